@@ -0,0 +1,91 @@
+package config
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Access request statuses
+const (
+	AccessRequestPending   = "pending"
+	AccessRequestApproved  = "approved"
+	AccessRequestRejected  = "rejected"
+	AccessRequestCancelled = "cancelled"
+	AccessRequestExpired   = "expired"
+)
+
+type AccessRequest struct {
+	ID            uuid.UUID  `json:"id"`
+	ProjectID     uuid.UUID  `json:"project_id"`
+	UserID        uuid.UUID  `json:"user_id"`
+	UserEmail     string     `json:"user_email,omitempty"`
+	UserPublicKey []byte     `json:"user_public_key,omitempty"`
+	EnvName       string     `json:"env_name"`
+	Reason        string     `json:"reason"`
+	Status        string     `json:"status"`
+	DecidedBy     *uuid.UUID `json:"decided_by,omitempty"`
+	DecidedAt     *time.Time `json:"decided_at,omitempty"`
+	DecisionNote  *string    `json:"decision_note,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+}
+
+// AccessRequestCreateRequest POST /projects/access-requests/create
+type AccessRequestCreateRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   string    `json:"env_name"`
+	Reason    string    `json:"reason"`
+}
+type AccessRequestCreateResponse struct {
+	Message       string        `json:"message"`
+	AccessRequest AccessRequest `json:"access_request"`
+}
+
+// AccessRequestListRequest POST /projects/access-requests/list
+type AccessRequestListRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Status    *string   `json:"status"`
+}
+type AccessRequestListResponse struct {
+	AccessRequests []AccessRequest `json:"access_requests"`
+}
+
+// AccessRequestMineRequest POST /projects/access-requests/mine
+type AccessRequestMineRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+}
+
+// AccessRequestApproveRequest POST /projects/access-requests/approve
+type AccessRequestApproveRequest struct {
+	RequestID uuid.UUID `json:"request_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+
+	WrappedPRK         []byte `json:"wrapped_prk"`
+	WrapNonce          []byte `json:"wrap_nonce"`
+	EphemeralPublicKey []byte `json:"ephemeral_public_key"`
+
+	// ExpiresAt makes the resulting membership time-bound.
+	ExpiresAt *time.Time `json:"expires_at"`
+	Note      *string    `json:"note"`
+}
+
+// AccessRequestRejectRequest POST /projects/access-requests/reject
+type AccessRequestRejectRequest struct {
+	RequestID uuid.UUID `json:"request_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Note      *string   `json:"note"`
+}
+
+// AccessRequestCancelRequest POST /projects/access-requests/cancel
+type AccessRequestCancelRequest struct {
+	RequestID uuid.UUID `json:"request_id"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+type AccessRequestDecisionResponse struct {
+	Message       string        `json:"message"`
+	AccessRequest AccessRequest `json:"access_request"`
+}
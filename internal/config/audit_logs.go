@@ -22,6 +22,49 @@ const (
 	ActionServiceRoleCreate   = "service_role.create"
 	ActionServiceRoleDelete   = "service_role.delete"
 	ActionServiceRoleDelegate = "service_role.delegate"
+	ActionServiceRolePin      = "service_role.pin"
+
+	ActionAccessRequestCreate  = "access_request.create"
+	ActionAccessRequestApprove = "access_request.approve"
+	ActionAccessRequestReject  = "access_request.reject"
+	ActionAccessRequestCancel  = "access_request.cancel"
+	ActionAccessRequestExpire  = "access_request.expire"
+
+	ActionBreakGlassPolicy  = "break_glass.policy"
+	ActionBreakGlassRequest = "break_glass.request"
+	ActionBreakGlassFulfill = "break_glass.fulfill"
+	ActionBreakGlassRevoke  = "break_glass.revoke"
+	ActionBreakGlassExpire  = "break_glass.expire"
+
+	ActionEnvRollback         = "env.rollback"
+	ActionEnvPromote          = "env.promote"
+	ActionEnvProtect          = "env.protect"
+	ActionEnvCandidateApprove = "env.candidate.approve"
+	ActionEnvCandidateReject  = "env.candidate.reject"
+	ActionEnvCandidateExpire  = "env.candidate.expire"
+	ActionEnvLabelSet         = "env.label.set"
+	ActionEnvLabelDelete      = "env.label.delete"
+	ActionEnvShred            = "env.shred"
+	ActionEnvSecretExpiring   = "env.secret.expiring"
+	ActionEnvSecretOverdue    = "env.secret.overdue"
+	ActionEnvSchemaSet        = "env.schema.set"
+
+	ActionEnvironmentCreate   = "environment.create"
+	ActionEnvironmentRename   = "environment.rename"
+	ActionEnvironmentArchive  = "environment.archive"
+	ActionEnvironmentRestore  = "environment.restore"
+	ActionEnvironmentDelete   = "environment.delete"
+	ActionEnvironmentSettings = "environment.settings"
+	ActionEnvironmentLock     = "environment.lock"
+	ActionEnvironmentUnlock   = "environment.unlock"
+
+	ActionWebhookCreate    = "webhook.create"
+	ActionWebhookDelete    = "webhook.delete"
+	ActionWebhookRedeliver = "webhook.redeliver"
+
+	ActionShareCreate = "share.create"
+	ActionShareView   = "share.view"
+	ActionShareRevoke = "share.revoke"
 )
 
 // Actor types
@@ -37,34 +80,45 @@ const (
 	StatusFailure = "failure"
 )
 
+// Severity levels
+const (
+	SeverityInfo     = "info"
+	SeverityWarning  = "warning"
+	SeverityCritical = "critical"
+)
+
 
 type AuditLog struct {
-	ID           uuid.UUID       `json:"id"`
-	Timestamp    time.Time       `json:"timestamp"`
-	RequestID    string          `json:"request_id"`
-	ActorType    string          `json:"actor_type"`
-	ActorID      string          `json:"actor_id"`
-	ActorEmail   string          `json:"actor_email"`
-	Action       string          `json:"action"`
-	ProjectID    *uuid.UUID      `json:"project_id"`
-	Environment  *string         `json:"environment"`
-	TargetID     *string         `json:"target_id"`
-	IPAddress    *string         `json:"ip_address"`
-	UserAgent    *string         `json:"user_agent"`
-	Status       string          `json:"status"`
-	ErrorMessage *string         `json:"error_message"`
-	Metadata     json.RawMessage `json:"metadata"`
+	ID                 uuid.UUID       `json:"id"`
+	Timestamp          time.Time       `json:"timestamp"`
+	RequestID          string          `json:"request_id"`
+	ActorType          string          `json:"actor_type"`
+	ActorID            string          `json:"actor_id"`
+	ActorEmail         string          `json:"actor_email"`
+	Action             string          `json:"action"`
+	ProjectID          *uuid.UUID      `json:"project_id"`
+	Environment        *string         `json:"environment"`
+	CurrentEnvironment *string         `json:"current_environment,omitempty"` // set when renamed since
+	TargetID           *string         `json:"target_id"`
+	IPAddress          *string         `json:"ip_address"`
+	UserAgent          *string         `json:"user_agent"`
+	Status             string          `json:"status"`
+	ErrorMessage       *string         `json:"error_message"`
+	Metadata           json.RawMessage `json:"metadata"`
+	Severity           string          `json:"severity"`
 }
 
 type ProjectAuditRequest struct {
-	ProjectID  uuid.UUID  `json:"project_id"`
-	Limit      int32      `json:"limit"`
-	Offset     int32      `json:"offset"`
-	ActorEmail *string    `json:"actor_email"`
-	Action     *string    `json:"action"`
-	Status     *string    `json:"status"`
-	From       *time.Time `json:"from"`
-	To         *time.Time `json:"to"`
+	ProjectID   uuid.UUID  `json:"project_id"`
+	Limit       int32      `json:"limit"`
+	Offset      int32      `json:"offset"`
+	ActorEmail  *string    `json:"actor_email"`
+	Action      *string    `json:"action"`
+	Status      *string    `json:"status"`
+	Severity    *string    `json:"severity"`
+	Environment *string    `json:"environment"` // follows renames
+	From        *time.Time `json:"from"`
+	To          *time.Time `json:"to"`
 }
 
 type ProjectAuditResponse struct {
@@ -0,0 +1,110 @@
+package config
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Break-glass grant statuses
+const (
+	BreakGlassPending = "pending"
+	BreakGlassActive  = "active"
+	BreakGlassExpired = "expired"
+	BreakGlassRevoked = "revoked"
+)
+
+type BreakGlassPolicy struct {
+	ProjectID     uuid.UUID  `json:"project_id"`
+	EscrowUserID  *uuid.UUID `json:"escrow_user_id,omitempty"`
+	WindowMinutes int32      `json:"window_minutes"`
+	UpdatedBy     uuid.UUID  `json:"updated_by"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+type BreakGlassEligibleUser struct {
+	UserID  uuid.UUID `json:"user_id"`
+	Email   string    `json:"email"`
+	AddedBy uuid.UUID `json:"added_by"`
+	AddedAt time.Time `json:"added_at"`
+}
+
+type BreakGlassGrant struct {
+	ID            uuid.UUID  `json:"id"`
+	ProjectID     uuid.UUID  `json:"project_id"`
+	UserID        uuid.UUID  `json:"user_id"`
+	UserEmail     string     `json:"user_email,omitempty"`
+	UserPublicKey []byte     `json:"user_public_key,omitempty"`
+	EnvName       string     `json:"env_name"`
+	Justification string     `json:"justification"`
+	Status        string     `json:"status"`
+	WindowMinutes int32      `json:"window_minutes"`
+	FulfilledBy   *uuid.UUID `json:"fulfilled_by,omitempty"`
+	FulfilledAt   *time.Time `json:"fulfilled_at,omitempty"`
+	RevokedBy     *uuid.UUID `json:"revoked_by,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	ExpiresAt     time.Time  `json:"expires_at"`
+}
+
+// BreakGlassPolicyRequest POST /projects/break-glass/policy
+type BreakGlassPolicyRequest struct {
+	ProjectID     uuid.UUID  `json:"project_id"`
+	AdminID       uuid.UUID  `json:"admin_id"`
+	EscrowUserID  *uuid.UUID `json:"escrow_user_id"`
+	WindowMinutes int32      `json:"window_minutes"`
+}
+type BreakGlassPolicyResponse struct {
+	Policy        BreakGlassPolicy         `json:"policy"`
+	EligibleUsers []BreakGlassEligibleUser `json:"eligible_users"`
+}
+
+// BreakGlassPolicyGetRequest POST /projects/break-glass/policy/get
+type BreakGlassPolicyGetRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+}
+
+// BreakGlassEligibleRequest POST /projects/break-glass/eligible/{add,remove}
+type BreakGlassEligibleRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+
+// BreakGlassCreateRequest POST /projects/break-glass/request
+type BreakGlassCreateRequest struct {
+	ProjectID     uuid.UUID `json:"project_id"`
+	UserID        uuid.UUID `json:"user_id"`
+	EnvName       string    `json:"env_name"`
+	Justification string    `json:"justification"`
+}
+
+// BreakGlassPendingRequest POST /projects/break-glass/pending
+type BreakGlassPendingRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+type BreakGlassPendingResponse struct {
+	Grants []BreakGlassGrant `json:"grants"`
+}
+
+// BreakGlassFulfillRequest POST /projects/break-glass/fulfill
+type BreakGlassFulfillRequest struct {
+	GrantID     uuid.UUID `json:"grant_id"`
+	FulfillerID uuid.UUID `json:"fulfiller_id"`
+
+	WrappedPRK         []byte `json:"wrapped_prk"`
+	WrapNonce          []byte `json:"wrap_nonce"`
+	EphemeralPublicKey []byte `json:"ephemeral_public_key"`
+}
+
+// BreakGlassRevokeRequest POST /projects/break-glass/revoke
+type BreakGlassRevokeRequest struct {
+	GrantID uuid.UUID `json:"grant_id"`
+	AdminID uuid.UUID `json:"admin_id"`
+}
+
+type BreakGlassGrantResponse struct {
+	Message string          `json:"message"`
+	Grant   BreakGlassGrant `json:"grant"`
+}
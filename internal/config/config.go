@@ -13,6 +13,9 @@ type Config struct {
 	DatabaseDriver string
 	JWTSecret      string
 	Env            string
+	// WebhookKey is the hex-encoded 32-byte key webhook signing secrets are
+	// encrypted with. Webhooks cannot be created without it.
+	WebhookKey string
 }
 
 func Load() *Config {
@@ -26,6 +29,7 @@ func Load() *Config {
 		DatabaseURL:    mustEnv("DATABASE_URL"),
 		DatabaseDriver: getEnv("DATABASE_DRIVER", "postgres"),
 		Env:            getEnv("ENV", "development"),
+		WebhookKey:     getEnv("WEBHOOK_KEY", ""),
 	}
 	return cfg
 }
@@ -1,17 +1,108 @@
 package config
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Env version statuses. Pushes to protected environments start out pending.
+const (
+	EnvVersionPending   = "pending"
+	EnvVersionPublished = "published"
+	EnvVersionRejected  = "rejected"
+	EnvVersionExpired   = "expired"
+)
+
+// EncryptionVersionEntries marks versions stored as per-key entries under the
+// version DEK instead of a single ciphertext of the whole file.
+const EncryptionVersionEntries = 3
 
 type Metadata struct {
 	Type string `json:"type"`
+
+	// Message describes the change, like a commit message.
+	Message string `json:"message,omitempty"`
+	// Source is where the version came from, one of the MetadataSource values.
+	Source        string `json:"source,omitempty"`
+	ClientVersion string `json:"client_version,omitempty"`
+	// ParentVersion is always filled in by the server from the version the push
+	// was based on; values sent by clients are ignored.
+	ParentVersion *int32            `json:"parent_version,omitempty"`
+	Tags          []string          `json:"tags,omitempty"`
+	Annotations   map[string]string `json:"annotations,omitempty"`
+
+	// Provenance is set on versions the server copied from another version.
+	Provenance *Provenance `json:"provenance,omitempty"`
+
+	// Keys summarizes the version's contents without revealing them, so
+	// reviewers can see what changed between versions.
+	Keys []KeySummary `json:"keys,omitempty"`
+
+	// ExpiresAt is when the secrets of the whole version are due for
+	// rotation. Single keys can carry their own date in Keys.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
 }
 
+// KeySummary identifies one key of a version. KeyHMAC is an HMAC of the key
+// name and ValueFingerprint an HMAC of its value, both under a PRK-derived key
+// the server never sees.
+type KeySummary struct {
+	KeyHMAC          []byte `json:"key_hmac"`
+	ValueFingerprint []byte `json:"value_fingerprint"`
+
+	// ExpiresAt is when this key's value is due for rotation.
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// Provenance records where a rolled back or promoted version was copied from.
+type Provenance struct {
+	Operation string `json:"operation"`
+	EnvName   string `json:"env_name"`
+	Version   int32  `json:"version"`
+}
+
+// Metadata types for server-side copies
+const (
+	MetadataTypeRollback = "env_rollback"
+	MetadataTypePromote  = "env_promote"
+
+	// MetadataTypeUnknown is reported for stored metadata that no longer decodes.
+	MetadataTypeUnknown = "unknown"
+)
+
+// Metadata sources. Rollback and promote are only set by the server.
+const (
+	MetadataSourceCLI      = "cli"
+	MetadataSourceCI       = "ci"
+	MetadataSourceAPI      = "api"
+	MetadataSourceImport   = "import"
+	MetadataSourceRollback = "rollback"
+	MetadataSourcePromote  = "promote"
+)
+
 type GetEnvRequest struct {
 	ProjectId uuid.UUID `json:"project_id"`
 	Email     string    `json:"user_email"`
 
 	EnvName string `json:"env_name"`
 	Version *int32 `json:"version"`
+	// Label fetches the version a label points at instead of the latest one.
+	Label *string `json:"label"`
+	// KnownVersion is the version the client already holds. When it is still
+	// the one the read resolves to, no ciphertext is returned.
+	KnownVersion *int32 `json:"known_version,omitempty"`
+	// KnownTags are the ETags of an If-None-Match header; any of them naming
+	// the version the read resolves to counts as known.
+	KnownTags []EnvTag `json:"-"`
+}
+
+// EnvTag is what a pull's ETag names: one version of one environment. The
+// environment ID keeps a tag from matching an environment deleted and created
+// again under the same name.
+type EnvTag struct {
+	EnvironmentID uuid.UUID
+	Version       int32
 }
 
 type GetEnvResponse struct {
@@ -20,6 +111,56 @@ type GetEnvResponse struct {
 	WrappedDEK        []byte `json:"wrapped_dek,omitempty"`
 	DekNonce          []byte `json:"dek_nonce,omitempty"`
 	EncryptionVersion int32  `json:"encryption_version"`
+	Version           int32  `json:"version"`
+
+	// EnvironmentID and Version make up the response's ETag, unless the
+	// response is Composite.
+	EnvironmentID uuid.UUID `json:"environment_id"`
+
+	Entries []EnvEntry `json:"entries,omitempty"`
+
+	// Parents holds one version of every environment this one inherits from,
+	// root first: the ones a pinned version was pushed on top of, or the latest
+	// ones for a read of the latest version. Clients merge them in order, then
+	// this version on top.
+	Parents []ParentEnv `json:"parents,omitempty"`
+
+	// References holds the environments and entries of other projects this
+	// environment references, with the caller's wrapped PRK for each of
+	// those projects in ReferenceKeys.
+	References    []ResolvedReference      `json:"references,omitempty"`
+	ReferenceKeys []GetUserProjectResponse `json:"reference_keys,omitempty"`
+
+	// NotModified is set when the client's known version is still current;
+	// the response then carries only EnvironmentID and Version.
+	NotModified bool `json:"-"`
+
+	// Composite is set when the response merges parent or referenced layers
+	// that Version does not identify; it is then sent without an ETag.
+	Composite bool `json:"-"`
+}
+
+// ResolvedReference answers one reference of a pulled environment. Exactly
+// one of Env and Error is set; a reference the caller cannot read fails on its
+// own without failing the pull. Env is the latest published version of the
+// referenced environment, holding only the referenced entry for entry
+// references.
+type ResolvedReference struct {
+	ID           uuid.UUID      `json:"id"`
+	RefProjectID uuid.UUID      `json:"ref_project_id"`
+	RefEnvName   string         `json:"ref_env_name"`
+	KeyHMAC      []byte         `json:"key_hmac,omitempty"`
+	Env          *EnvResponse   `json:"env,omitempty"`
+	Error        *BatchEnvError `json:"error,omitempty"`
+}
+
+// ParentEnv is one layer of an inherited environment.
+type ParentEnv struct {
+	EnvName string `json:"env_name"`
+	EnvResponse
+
+	// VersionID identifies the layer for the drift report.
+	VersionID uuid.UUID `json:"-"`
 }
 
 type GetEnvVersionsRequest struct {
@@ -37,6 +178,14 @@ type EnvResponse struct {
 	EncryptionVersion int32    `json:"encryption_version"`
 	Version           int32    `json:"version"`
 	Metadata          Metadata `json:"metadata"`
+
+	CreatedBy uuid.UUID `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+
+	// ShreddedAt is set once the version's key and ciphertext were destroyed.
+	ShreddedAt *time.Time `json:"shredded_at,omitempty"`
+
+	Entries []EnvEntry `json:"entries,omitempty"`
 }
 type GetEnvVersionsResponse struct {
 	EnvVersions []EnvResponse `json:"env_versions"`
@@ -52,11 +201,19 @@ type AddEnvRequest struct {
 	DekNonce          []byte `json:"dek_nonce"`
 	EncryptionVersion int32  `json:"encryption_version"`
 
+	// ExpectedVersion is the published version the push is based on; the
+	// If-Match header is accepted as an alternative. An If-Match ETag also
+	// sets ExpectedEnvironmentID.
+	ExpectedVersion       *int32     `json:"expected_version"`
+	ExpectedEnvironmentID *uuid.UUID `json:"-"`
+
 	Metadata Metadata `json:"metadata"`
 }
 
 type AddEnvResponse struct {
 	Message string `json:"message"`
+	Version int32  `json:"version"`
+	Status  string `json:"status"`
 }
 
 type UpdateEnvRequest struct {
@@ -70,16 +227,35 @@ type UpdateEnvRequest struct {
 	DekNonce          []byte `json:"dek_nonce"`
 	EncryptionVersion int32  `json:"encryption_version"`
 
+	// ExpectedVersion is the published version the push is based on; the
+	// If-Match header is accepted as an alternative. An If-Match ETag also
+	// sets ExpectedEnvironmentID.
+	ExpectedVersion       *int32     `json:"expected_version"`
+	ExpectedEnvironmentID *uuid.UUID `json:"-"`
+
 	Metadata Metadata `json:"metadata"`
 }
 
 type UpdateEnvResponse struct {
 	Message string `json:"message"`
+	Version int32  `json:"version"`
+	Status  string `json:"status"`
 }
 
 type GetEnvForCIRequest struct {
 	ProjectId uuid.UUID `json:"project_id"`
 	EnvName   string    `json:"env_name"`
+	Label     *string   `json:"label"`
+
+	// SessionID is the CI session from the OIDC login. When the session's
+	// service role is pinned to a label, Label must be empty or match it.
+	SessionID uuid.UUID `json:"session_id"`
+
+	KnownVersion *int32   `json:"known_version,omitempty"`
+	KnownTags    []EnvTag `json:"-"`
+	// Consumer names the deployment the pull is for. What it resolved to is
+	// remembered for the drift report; only CI pulls are tracked.
+	Consumer *string `json:"consumer,omitempty"`
 }
 type GetEnvForCIResponse struct {
 	CipherText        []byte `json:"cipher_text"`
@@ -87,4 +263,292 @@ type GetEnvForCIResponse struct {
 	WrappedDEK        []byte `json:"wrapped_dek"`
 	DekNonce          []byte `json:"dek_nonce"`
 	EncryptionVersion int32  `json:"encryption_version"`
+	Version           int32  `json:"version"`
+
+	// EnvironmentID and Version make up the response's ETag, unless the
+	// response is Composite.
+	EnvironmentID uuid.UUID `json:"environment_id"`
+
+	Entries []EnvEntry `json:"entries,omitempty"`
+
+	Parents []ParentEnv `json:"parents,omitempty"`
+
+	// References lists what the environment references, each with an error:
+	// a CI session holds no keys for other projects, so none is resolved.
+	References []ResolvedReference `json:"references,omitempty"`
+
+	NotModified bool `json:"-"`
+	Composite   bool `json:"-"`
+}
+
+// ProtectEnvRequest POST /env/protect
+type ProtectEnvRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	EnvName   string    `json:"env_name"`
+	Protected bool      `json:"protected"`
+}
+type ProtectEnvResponse struct {
+	Message               string   `json:"message"`
+	ProtectedEnvironments []string `json:"protected_environments"`
+}
+
+type EnvCandidate struct {
+	ID             uuid.UUID  `json:"id"`
+	EnvName        string     `json:"env_name"`
+	Version        int32      `json:"version"`
+	Status         string     `json:"status"`
+	Metadata       Metadata   `json:"metadata"`
+	CreatedBy      uuid.UUID  `json:"created_by"`
+	CreatedByEmail string     `json:"created_by_email,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ExpiresAt      *time.Time `json:"expires_at,omitempty"`
+	ReviewedBy     *uuid.UUID `json:"reviewed_by,omitempty"`
+	ReviewedAt     *time.Time `json:"reviewed_at,omitempty"`
+	ReviewNote     *string    `json:"review_note,omitempty"`
+}
+
+// EnvCandidatesRequest POST /env/candidates
+type EnvCandidatesRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   *string   `json:"env_name"`
+}
+type EnvCandidatesResponse struct {
+	Candidates []EnvCandidate `json:"candidates"`
+}
+
+// EnvCandidateGetRequest POST /env/candidates/get
+type EnvCandidateGetRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   string    `json:"env_name"`
+	Version   int32     `json:"version"`
+}
+type EnvCandidateGetResponse struct {
+	Candidate EnvCandidate `json:"candidate"`
+	Env       EnvResponse  `json:"env"`
+}
+
+// EnvCandidateReviewRequest POST /env/candidates/{approve,reject}
+type EnvCandidateReviewRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	EnvName   string    `json:"env_name"`
+	Version   int32     `json:"version"`
+	Note      *string   `json:"note"`
+}
+type EnvCandidateReviewResponse struct {
+	Message   string       `json:"message"`
+	Candidate EnvCandidate `json:"candidate"`
+}
+
+// RollbackEnvRequest POST /env/rollback
+type RollbackEnvRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+
+	EnvName               string     `json:"env_name"`
+	ToVersion             int32      `json:"to_version"`
+	ExpectedVersion       *int32     `json:"expected_version"`
+	ExpectedEnvironmentID *uuid.UUID `json:"-"`
+}
+
+// PromoteEnvRequest POST /env/promote
+type PromoteEnvRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+
+	FromEnv     string `json:"from_env"`
+	FromVersion *int32 `json:"from_version"`
+	ToEnv       string `json:"to_env"`
+
+	ExpectedVersion       *int32     `json:"expected_version"`
+	ExpectedEnvironmentID *uuid.UUID `json:"-"`
+}
+
+type CopyEnvResponse struct {
+	Message    string     `json:"message"`
+	Version    int32      `json:"version"`
+	Status     string     `json:"status"`
+	Provenance Provenance `json:"provenance"`
+}
+
+// DiffEnvRequest POST /env/diff
+type DiffEnvRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+
+	EnvName     string `json:"env_name"`
+	FromVersion int32  `json:"from_version"`
+	ToVersion   int32  `json:"to_version"`
+}
+
+// DiffEnvResponse lists blinded keys; clients match them against their own key HMACs.
+// Keys in both versions land in Unknown when only one version has a key summary.
+type DiffEnvResponse struct {
+	FromVersion int32    `json:"from_version"`
+	ToVersion   int32    `json:"to_version"`
+	Added       [][]byte `json:"added"`
+	Removed     [][]byte `json:"removed"`
+	Changed     [][]byte `json:"changed"`
+	Unknown     [][]byte `json:"unknown"`
+	Unchanged   int      `json:"unchanged"`
+}
+
+// SearchEnvVersionsRequest POST /env/versions/search
+type SearchEnvVersionsRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+
+	EnvName *string `json:"env_name"`
+	Tag     *string `json:"tag"`
+	// Query matches the version message, case-insensitively.
+	Query *string `json:"query"`
+	Limit int32   `json:"limit"`
+}
+
+type EnvVersionSummary struct {
+	EnvName           string     `json:"env_name"`
+	Version           int32      `json:"version"`
+	Metadata          Metadata   `json:"metadata"`
+	EncryptionVersion int32      `json:"encryption_version"`
+	Size              int64      `json:"size"`
+	CreatedBy         uuid.UUID  `json:"created_by"`
+	CreatedByEmail    string     `json:"created_by_email"`
+	CreatedAt         time.Time  `json:"created_at"`
+	ShreddedAt        *time.Time `json:"shredded_at,omitempty"`
+}
+
+type SearchEnvVersionsResponse struct {
+	Versions []EnvVersionSummary `json:"versions"`
+}
+
+// ListEnvVersionsRequest POST /env/versions
+type ListEnvVersionsRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   string    `json:"env_name"`
+
+	Limit  int32      `json:"limit"`
+	Cursor string     `json:"cursor"`
+	From   *time.Time `json:"from"`
+	To     *time.Time `json:"to"`
+}
+type ListEnvVersionsResponse struct {
+	Versions []EnvVersionSummary `json:"versions"`
+	// NextCursor is empty on the last page.
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// FetchEnvVersionsRequest POST /env/versions/fetch
+type FetchEnvVersionsRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   string    `json:"env_name"`
+	Versions  []int32   `json:"versions"`
+}
+type FetchEnvVersionsResponse struct {
+	EnvVersions []EnvResponse `json:"env_versions"`
+}
+
+// ShredEnvRequest POST /env/shred
+type ShredEnvRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	EnvName   string    `json:"env_name"`
+	Versions  []int32   `json:"versions"`
+	Reason    string    `json:"reason"`
+}
+type ShredEnvResponse struct {
+	Message  string  `json:"message"`
+	Shredded []int32 `json:"shredded"`
+}
+
+// BatchEnvRequest POST /env/batch
+type BatchEnvRequest struct {
+	UserID uuid.UUID      `json:"user_id"`
+	Items  []BatchEnvItem `json:"items"`
+}
+
+// BatchEnvItem selects one environment read, like a single /env/search.
+type BatchEnvItem struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	EnvName   string    `json:"env_name"`
+	Version   *int32    `json:"version,omitempty"`
+	Label     *string   `json:"label,omitempty"`
+}
+
+// BatchEnvResult answers one item, in request order. Exactly one of Env and
+// Error is set.
+type BatchEnvResult struct {
+	ProjectID uuid.UUID       `json:"project_id"`
+	EnvName   string          `json:"env_name"`
+	Env       *GetEnvResponse `json:"env,omitempty"`
+	Error     *BatchEnvError  `json:"error,omitempty"`
+}
+type BatchEnvError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+	Hint    string `json:"hint,omitempty"`
+}
+
+type BatchEnvResponse struct {
+	Items []BatchEnvResult `json:"items"`
+	// Keys holds the caller's wrapped PRK once for every requested project
+	// the caller is a member of.
+	Keys []GetUserProjectResponse `json:"keys"`
+}
+
+// EnvDriftRequest POST /env/drift
+type EnvDriftRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   *string   `json:"env_name,omitempty"`
+	// DriftedOnly leaves out consumers running the version they should.
+	DriftedOnly bool `json:"drifted_only"`
+}
+
+// EnvConsumer is the last pull of one consumer. A consumer that pulled through
+// a label should run the version the label points at now; any other consumer
+// should run the latest version on top of the latest parent versions.
+// ParentsDrifted is set when only the parent layers moved on.
+type EnvConsumer struct {
+	EnvName        string    `json:"env_name"`
+	Consumer       string    `json:"consumer"`
+	Version        int32     `json:"version"`
+	Label          *string   `json:"label,omitempty"`
+	LatestVersion  int32     `json:"latest_version"`
+	LabelVersion   *int32    `json:"label_version,omitempty"`
+	Drifted        bool      `json:"drifted"`
+	ParentsDrifted bool      `json:"parents_drifted,omitempty"`
+	PulledBy       string    `json:"pulled_by"`
+	PulledAt       time.Time `json:"pulled_at"`
+}
+
+type EnvDriftResponse struct {
+	Consumers []EnvConsumer `json:"consumers"`
+}
+
+// EnvExpiringRequest POST /env/expiring
+type EnvExpiringRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	// WithinDays is how far ahead to look, 7 days when unset. Overdue
+	// secrets are always included.
+	WithinDays *int32 `json:"within_days,omitempty"`
+}
+
+// EnvExpiry is one expiry date of the latest published version of an
+// environment. KeyHMAC is empty when the date covers the whole version.
+type EnvExpiry struct {
+	EnvName   string    `json:"env_name"`
+	Version   int32     `json:"version"`
+	KeyHMAC   []byte    `json:"key_hmac,omitempty"`
+	ExpiresAt time.Time `json:"expires_at"`
+	Overdue   bool      `json:"overdue"`
+}
+
+type EnvExpiringResponse struct {
+	Expiries []EnvExpiry `json:"expiries"`
 }
@@ -0,0 +1,91 @@
+package config
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// EnvEntry is one key of a per-key version. KeyHMAC blinds the key name with
+// an HMAC keyed from the PRK; name and value are encrypted under the version DEK.
+type EnvEntry struct {
+	KeyHMAC          []byte `json:"key_hmac"`
+	NameCiphertext   []byte `json:"name_ciphertext"`
+	NameNonce        []byte `json:"name_nonce"`
+	ValueCiphertext  []byte `json:"value_ciphertext"`
+	ValueNonce       []byte `json:"value_nonce"`
+	ChangedInVersion int32  `json:"changed_in_version"`
+}
+
+type EnvEntryWrite struct {
+	KeyHMAC         []byte `json:"key_hmac"`
+	NameCiphertext  []byte `json:"name_ciphertext"`
+	NameNonce       []byte `json:"name_nonce"`
+	ValueCiphertext []byte `json:"value_ciphertext"`
+	ValueNonce      []byte `json:"value_nonce"`
+
+	// ExpectedVersion is the changed_in_version the client last saw for this
+	// key, 0 when the key must not exist yet.
+	ExpectedVersion *int32 `json:"expected_version"`
+}
+
+type EnvEntryDelete struct {
+	KeyHMAC         []byte `json:"key_hmac"`
+	ExpectedVersion *int32 `json:"expected_version"`
+}
+
+// PushEnvEntriesRequest POST /env/entries/push
+type PushEnvEntriesRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   string    `json:"env_name"`
+
+	// Replace starts a fresh entry set under a new DEK instead of patching the
+	// latest version, which is required for the first per-key push.
+	Replace    bool   `json:"replace"`
+	WrappedDEK []byte `json:"wrapped_dek"`
+	DekNonce   []byte `json:"dek_nonce"`
+
+	Set    []EnvEntryWrite  `json:"set"`
+	Delete []EnvEntryDelete `json:"delete"`
+
+	// ExpectedVersion pins the whole environment; leave it unset to only
+	// conflict on the keys being changed. An If-Match ETag also sets
+	// ExpectedEnvironmentID.
+	ExpectedVersion       *int32     `json:"expected_version"`
+	ExpectedEnvironmentID *uuid.UUID `json:"-"`
+
+	Metadata Metadata `json:"metadata"`
+}
+
+type PushEnvEntriesResponse struct {
+	Message string `json:"message"`
+	Version int32  `json:"version"`
+	Status  string `json:"status"`
+}
+
+// EnvEntryHistoryRequest POST /env/entries/history
+type EnvEntryHistoryRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   string    `json:"env_name"`
+	KeyHMAC   []byte    `json:"key_hmac"`
+}
+
+// EnvEntryRevision is a published version in which the key was written,
+// together with the DEK needed to decrypt it.
+type EnvEntryRevision struct {
+	Version         int32     `json:"version"`
+	WrappedDEK      []byte    `json:"wrapped_dek"`
+	DekNonce        []byte    `json:"dek_nonce"`
+	NameCiphertext  []byte    `json:"name_ciphertext"`
+	NameNonce       []byte    `json:"name_nonce"`
+	ValueCiphertext []byte    `json:"value_ciphertext"`
+	ValueNonce      []byte    `json:"value_nonce"`
+	CreatedBy       uuid.UUID `json:"created_by"`
+	CreatedAt       time.Time `json:"created_at"`
+}
+
+type EnvEntryHistoryResponse struct {
+	Revisions []EnvEntryRevision `json:"revisions"`
+}
@@ -0,0 +1,49 @@
+package config
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type EnvLabel struct {
+	Name      string    `json:"name"`
+	Version   int32     `json:"version"`
+	UpdatedBy uuid.UUID `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// EnvLabelsRequest POST /env/labels
+type EnvLabelsRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   string    `json:"env_name"`
+}
+type EnvLabelsResponse struct {
+	Labels []EnvLabel `json:"labels"`
+}
+
+// EnvLabelSetRequest POST /env/labels/set
+type EnvLabelSetRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   string    `json:"env_name"`
+	Label     string    `json:"label"`
+	Version   int32     `json:"version"`
+}
+
+// EnvLabelDeleteRequest POST /env/labels/delete
+type EnvLabelDeleteRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	EnvName   string    `json:"env_name"`
+	Label     string    `json:"label"`
+}
+type EnvLabelDeleteResponse struct {
+	Message string `json:"message"`
+}
+
+type EnvLabelResponse struct {
+	Message string   `json:"message"`
+	Label   EnvLabel `json:"label"`
+}
@@ -0,0 +1,50 @@
+package config
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/pkg/envschema"
+)
+
+// EnvSchema is one version of a project's environment schema. Schemas are
+// plaintext; clients validate decrypted environments against them with
+// package envschema before pushing.
+type EnvSchema struct {
+	ProjectID uuid.UUID        `json:"project_id"`
+	Version   int32            `json:"version"`
+	Schema    envschema.Schema `json:"schema"`
+	CreatedBy uuid.UUID        `json:"created_by"`
+	CreatedAt time.Time        `json:"created_at"`
+}
+
+// EnvSchemaRequest POST /environments/schema
+type EnvSchemaRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	// Version selects an older schema; without it the current one is returned.
+	Version *int32 `json:"version,omitempty"`
+}
+
+// EnvSchemaSetRequest POST /environments/schema/set
+type EnvSchemaSetRequest struct {
+	ProjectID uuid.UUID        `json:"project_id"`
+	AdminID   uuid.UUID        `json:"admin_id"`
+	Schema    envschema.Schema `json:"schema"`
+	// ExpectedVersion is the schema version the change is based on, 0 for a
+	// project without a schema yet.
+	ExpectedVersion *int32 `json:"expected_version,omitempty"`
+}
+type EnvSchemaResponse struct {
+	Message string    `json:"message"`
+	Schema  EnvSchema `json:"schema"`
+}
+
+// EnvSchemaHistoryRequest POST /environments/schema/history
+type EnvSchemaHistoryRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+type EnvSchemaHistoryResponse struct {
+	Schemas []EnvSchema `json:"schemas"`
+}
@@ -0,0 +1,205 @@
+package config
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+type Environment struct {
+	ID          uuid.UUID  `json:"id"`
+	ProjectID   uuid.UUID  `json:"project_id"`
+	Name        string     `json:"name"`
+	Description string     `json:"description"`
+	Protected   bool       `json:"protected"`
+	CreatedBy   uuid.UUID  `json:"created_by"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ArchivedAt  *time.Time `json:"archived_at,omitempty"`
+
+	// Retention is the environment's own policy; without one the project default applies.
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+	// Lock is set while writes to the environment are frozen.
+	Lock *EnvironmentLock `json:"lock,omitempty"`
+	// ParentID is the environment this one inherits from.
+	ParentID *uuid.UUID `json:"parent_id,omitempty"`
+	// Overdue is set when a secret in the latest published version is past
+	// its expiry date.
+	Overdue bool `json:"overdue,omitempty"`
+}
+
+type EnvironmentLock struct {
+	LockedBy uuid.UUID  `json:"locked_by"`
+	Reason   string     `json:"reason"`
+	LockedAt time.Time  `json:"locked_at"`
+	Until    *time.Time `json:"until,omitempty"`
+}
+
+// RetentionPolicy keeps the newest KeepVersions versions and/or versions younger
+// than KeepDays days; older versions are shredded by a background job.
+type RetentionPolicy struct {
+	KeepVersions *int32 `json:"keep_versions,omitempty"`
+	KeepDays     *int32 `json:"keep_days,omitempty"`
+}
+
+// EnvironmentListRequest POST /environments/list
+type EnvironmentListRequest struct {
+	ProjectID       uuid.UUID `json:"project_id"`
+	UserID          uuid.UUID `json:"user_id"`
+	IncludeArchived bool      `json:"include_archived"`
+}
+type EnvironmentListResponse struct {
+	Environments []Environment    `json:"environments"`
+	AutoCreate   bool             `json:"auto_create"`
+	Retention    *RetentionPolicy `json:"retention,omitempty"`
+}
+
+// EnvironmentCreateRequest POST /environments/create
+type EnvironmentCreateRequest struct {
+	ProjectID   uuid.UUID `json:"project_id"`
+	AdminID     uuid.UUID `json:"admin_id"`
+	Name        string    `json:"name"`
+	Description string    `json:"description"`
+	Protected   bool      `json:"protected"`
+}
+
+// EnvironmentRenameRequest POST /environments/rename
+type EnvironmentRenameRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Name      string    `json:"name"`
+	NewName   string    `json:"new_name"`
+}
+
+// EnvironmentArchiveRequest POST /environments/archive
+type EnvironmentArchiveRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Name      string    `json:"name"`
+}
+
+// EnvironmentLockRequest POST /environments/lock
+type EnvironmentLockRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Name      string    `json:"name"`
+	Reason    string    `json:"reason"`
+	// Until lifts the lock automatically; without it the lock holds until unlocked.
+	Until *time.Time `json:"until,omitempty"`
+}
+
+// EnvironmentUnlockRequest POST /environments/unlock
+type EnvironmentUnlockRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Name      string    `json:"name"`
+}
+
+// EnvironmentParentRequest POST /environments/parent
+type EnvironmentParentRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Name      string    `json:"name"`
+	// Parent is the environment to inherit from; without it the environment
+	// stops inheriting.
+	Parent *string `json:"parent,omitempty"`
+}
+
+// EnvironmentRestoreRequest POST /environments/restore
+type EnvironmentRestoreRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Name      string    `json:"name"`
+}
+
+// EnvironmentDeleteRequest POST /environments/delete
+type EnvironmentDeleteRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Name      string    `json:"name"`
+	// Confirm must repeat Name; deletion destroys every version.
+	Confirm string `json:"confirm"`
+}
+type EnvironmentDeleteResponse struct {
+	Message string `json:"message"`
+	Name    string `json:"name"`
+}
+
+type EnvironmentResponse struct {
+	Message     string      `json:"message"`
+	Environment Environment `json:"environment"`
+}
+
+// EnvironmentSettingsRequest POST /environments/settings
+type EnvironmentSettingsRequest struct {
+	ProjectID  uuid.UUID `json:"project_id"`
+	AdminID    uuid.UUID `json:"admin_id"`
+	AutoCreate bool      `json:"auto_create"`
+}
+type EnvironmentSettingsResponse struct {
+	Message    string `json:"message"`
+	AutoCreate bool   `json:"auto_create"`
+}
+
+// EnvironmentRetentionRequest POST /environments/retention
+type EnvironmentRetentionRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	// Name selects the environment; without it the project default is set.
+	// Sending neither limit clears the policy.
+	Name         *string `json:"name,omitempty"`
+	KeepVersions *int32  `json:"keep_versions,omitempty"`
+	KeepDays     *int32  `json:"keep_days,omitempty"`
+}
+type EnvironmentRetentionResponse struct {
+	Message   string           `json:"message"`
+	Name      *string          `json:"name,omitempty"`
+	Retention *RetentionPolicy `json:"retention,omitempty"`
+}
+
+// EnvironmentReference points an environment at an environment of another
+// project, or at a single entry of it when KeyHMAC is set.
+type EnvironmentReference struct {
+	ID           uuid.UUID `json:"id"`
+	RefProjectID uuid.UUID `json:"ref_project_id"`
+	RefEnvName   string    `json:"ref_env_name"`
+	KeyHMAC      []byte    `json:"key_hmac,omitempty"`
+	CreatedBy    uuid.UUID `json:"created_by"`
+	CreatedAt    time.Time `json:"created_at"`
+}
+
+// EnvironmentReferencesRequest POST /environments/references
+type EnvironmentReferencesRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	Name      string    `json:"name"`
+}
+type EnvironmentReferencesResponse struct {
+	References []EnvironmentReference `json:"references"`
+}
+
+// EnvironmentReferenceAddRequest POST /environments/references/add
+type EnvironmentReferenceAddRequest struct {
+	ProjectID    uuid.UUID `json:"project_id"`
+	AdminID      uuid.UUID `json:"admin_id"`
+	Name         string    `json:"name"`
+	RefProjectID uuid.UUID `json:"ref_project_id"`
+	RefEnvName   string    `json:"ref_env_name"`
+	// KeyHMAC is the blinded key of one entry, computed under the referenced
+	// project's PRK. Without it the whole environment is referenced.
+	KeyHMAC []byte `json:"key_hmac,omitempty"`
+}
+type EnvironmentReferenceResponse struct {
+	Message   string               `json:"message"`
+	Reference EnvironmentReference `json:"reference"`
+}
+
+// EnvironmentReferenceRemoveRequest POST /environments/references/remove
+type EnvironmentReferenceRemoveRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	AdminID   uuid.UUID `json:"admin_id"`
+	Name      string    `json:"name"`
+	ID        uuid.UUID `json:"id"`
+}
+type EnvironmentReferenceRemoveResponse struct {
+	Message string `json:"message"`
+}
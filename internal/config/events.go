@@ -0,0 +1,41 @@
+package config
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Event types delivered by /events/stream.
+const (
+	EventEnvVersionPublished = "env.version_published"
+	EventEnvLabelMoved       = "env.label_moved"
+	EventEnvParentUpdated    = "env.parent_updated"
+	EventEnvRenamed          = "env.renamed"
+	EventEnvSecretExpiring   = "env.secret_expiring"
+	EventEnvSecretOverdue    = "env.secret_overdue"
+	EventPRKRotated          = "project.prk_rotated"
+	EventAccessRevoked       = "project.access_revoked"
+)
+
+// EventStreamRequest POST /events/stream
+type EventStreamRequest struct {
+	UserID    uuid.UUID  `json:"user_id"`
+	ProjectID *uuid.UUID `json:"project_id,omitempty"`
+	EnvName   *string    `json:"env_name,omitempty"`
+
+	// LastEventID resumes the stream after that event. The Last-Event-ID
+	// header wins when both are sent; with neither the stream starts at the
+	// current tail.
+	LastEventID *int64 `json:"last_event_id,omitempty"`
+}
+
+type Event struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	ProjectID uuid.UUID       `json:"project_id"`
+	EnvName   *string         `json:"env_name,omitempty"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
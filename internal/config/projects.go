@@ -1,6 +1,10 @@
 package config
 
-import "github.com/google/uuid"
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
 
 type ProjectCreateRequest struct {
 	Name               string    `json:"name"`
@@ -25,10 +29,15 @@ type ListProjectRequest struct {
 	UserId uuid.UUID `json:"user_id"`
 }
 type Project struct {
-	Id        uuid.UUID `json:"project_id"`
-	Name      string    `json:"name"`
-	Role      string    `json:"role"`
-	IsRevoked bool      `json:"is_revoked"`
+	Id               uuid.UUID `json:"project_id"`
+	Name             string    `json:"name"`
+	Role             string    `json:"role"`
+	IsRevoked        bool      `json:"is_revoked"`
+	RotationRequired bool      `json:"rotation_required"`
+
+	// ExpiresAt is set for time-bound memberships; ExpiringSoon warns members ahead of it.
+	ExpiresAt    *time.Time `json:"expires_at,omitempty"`
+	ExpiringSoon bool       `json:"expiring_soon"`
 }
 
 type ListProjectResponse struct {
@@ -36,12 +45,13 @@ type ListProjectResponse struct {
 }
 
 type AddUserToProjectRequest struct {
-	ProjectName        string    `json:"project_name"`
-	AdminId            uuid.UUID `json:"admin_id"`
-	UserId             uuid.UUID `json:"user_id"`
-	WrappedPRK         []byte    `json:"wrapped_prk"`
-	WrapNonce          []byte    `json:"wrap_nonce"`
-	EphemeralPublicKey []byte    `json:"ephemeral_public_key"`
+	ProjectName        string     `json:"project_name"`
+	AdminId            uuid.UUID  `json:"admin_id"`
+	UserId             uuid.UUID  `json:"user_id"`
+	WrappedPRK         []byte     `json:"wrapped_prk"`
+	WrapNonce          []byte     `json:"wrap_nonce"`
+	EphemeralPublicKey []byte     `json:"ephemeral_public_key"`
+	ExpiresAt          *time.Time `json:"expires_at"`
 }
 type AddUserToProjectResponse struct {
 	Message string `json:"message"`
@@ -58,6 +68,18 @@ type SetAccessResponse struct {
 	Message string `json:"message"`
 }
 
+// RenewMembershipRequest POST /projects/access/renew
+type RenewMembershipRequest struct {
+	ProjectID uuid.UUID  `json:"project_id"`
+	AdminID   uuid.UUID  `json:"admin_id"`
+	UserID    uuid.UUID  `json:"user_id"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+type RenewMembershipResponse struct {
+	Message   string     `json:"message"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
 type GetUserProjectRequest struct {
 	ProjectName string    `json:"project_name"`
 	UserId      uuid.UUID `json:"user_id"`
@@ -110,6 +132,7 @@ type RotateInitResponse struct {
 	WrappedDEKs      []WrappedDEK      `json:"wrapped_deks"`
 	MemberPublicKeys []MemberPublicKey `json:"member_public_keys"`
 	PRKVersion       int32             `json:"prk_version"`
+	RotationRequired bool              `json:"rotation_required"`
 }
 
 type NewWrappedDEK struct {
@@ -119,14 +142,13 @@ type NewWrappedDEK struct {
 }
 
 type RotateCommitRequest struct {
-	ProjectID          uuid.UUID     `json:"project_id"`
-	UserID             uuid.UUID     `json:"user_id"`
-	ExpectedPRKVersion int32         `json:"expected_prk_version"`
-	NewWrappedPRKs     []WrappedKey  `json:"new_wrapped_prks"`
+	ProjectID          uuid.UUID       `json:"project_id"`
+	UserID             uuid.UUID       `json:"user_id"`
+	ExpectedPRKVersion int32           `json:"expected_prk_version"`
+	NewWrappedPRKs     []WrappedKey    `json:"new_wrapped_prks"`
 	NewWrappedDEKs     []NewWrappedDEK `json:"new_wrapped_deks"`
 }
 
 type RotateCommitResponse struct {
 	NewPRKVersion int32 `json:"new_prk_version"`
 }
-
@@ -65,6 +65,7 @@ type ServiceRolePermsResponse struct {
 	ProjectID   uuid.UUID `json:"project_id"`
 	ProjectName string    `json:"project_name"`
 	Env         string    `json:"env"`
+	PinnedLabel *string   `json:"pinned_label,omitempty"`
 }
 
 // ServiceRoleDelegateRequest POST /service_role/delegate
@@ -78,8 +79,24 @@ type ServiceRoleDelegateRequest struct {
 	WrapNonce          []byte `json:"wrap_nonce"`
 	EphemeralPublicKey []byte `json:"ephemeral_public_key"`
 
+	// PinnedLabel makes the service role fetch the labelled version instead of the latest.
+	PinnedLabel *string `json:"pinned_label"`
+
 	DelegatedBy uuid.UUID `json:"delegated_by"`
 }
 type ServiceRoleDelegateResponse struct {
 	Message string `json:"message"`
 }
+
+// ServiceRolePinRequest POST /service_role/pin
+type ServiceRolePinRequest struct {
+	RepoPrincipal string    `json:"repo_principal"`
+	ProjectId     uuid.UUID `json:"project_id"`
+	EnvName       string    `json:"env_name"`
+	// Label pins the delegation; null makes it follow the latest version again.
+	Label   *string   `json:"label"`
+	AdminID uuid.UUID `json:"admin_id"`
+}
+type ServiceRolePinResponse struct {
+	Message string `json:"message"`
+}
@@ -0,0 +1,55 @@
+package config
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// ShareCreateRequest POST /shares/create
+//
+// The client encrypts the payload under a random key it keeps in the link's
+// URL fragment; only the ciphertext reaches the server.
+type ShareCreateRequest struct {
+	UserID     uuid.UUID `json:"user_id"`
+	Ciphertext []byte    `json:"ciphertext"`
+	Nonce      []byte    `json:"nonce"`
+	// MaxViews is how many times the share can be opened, 1 when unset.
+	MaxViews *int32 `json:"max_views,omitempty"`
+	// ExpiresInMinutes is how long the share lives, a day when unset.
+	ExpiresInMinutes *int32 `json:"expires_in_minutes,omitempty"`
+}
+
+type ShareCreateResponse struct {
+	Message string `json:"message"`
+	Share   Share  `json:"share"`
+}
+
+type Share struct {
+	ID        uuid.UUID `json:"id"`
+	MaxViews  int32     `json:"max_views"`
+	Views     int32     `json:"views"`
+	ExpiresAt time.Time `json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ShareViewRequest POST /shares/view
+type ShareViewRequest struct {
+	ID uuid.UUID `json:"id"`
+}
+
+type ShareViewResponse struct {
+	Ciphertext []byte    `json:"ciphertext"`
+	Nonce      []byte    `json:"nonce"`
+	Remaining  int32     `json:"remaining_views"`
+	ExpiresAt  time.Time `json:"expires_at"`
+}
+
+// ShareRevokeRequest POST /shares/revoke
+type ShareRevokeRequest struct {
+	UserID uuid.UUID `json:"user_id"`
+	ID     uuid.UUID `json:"id"`
+}
+type ShareRevokeResponse struct {
+	Message string `json:"message"`
+}
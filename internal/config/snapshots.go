@@ -30,6 +30,8 @@ type SnapshotEnvVersion struct {
 	CreatedAt         time.Time `json:"created_at"`
 	CreatedBy         uuid.UUID `json:"created_by"`
 	Metadata          []byte    `json:"metadata"`
+
+	Entries []EnvEntry `json:"entries,omitempty"`
 }
 
 type Snapshot struct {
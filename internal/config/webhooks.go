@@ -0,0 +1,134 @@
+package config
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// Webhook delivery states.
+const (
+	WebhookDeliveryPending   = "pending"
+	WebhookDeliveryDelivered = "delivered"
+	WebhookDeliveryDead      = "dead"
+)
+
+// WebhookActions are the audit actions a webhook can subscribe to. Reads and
+// session actions are left out, they are neither project changes nor rare.
+var WebhookActions = map[string]bool{
+	ActionEnvPush:              true,
+	ActionEnvRollback:          true,
+	ActionEnvPromote:           true,
+	ActionEnvProtect:           true,
+	ActionEnvCandidateApprove:  true,
+	ActionEnvCandidateReject:   true,
+	ActionEnvLabelSet:          true,
+	ActionEnvLabelDelete:       true,
+	ActionEnvShred:             true,
+	ActionEnvSecretExpiring:    true,
+	ActionEnvSecretOverdue:     true,
+	ActionEnvSchemaSet:         true,
+	ActionPRKRotate:            true,
+	ActionMembershipChange:     true,
+	ActionServiceRoleDelegate:  true,
+	ActionServiceRolePin:       true,
+	ActionAccessRequestApprove: true,
+	ActionBreakGlassFulfill:    true,
+	ActionBreakGlassRevoke:     true,
+	ActionEnvironmentCreate:    true,
+	ActionEnvironmentRename:    true,
+	ActionEnvironmentArchive:   true,
+	ActionEnvironmentRestore:   true,
+	ActionEnvironmentDelete:    true,
+	ActionEnvironmentSettings:  true,
+	ActionEnvironmentLock:      true,
+	ActionEnvironmentUnlock:    true,
+}
+
+type Webhook struct {
+	ID        uuid.UUID `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedBy uuid.UUID `json:"created_by"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// WebhookCreateRequest POST /projects/webhooks/create
+type WebhookCreateRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+}
+
+// WebhookCreateResponse carries the signing secret. It is only ever returned here.
+type WebhookCreateResponse struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookListRequest POST /projects/webhooks/list
+type WebhookListRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+}
+type WebhookListResponse struct {
+	Webhooks []Webhook `json:"webhooks"`
+}
+
+// WebhookDeleteRequest POST /projects/webhooks/delete
+type WebhookDeleteRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	WebhookID uuid.UUID `json:"webhook_id"`
+}
+type WebhookDeleteResponse struct {
+	Message string `json:"message"`
+}
+
+// WebhookPayload is the signed body POSTed to subscribers. It describes the
+// change only; ciphertext and key material are never included.
+type WebhookPayload struct {
+	ID          uuid.UUID       `json:"id"`
+	Action      string          `json:"action"`
+	Timestamp   time.Time       `json:"timestamp"`
+	ProjectID   uuid.UUID       `json:"project_id"`
+	Environment *string         `json:"environment,omitempty"`
+	ActorType   string          `json:"actor_type"`
+	ActorID     string          `json:"actor_id"`
+	TargetID    *string         `json:"target_id,omitempty"`
+	Metadata    json.RawMessage `json:"metadata,omitempty"`
+}
+
+type WebhookDelivery struct {
+	ID             uuid.UUID  `json:"id"`
+	WebhookID      uuid.UUID  `json:"webhook_id"`
+	Action         string     `json:"action"`
+	Status         string     `json:"status"`
+	Attempts       int32      `json:"attempts"`
+	NextAttemptAt  *time.Time `json:"next_attempt_at,omitempty"`
+	LastStatusCode *int32     `json:"last_status_code,omitempty"`
+	LastError      *string    `json:"last_error,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+	DeliveredAt    *time.Time `json:"delivered_at,omitempty"`
+}
+
+// WebhookDeliveriesRequest POST /projects/webhooks/deliveries
+type WebhookDeliveriesRequest struct {
+	ProjectID uuid.UUID `json:"project_id"`
+	UserID    uuid.UUID `json:"user_id"`
+	WebhookID uuid.UUID `json:"webhook_id"`
+	Status    *string   `json:"status,omitempty"`
+	Limit     int32     `json:"limit"`
+}
+type WebhookDeliveriesResponse struct {
+	Deliveries []WebhookDelivery `json:"deliveries"`
+}
+
+// WebhookRedeliverRequest POST /projects/webhooks/redeliver
+type WebhookRedeliverRequest struct {
+	ProjectID  uuid.UUID `json:"project_id"`
+	UserID     uuid.UUID `json:"user_id"`
+	DeliveryID uuid.UUID `json:"delivery_id"`
+}
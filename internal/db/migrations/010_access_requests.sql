@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE access_requests (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+
+    env_name TEXT NOT NULL,
+    reason TEXT NOT NULL,
+
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled', 'expired')),
+
+    decided_by UUID NULL REFERENCES users(id) ON DELETE SET NULL,
+    decided_at TIMESTAMP NULL,
+    decision_note TEXT NULL,
+
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    expires_at TIMESTAMP NOT NULL DEFAULT (NOW() + INTERVAL '7 days')
+);
+
+CREATE INDEX idx_access_requests_project
+    ON access_requests(project_id, status);
+
+CREATE UNIQUE INDEX idx_access_requests_one_pending
+    ON access_requests(project_id, user_id)
+    WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE access_requests;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE protected_environments (
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    env_name TEXT NOT NULL,
+
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (project_id, env_name)
+);
+
+-- pushes to a protected environment land as pending candidates until a second admin reviews them
+ALTER TABLE env_versions ADD COLUMN status TEXT NOT NULL DEFAULT 'published'
+    CHECK (status IN ('pending', 'published', 'rejected', 'expired'));
+ALTER TABLE env_versions ADD COLUMN reviewed_by UUID NULL REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE env_versions ADD COLUMN reviewed_at TIMESTAMP NULL;
+ALTER TABLE env_versions ADD COLUMN review_note TEXT NULL;
+ALTER TABLE env_versions ADD COLUMN candidate_expires_at TIMESTAMP NULL;
+
+CREATE INDEX idx_env_versions_status
+    ON env_versions(project_id, env_name, status, version DESC);
+
+CREATE INDEX idx_env_versions_pending_expiry
+    ON env_versions(candidate_expires_at)
+    WHERE status = 'pending';
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_env_versions_pending_expiry;
+DROP INDEX IF EXISTS idx_env_versions_status;
+ALTER TABLE env_versions DROP COLUMN candidate_expires_at;
+ALTER TABLE env_versions DROP COLUMN review_note;
+ALTER TABLE env_versions DROP COLUMN reviewed_at;
+ALTER TABLE env_versions DROP COLUMN reviewed_by;
+ALTER TABLE env_versions DROP COLUMN status;
+DROP TABLE protected_environments;
@@ -0,0 +1,59 @@
+-- +goose Up
+CREATE TABLE environments (
+    id UUID PRIMARY KEY DEFAULT gen_random_uuid(),
+
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    protected BOOLEAN NOT NULL DEFAULT false,
+
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    archived_at TIMESTAMP NULL,
+
+    UNIQUE (project_id, name)
+);
+
+-- when enabled, pushing or delegating to an unknown environment registers it instead of failing
+ALTER TABLE projects ADD COLUMN auto_create_environments BOOLEAN NOT NULL DEFAULT false;
+
+-- register every environment that already exists implicitly
+INSERT INTO environments (project_id, name, created_by, created_at)
+SELECT ev.project_id, ev.env_name, p.created_by, MIN(ev.created_at)
+FROM env_versions ev
+         JOIN projects p ON p.id = ev.project_id
+GROUP BY ev.project_id, ev.env_name, p.created_by;
+
+INSERT INTO environments (project_id, name, created_by, created_at)
+SELECT d.project_id, d.env, p.created_by, MIN(d.created_at)
+FROM service_delegations d
+         JOIN projects p ON p.id = d.project_id
+GROUP BY d.project_id, d.env, p.created_by
+ON CONFLICT (project_id, name) DO NOTHING;
+
+INSERT INTO environments (project_id, name, protected, created_by, created_at)
+SELECT project_id, env_name, true, created_by, created_at
+FROM protected_environments
+ON CONFLICT (project_id, name) DO UPDATE SET protected = true;
+
+DROP TABLE protected_environments;
+
+-- +goose Down
+CREATE TABLE protected_environments (
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    env_name TEXT NOT NULL,
+
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (project_id, env_name)
+);
+
+INSERT INTO protected_environments (project_id, env_name, created_by, created_at)
+SELECT project_id, name, created_by, created_at
+FROM environments
+WHERE protected = true;
+
+ALTER TABLE projects DROP COLUMN auto_create_environments;
+DROP TABLE environments;
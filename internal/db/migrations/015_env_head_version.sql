@@ -0,0 +1,18 @@
+-- +goose Up
+-- head_version is the last version number handed out for the environment; pushes
+-- bump it under the row lock so concurrent writers never pick the same number
+ALTER TABLE environments ADD COLUMN head_version INTEGER NOT NULL DEFAULT 0;
+
+UPDATE environments
+SET head_version = COALESCE((
+    SELECT MAX(ev.version)
+    FROM env_versions ev
+    WHERE ev.project_id = environments.project_id AND ev.env_name = environments.name
+), 0);
+
+-- published version the push was based on
+ALTER TABLE env_versions ADD COLUMN parent_version INTEGER NULL;
+
+-- +goose Down
+ALTER TABLE env_versions DROP COLUMN parent_version;
+ALTER TABLE environments DROP COLUMN head_version;
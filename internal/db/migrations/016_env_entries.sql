@@ -0,0 +1,26 @@
+-- +goose Up
+-- Per-key entries for versions with encryption_version 3. Every version holds
+-- its full set of entries, unchanged ones are copied from the parent version,
+-- so a single version can be read without walking history. key_hmac blinds the
+-- key name with an HMAC keyed from the PRK; the name itself is only stored
+-- encrypted under the version DEK.
+CREATE TABLE env_entries (
+    env_version_id UUID NOT NULL REFERENCES env_versions(id) ON DELETE CASCADE,
+    key_hmac BYTEA NOT NULL,
+
+    name_ciphertext BYTEA NOT NULL,
+    name_nonce BYTEA NOT NULL,
+    value_ciphertext BYTEA NOT NULL,
+    value_nonce BYTEA NOT NULL,
+
+    -- version in which this entry was last written
+    changed_in_version INTEGER NOT NULL,
+
+    PRIMARY KEY (env_version_id, key_hmac)
+);
+
+CREATE INDEX idx_env_entries_key_hmac ON env_entries(key_hmac);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_env_entries_key_hmac;
+DROP TABLE IF EXISTS env_entries;
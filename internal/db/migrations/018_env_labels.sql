@@ -0,0 +1,21 @@
+-- +goose Up
+-- movable names for published versions, e.g. stable or release-2024-10
+CREATE TABLE env_labels (
+    id UUID PRIMARY KEY,
+    environment_id UUID NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    version INTEGER NOT NULL,
+
+    updated_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    UNIQUE (environment_id, name)
+);
+
+-- a delegation pinned to a label only ever sees the version the label points at
+ALTER TABLE service_delegations ADD COLUMN pinned_label TEXT NULL;
+
+-- +goose Down
+ALTER TABLE service_delegations DROP COLUMN pinned_label;
+DROP TABLE IF EXISTS env_labels;
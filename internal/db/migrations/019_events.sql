@@ -0,0 +1,19 @@
+-- +goose Up
+-- append-only change feed read by /events/stream; ids order delivery and let clients resume
+CREATE TABLE events (
+    id BIGSERIAL PRIMARY KEY,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    env_name TEXT NULL,
+    type TEXT NOT NULL,
+
+    -- the user an access event is about, who may no longer be a member
+    subject_user_id UUID NULL REFERENCES users(id) ON DELETE CASCADE,
+    payload JSONB NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_events_project_id ON events(project_id, id);
+CREATE INDEX idx_events_created_at ON events(created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS events;
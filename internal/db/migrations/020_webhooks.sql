@@ -0,0 +1,41 @@
+-- +goose Up
+CREATE TABLE webhooks (
+    id UUID PRIMARY KEY,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    url TEXT NOT NULL,
+    -- HMAC key for the signature header, handed to the creator once and
+    -- stored encrypted under the server's WEBHOOK_KEY
+    secret_ciphertext BYTEA NOT NULL,
+    secret_nonce BYTEA NOT NULL,
+    -- audit actions the webhook is subscribed to
+    events JSONB NOT NULL,
+
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_webhooks_project_id ON webhooks(project_id);
+
+-- delivery queue and log; rows stay after delivery so they can be inspected and redelivered
+CREATE TABLE webhook_deliveries (
+    id UUID PRIMARY KEY,
+    webhook_id UUID NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+    action TEXT NOT NULL,
+    payload JSONB NOT NULL,
+
+    status TEXT NOT NULL DEFAULT 'pending', -- pending | delivered | dead
+    attempts INTEGER NOT NULL DEFAULT 0,
+    next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    last_status_code INTEGER NULL,
+    last_error TEXT NULL,
+
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+    delivered_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_webhook_deliveries_due ON webhook_deliveries(status, next_attempt_at);
+CREATE INDEX idx_webhook_deliveries_webhook_id ON webhook_deliveries(webhook_id, created_at DESC);
+
+-- +goose Down
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- retention policy: keep the newest N versions and/or versions younger than D days.
+-- an environment with either column set overrides the project policy as a whole
+ALTER TABLE projects ADD COLUMN retention_keep_versions INTEGER NULL;
+ALTER TABLE projects ADD COLUMN retention_keep_days INTEGER NULL;
+ALTER TABLE environments ADD COLUMN retention_keep_versions INTEGER NULL;
+ALTER TABLE environments ADD COLUMN retention_keep_days INTEGER NULL;
+
+-- a shredded version keeps its row and metadata, but its ciphertext, entries and wrapped DEK are gone
+ALTER TABLE env_versions ADD COLUMN shredded_at TIMESTAMP NULL;
+ALTER TABLE env_versions ADD COLUMN shredded_by UUID NULL REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE env_versions DROP COLUMN shredded_by;
+ALTER TABLE env_versions DROP COLUMN shredded_at;
+ALTER TABLE environments DROP COLUMN retention_keep_days;
+ALTER TABLE environments DROP COLUMN retention_keep_versions;
+ALTER TABLE projects DROP COLUMN retention_keep_days;
+ALTER TABLE projects DROP COLUMN retention_keep_versions;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- an environment inherits every variable of its parent chain; readers merge
+-- the chain root first, so the child's own values win
+ALTER TABLE environments ADD COLUMN parent_id UUID NULL REFERENCES environments(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_environments_parent ON environments(parent_id);
+
+-- the parent versions a child version was pushed on top of, root first, so a
+-- pinned read returns the layers the version was written against. Parent
+-- versions are not foreign keys: a reader reports a layer that is gone.
+CREATE TABLE env_version_parents (
+    env_version_id UUID NOT NULL REFERENCES env_versions(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    parent_version_id UUID NOT NULL,
+    PRIMARY KEY (env_version_id, position)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS env_version_parents;
+DROP INDEX IF EXISTS idx_environments_parent;
+ALTER TABLE environments DROP COLUMN parent_id;
@@ -0,0 +1,20 @@
+-- +goose Up
+-- the version each consumer (a service, a CI job, a developer machine) last pulled
+CREATE TABLE env_consumers (
+    id UUID PRIMARY KEY,
+    environment_id UUID NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    consumer TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    -- the label the pull resolved through, drift is then measured against it
+    label TEXT NULL,
+    -- ids of the parent versions merged into an unpinned pull, root first
+    parent_versions JSONB NOT NULL DEFAULT '[]',
+
+    pulled_by TEXT NOT NULL,
+    pulled_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    UNIQUE (environment_id, consumer)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS env_consumers;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- expiry dates pushed with a version, for one blinded key or, without key_hmac,
+-- for the whole version. Only the latest published version of an environment
+-- is checked; pushing a new version replaces its expiries
+CREATE TABLE env_expiries (
+    id UUID PRIMARY KEY,
+    env_version_id UUID NOT NULL REFERENCES env_versions(id) ON DELETE CASCADE,
+    key_hmac BYTEA NULL,
+    expires_at TIMESTAMP NOT NULL,
+
+    reminded_at TIMESTAMP NULL,
+    overdue_notified_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_env_expiries_expires_at ON env_expiries(expires_at);
+CREATE INDEX idx_env_expiries_version ON env_expiries(env_version_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_env_expiries_version;
+DROP INDEX IF EXISTS idx_env_expiries_expires_at;
+DROP TABLE IF EXISTS env_expiries;
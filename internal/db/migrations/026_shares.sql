@@ -0,0 +1,21 @@
+-- +goose Up
+-- one-time share links. The payload is encrypted by the client under a key
+-- that only lives in the link's URL fragment, so the server never sees it
+CREATE TABLE shares (
+    id UUID PRIMARY KEY,
+    created_by UUID NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ciphertext BYTEA NOT NULL,
+    nonce BYTEA NOT NULL,
+
+    max_views INTEGER NOT NULL,
+    views INTEGER NOT NULL DEFAULT 0,
+
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_shares_expires_at ON shares(expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_shares_expires_at;
+DROP TABLE IF EXISTS shares;
@@ -0,0 +1,21 @@
+-- +goose Up
+-- entries or whole environments of other projects an environment pulls in,
+-- so shared credentials live in one place. key_hmac selects one entry of the
+-- referenced environment; without it the whole environment is referenced
+CREATE TABLE env_references (
+    id UUID PRIMARY KEY,
+    environment_id UUID NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    ref_environment_id UUID NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    key_hmac BYTEA NULL,
+
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
+
+CREATE UNIQUE INDEX idx_env_references_unique ON env_references(environment_id, ref_environment_id, COALESCE(key_hmac, ''::BYTEA));
+CREATE INDEX idx_env_references_ref ON env_references(ref_environment_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_env_references_ref;
+DROP INDEX IF EXISTS idx_env_references_unique;
+DROP TABLE IF EXISTS env_references;
@@ -0,0 +1,17 @@
+-- +goose Up
+-- optional plaintext schema of a project's environment variables. Every change
+-- adds a version, the highest one is current
+CREATE TABLE env_schemas (
+    id UUID PRIMARY KEY,
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    version INTEGER NOT NULL,
+    schema JSONB NOT NULL,
+
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    UNIQUE (project_id, version)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS env_schemas;
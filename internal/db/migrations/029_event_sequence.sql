@@ -0,0 +1,15 @@
+-- +goose Up
+-- Event ids are taken from this row instead of the events sequence. The row
+-- stays locked until the recording transaction commits, so ids become visible
+-- in commit order and a stream resuming after an id never skips an event that
+-- committed late.
+CREATE TABLE event_sequence (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_id BIGINT NOT NULL
+);
+
+INSERT INTO event_sequence (id, last_id)
+SELECT 1, COALESCE(MAX(id), 0) FROM events;
+
+-- +goose Down
+DROP TABLE IF EXISTS event_sequence;
@@ -0,0 +1,19 @@
+-- +goose Up
+-- Audit entries keep the environment name they were written under and also
+-- the environment's id, so a renamed environment is found by id when the log
+-- is read instead of rewriting history. No foreign key: entries outlive a
+-- deleted environment.
+ALTER TABLE audit_logs ADD COLUMN environment_id UUID NULL;
+
+UPDATE audit_logs
+SET environment_id = (
+    SELECT e.id FROM environments e
+    WHERE e.project_id = audit_logs.project_id AND e.name = audit_logs.environment
+)
+WHERE environment IS NOT NULL;
+
+CREATE INDEX idx_audit_logs_environment_id ON audit_logs(environment_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_audit_logs_environment_id;
+ALTER TABLE audit_logs DROP COLUMN IF EXISTS environment_id;
@@ -0,0 +1,23 @@
+-- +goose Up
+-- A database that ran 013 but a copy of 014 without the protection move
+-- still holds protected_environments. Move what is left onto environments
+-- and drop it; everywhere else the table is created empty and dropped again.
+CREATE TABLE IF NOT EXISTS protected_environments (
+    project_id UUID NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    env_name TEXT NOT NULL,
+
+    created_by UUID NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+
+    PRIMARY KEY (project_id, env_name)
+);
+
+INSERT INTO environments (project_id, name, protected, created_by, created_at)
+SELECT project_id, env_name, true, created_by, created_at
+FROM protected_environments
+ON CONFLICT (project_id, name) DO UPDATE SET protected = true;
+
+DROP TABLE protected_environments;
+
+-- +goose Down
+-- protection stays on environments, which 014's down migration moves back
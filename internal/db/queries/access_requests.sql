@@ -0,0 +1,64 @@
+-- name: CreateAccessRequest :one
+INSERT INTO access_requests (
+    id,
+    project_id,
+    user_id,
+    env_name,
+    reason,
+    expires_at
+)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetAccessRequest :one
+SELECT * FROM access_requests WHERE id = $1;
+
+-- name: ListAccessRequests :many
+SELECT
+    ar.id,
+    ar.project_id,
+    ar.user_id,
+    u.email AS user_email,
+    u.user_public_key,
+    ar.env_name,
+    ar.reason,
+    ar.status,
+    ar.decided_by,
+    ar.decided_at,
+    ar.decision_note,
+    ar.created_at,
+    ar.expires_at
+FROM access_requests ar
+         JOIN users u ON u.id = ar.user_id
+WHERE ar.project_id = $1
+  AND (sqlc.narg('status') IS NULL OR ar.status = sqlc.narg('status'))
+ORDER BY ar.created_at DESC;
+
+-- name: ListUserAccessRequests :many
+SELECT * FROM access_requests
+WHERE user_id = $1
+ORDER BY created_at DESC;
+
+-- name: DecideAccessRequest :one
+UPDATE access_requests
+SET status = $2,
+    decided_by = $3,
+    decided_at = CURRENT_TIMESTAMP,
+    decision_note = $4
+WHERE id = $1
+  AND status = 'pending'
+  AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: CancelAccessRequest :one
+UPDATE access_requests
+SET status = 'cancelled',
+    decided_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND user_id = $2 AND status = 'pending'
+RETURNING *;
+
+-- name: ExpireAccessRequests :many
+UPDATE access_requests
+SET status = 'expired'
+WHERE status = 'pending' AND expires_at <= CURRENT_TIMESTAMP
+RETURNING *;
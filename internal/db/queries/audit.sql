@@ -14,29 +14,46 @@ INSERT INTO audit_logs (
     user_agent,
     status,
     error_message,
-    metadata
+    metadata,
+    severity,
+    environment_id
 ) VALUES (
-    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16,
+    (SELECT id FROM environments WHERE project_id = $8 AND name = $9)
 );
 
 -- name: GetProjectAuditLogsPaginated :many
-SELECT *
-FROM audit_logs
-WHERE project_id = $1
-  AND (sqlc.narg('actor_email') IS NULL OR actor_email = sqlc.narg('actor_email'))
-  AND (sqlc.narg('action') IS NULL OR action = sqlc.narg('action'))
-  AND (sqlc.narg('status') IS NULL OR status = sqlc.narg('status'))
-  AND (sqlc.narg('from_time') IS NULL OR timestamp >= sqlc.narg('from_time'))
-  AND (sqlc.narg('to_time') IS NULL OR timestamp <= sqlc.narg('to_time'))
-ORDER BY timestamp DESC
+-- The environment filter follows the environment by id, so entries written
+-- under an earlier name show up; entries of deleted environments match by the
+-- name they were written under.
+SELECT a.*, e.name AS current_environment
+FROM audit_logs a
+LEFT JOIN environments e ON e.id = a.environment_id
+WHERE a.project_id = $1
+  AND (sqlc.narg('actor_email') IS NULL OR a.actor_email = sqlc.narg('actor_email'))
+  AND (sqlc.narg('action') IS NULL OR a.action = sqlc.narg('action'))
+  AND (sqlc.narg('status') IS NULL OR a.status = sqlc.narg('status'))
+  AND (sqlc.narg('severity') IS NULL OR a.severity = sqlc.narg('severity'))
+  AND (sqlc.narg('from_time') IS NULL OR a.timestamp >= sqlc.narg('from_time'))
+  AND (sqlc.narg('to_time') IS NULL OR a.timestamp <= sqlc.narg('to_time'))
+  AND (sqlc.narg('environment') IS NULL
+       OR a.environment_id = (SELECT id FROM environments WHERE project_id = $1 AND name = sqlc.narg('environment'))
+       OR (a.environment = sqlc.narg('environment')
+           AND NOT EXISTS (SELECT 1 FROM environments WHERE id = a.environment_id)))
+ORDER BY a.timestamp DESC
 LIMIT sqlc.arg('limit_val') OFFSET sqlc.arg('offset_val');
 
 -- name: CountProjectAuditLogs :one
 SELECT COUNT(*)
-FROM audit_logs
-WHERE project_id = $1
-  AND (sqlc.narg('actor_email') IS NULL OR actor_email = sqlc.narg('actor_email'))
-  AND (sqlc.narg('action') IS NULL OR action = sqlc.narg('action'))
-  AND (sqlc.narg('status') IS NULL OR status = sqlc.narg('status'))
-  AND (sqlc.narg('from_time') IS NULL OR timestamp >= sqlc.narg('from_time'))
-  AND (sqlc.narg('to_time') IS NULL OR timestamp <= sqlc.narg('to_time'));
+FROM audit_logs a
+WHERE a.project_id = $1
+  AND (sqlc.narg('actor_email') IS NULL OR a.actor_email = sqlc.narg('actor_email'))
+  AND (sqlc.narg('action') IS NULL OR a.action = sqlc.narg('action'))
+  AND (sqlc.narg('status') IS NULL OR a.status = sqlc.narg('status'))
+  AND (sqlc.narg('severity') IS NULL OR a.severity = sqlc.narg('severity'))
+  AND (sqlc.narg('from_time') IS NULL OR a.timestamp >= sqlc.narg('from_time'))
+  AND (sqlc.narg('to_time') IS NULL OR a.timestamp <= sqlc.narg('to_time'))
+  AND (sqlc.narg('environment') IS NULL
+       OR a.environment_id = (SELECT id FROM environments WHERE project_id = $1 AND name = sqlc.narg('environment'))
+       OR (a.environment = sqlc.narg('environment')
+           AND NOT EXISTS (SELECT 1 FROM environments WHERE id = a.environment_id)));
@@ -0,0 +1,99 @@
+-- name: UpsertBreakGlassPolicy :one
+INSERT INTO break_glass_policies (
+    project_id,
+    escrow_user_id,
+    window_minutes,
+    updated_by
+)
+VALUES ($1, $2, $3, $4)
+ON CONFLICT (project_id) DO UPDATE
+SET escrow_user_id = EXCLUDED.escrow_user_id,
+    window_minutes = EXCLUDED.window_minutes,
+    updated_by = EXCLUDED.updated_by,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: GetBreakGlassPolicy :one
+SELECT * FROM break_glass_policies WHERE project_id = $1;
+
+-- name: AddBreakGlassEligibleUser :exec
+INSERT INTO break_glass_eligible_users (project_id, user_id, added_by)
+VALUES ($1, $2, $3)
+ON CONFLICT (project_id, user_id) DO NOTHING;
+
+-- name: RemoveBreakGlassEligibleUser :execrows
+DELETE FROM break_glass_eligible_users WHERE project_id = $1 AND user_id = $2;
+
+-- name: IsBreakGlassEligible :one
+SELECT user_id FROM break_glass_eligible_users WHERE project_id = $1 AND user_id = $2;
+
+-- name: ListBreakGlassEligibleUsers :many
+SELECT
+    e.user_id,
+    u.email,
+    e.added_by,
+    e.added_at
+FROM break_glass_eligible_users e
+         JOIN users u ON u.id = e.user_id
+WHERE e.project_id = $1
+ORDER BY e.added_at;
+
+-- name: CreateBreakGlassGrant :one
+INSERT INTO break_glass_grants (
+    id,
+    project_id,
+    user_id,
+    env_name,
+    justification,
+    window_minutes,
+    expires_at
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: GetBreakGlassGrant :one
+SELECT * FROM break_glass_grants WHERE id = $1;
+
+-- name: ListPendingBreakGlassGrants :many
+SELECT
+    g.id,
+    g.project_id,
+    g.user_id,
+    u.email AS user_email,
+    u.user_public_key,
+    g.env_name,
+    g.justification,
+    g.window_minutes,
+    g.created_at,
+    g.expires_at
+FROM break_glass_grants g
+         JOIN users u ON u.id = g.user_id
+WHERE g.project_id = $1
+  AND g.status = 'pending'
+  AND g.expires_at > CURRENT_TIMESTAMP
+ORDER BY g.created_at;
+
+-- name: ActivateBreakGlassGrant :one
+UPDATE break_glass_grants
+SET status = 'active',
+    fulfilled_by = $2,
+    fulfilled_at = CURRENT_TIMESTAMP,
+    expires_at = $3
+WHERE id = $1
+  AND status = 'pending'
+  AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: RevokeBreakGlassGrant :one
+UPDATE break_glass_grants
+SET status = 'revoked',
+    revoked_by = $2
+WHERE id = $1 AND status IN ('pending', 'active')
+RETURNING *;
+
+-- name: ExpireBreakGlassGrants :many
+UPDATE break_glass_grants
+SET status = 'expired'
+WHERE status IN ('pending', 'active')
+  AND expires_at <= CURRENT_TIMESTAMP
+RETURNING *;
@@ -0,0 +1,50 @@
+-- name: GetEnvCandidate :one
+SELECT * FROM env_versions
+WHERE project_id = $1 AND env_name = $2 AND version = $3;
+
+-- name: ListEnvCandidates :many
+SELECT
+    ev.id,
+    ev.env_name,
+    ev.version,
+    ev.status,
+    ev.metadata,
+    ev.parent_version,
+    ev.created_by,
+    u.email AS created_by_email,
+    ev.created_at,
+    ev.candidate_expires_at
+FROM env_versions ev
+         JOIN users u ON u.id = ev.created_by
+WHERE ev.project_id = $1
+  AND ev.status = 'pending'
+  AND (sqlc.narg('env_name') IS NULL OR ev.env_name = sqlc.narg('env_name'))
+ORDER BY ev.env_name, ev.version;
+
+-- name: ApproveEnvCandidate :one
+UPDATE env_versions
+SET status = 'published',
+    reviewed_by = $2,
+    reviewed_at = CURRENT_TIMESTAMP,
+    review_note = $3
+WHERE id = $1
+  AND status = 'pending'
+  AND created_by <> $4
+  AND candidate_expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: RejectEnvCandidate :one
+UPDATE env_versions
+SET status = 'rejected',
+    reviewed_by = $2,
+    reviewed_at = CURRENT_TIMESTAMP,
+    review_note = $3
+WHERE id = $1 AND status = 'pending'
+RETURNING *;
+
+-- name: ExpireEnvCandidates :many
+UPDATE env_versions
+SET status = 'expired'
+WHERE status = 'pending'
+  AND candidate_expires_at <= CURRENT_TIMESTAMP
+RETURNING id, project_id, env_name, version, created_by;
@@ -0,0 +1,25 @@
+-- name: UpsertEnvConsumer :exec
+INSERT INTO env_consumers (id, environment_id, consumer, version, label, parent_versions, pulled_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (environment_id, consumer) DO UPDATE
+SET version = EXCLUDED.version,
+    label = EXCLUDED.label,
+    parent_versions = EXCLUDED.parent_versions,
+    pulled_by = EXCLUDED.pulled_by,
+    pulled_at = CURRENT_TIMESTAMP;
+
+-- name: ListEnvConsumers :many
+SELECT
+    c.environment_id,
+    e.name AS env_name,
+    c.consumer,
+    c.version,
+    c.label,
+    c.parent_versions,
+    c.pulled_by,
+    c.pulled_at
+FROM env_consumers c
+JOIN environments e ON e.id = c.environment_id
+WHERE e.project_id = sqlc.arg('project_id')
+  AND (sqlc.narg('env_name') IS NULL OR e.name = sqlc.narg('env_name'))
+ORDER BY e.name, c.consumer;
@@ -0,0 +1,60 @@
+-- name: ListEnvEntries :many
+SELECT * FROM env_entries WHERE env_version_id = $1 ORDER BY key_hmac;
+
+-- name: GetEnvEntry :one
+SELECT * FROM env_entries WHERE env_version_id = $1 AND key_hmac = $2;
+
+-- name: UpsertEnvEntry :exec
+INSERT INTO env_entries (
+    env_version_id,
+    key_hmac,
+    name_ciphertext,
+    name_nonce,
+    value_ciphertext,
+    value_nonce,
+    changed_in_version
+)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+ON CONFLICT (env_version_id, key_hmac) DO UPDATE
+SET name_ciphertext = EXCLUDED.name_ciphertext,
+    name_nonce = EXCLUDED.name_nonce,
+    value_ciphertext = EXCLUDED.value_ciphertext,
+    value_nonce = EXCLUDED.value_nonce,
+    changed_in_version = EXCLUDED.changed_in_version;
+
+-- name: DeleteEnvEntry :execrows
+DELETE FROM env_entries WHERE env_version_id = $1 AND key_hmac = $2;
+
+-- name: CopyEnvEntries :exec
+-- carries the parent's entries into a partial update, keeping when each was last written
+INSERT INTO env_entries (env_version_id, key_hmac, name_ciphertext, name_nonce, value_ciphertext, value_nonce, changed_in_version)
+SELECT sqlc.arg('target_version_id'), key_hmac, name_ciphertext, name_nonce, value_ciphertext, value_nonce, changed_in_version
+FROM env_entries
+WHERE env_version_id = sqlc.arg('source_version_id');
+
+-- name: CopyEnvEntriesAsWritten :exec
+-- used by rollback and promote, where every copied entry counts as written in the new version
+INSERT INTO env_entries (env_version_id, key_hmac, name_ciphertext, name_nonce, value_ciphertext, value_nonce, changed_in_version)
+SELECT sqlc.arg('target_version_id'), key_hmac, name_ciphertext, name_nonce, value_ciphertext, value_nonce, sqlc.arg('changed_in_version')
+FROM env_entries
+WHERE env_version_id = sqlc.arg('source_version_id');
+
+-- name: ListEnvEntryHistory :many
+SELECT
+    ev.version,
+    ev.wrapped_dek,
+    ev.dek_nonce,
+    ev.created_by,
+    ev.created_at,
+    e.name_ciphertext,
+    e.name_nonce,
+    e.value_ciphertext,
+    e.value_nonce
+FROM env_entries e
+JOIN env_versions ev ON ev.id = e.env_version_id
+WHERE ev.project_id = $1
+  AND ev.env_name = $2
+  AND e.key_hmac = $3
+  AND ev.status = 'published'
+  AND e.changed_in_version = ev.version
+ORDER BY ev.version DESC;
@@ -0,0 +1,76 @@
+-- name: AddEnvExpiry :exec
+INSERT INTO env_expiries (id, env_version_id, key_hmac, expires_at)
+VALUES ($1, $2, $3, $4);
+
+-- name: CarryEnvExpiry :exec
+-- copies an expiry into a partial update with its notice state, so reminders
+-- already sent for the key are not sent again
+INSERT INTO env_expiries (id, env_version_id, key_hmac, expires_at, reminded_at, overdue_notified_at)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: ListEnvVersionExpiries :many
+SELECT * FROM env_expiries WHERE env_version_id = $1;
+
+-- name: ListEnvExpiries :many
+SELECT x.id, ev.project_id, ev.env_name, ev.version, x.key_hmac, x.expires_at
+FROM env_expiries x
+JOIN env_versions ev ON ev.id = x.env_version_id
+WHERE ev.project_id = sqlc.arg('project_id')
+  AND x.expires_at <= sqlc.arg('before')
+  AND ev.status = 'published'
+  AND ev.version = (
+      SELECT MAX(latest.version) FROM env_versions latest
+      WHERE latest.project_id = ev.project_id AND latest.env_name = ev.env_name AND latest.status = 'published'
+  )
+ORDER BY x.expires_at, ev.env_name;
+
+-- name: ListOverdueEnvironmentNames :many
+SELECT DISTINCT ev.env_name
+FROM env_expiries x
+JOIN env_versions ev ON ev.id = x.env_version_id
+WHERE ev.project_id = sqlc.arg('project_id')
+  AND x.expires_at <= sqlc.arg('now')
+  AND ev.status = 'published'
+  AND ev.version = (
+      SELECT MAX(latest.version) FROM env_versions latest
+      WHERE latest.project_id = ev.project_id AND latest.env_name = ev.env_name AND latest.status = 'published'
+  );
+
+-- name: ListDueExpiryReminders :many
+SELECT x.id, ev.project_id, ev.env_name, ev.version, x.key_hmac, x.expires_at
+FROM env_expiries x
+JOIN env_versions ev ON ev.id = x.env_version_id
+WHERE x.reminded_at IS NULL
+  AND x.expires_at <= sqlc.arg('before')
+  AND ev.status = 'published'
+  AND ev.version = (
+      SELECT MAX(latest.version) FROM env_versions latest
+      WHERE latest.project_id = ev.project_id AND latest.env_name = ev.env_name AND latest.status = 'published'
+  )
+ORDER BY x.expires_at
+LIMIT sqlc.arg('limit_val');
+
+-- name: ListDueOverdueNotices :many
+SELECT x.id, ev.project_id, ev.env_name, ev.version, x.key_hmac, x.expires_at
+FROM env_expiries x
+JOIN env_versions ev ON ev.id = x.env_version_id
+WHERE x.overdue_notified_at IS NULL
+  AND x.expires_at <= sqlc.arg('now')
+  AND ev.status = 'published'
+  AND ev.version = (
+      SELECT MAX(latest.version) FROM env_versions latest
+      WHERE latest.project_id = ev.project_id AND latest.env_name = ev.env_name AND latest.status = 'published'
+  )
+ORDER BY x.expires_at
+LIMIT sqlc.arg('limit_val');
+
+-- name: MarkExpiryReminded :execrows
+UPDATE env_expiries
+SET reminded_at = CURRENT_TIMESTAMP
+WHERE id = $1 AND reminded_at IS NULL;
+
+-- name: MarkExpiryOverdueNotified :execrows
+UPDATE env_expiries
+SET overdue_notified_at = CURRENT_TIMESTAMP,
+    reminded_at = COALESCE(reminded_at, CURRENT_TIMESTAMP)
+WHERE id = $1 AND overdue_notified_at IS NULL;
@@ -0,0 +1,32 @@
+-- name: UpsertEnvLabel :one
+INSERT INTO env_labels (id, environment_id, name, version, updated_by)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (environment_id, name) DO UPDATE
+SET version = EXCLUDED.version,
+    updated_by = EXCLUDED.updated_by,
+    updated_at = CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: GetEnvLabel :one
+SELECT * FROM env_labels WHERE environment_id = $1 AND name = $2;
+
+-- name: ListEnvLabels :many
+SELECT * FROM env_labels WHERE environment_id = $1 ORDER BY name;
+
+-- name: DeleteEnvLabel :execrows
+DELETE FROM env_labels WHERE environment_id = $1 AND name = $2;
+
+-- name: ResolveEnvLabel :one
+SELECT l.version
+FROM env_labels l
+JOIN environments e ON e.id = l.environment_id
+WHERE e.project_id = sqlc.arg('project_id') AND e.name = sqlc.arg('env_name') AND l.name = sqlc.arg('label');
+
+-- name: CountDelegationsPinnedToLabel :one
+SELECT COUNT(*) FROM service_delegations
+WHERE project_id = $1 AND env = $2 AND pinned_label = $3;
+
+-- name: SetDelegationPinnedLabel :execrows
+UPDATE service_delegations
+SET pinned_label = $4
+WHERE service_role_id = $1 AND project_id = $2 AND env = $3;
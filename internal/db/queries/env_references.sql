@@ -0,0 +1,30 @@
+-- name: AddEnvReference :one
+INSERT INTO env_references (id, environment_id, ref_environment_id, key_hmac, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: DeleteEnvReference :execrows
+DELETE FROM env_references
+WHERE id = $1 AND environment_id = $2;
+
+-- name: ListEnvReferences :many
+SELECT
+    r.id,
+    r.key_hmac,
+    r.created_by,
+    r.created_at,
+    target.project_id AS ref_project_id,
+    target.name AS ref_env_name
+FROM env_references r
+JOIN environments target ON target.id = r.ref_environment_id
+WHERE r.environment_id = $1
+ORDER BY r.created_at, r.id;
+
+-- name: ListEnvReferencesTo :many
+SELECT
+    e.project_id,
+    e.name
+FROM env_references r
+JOIN environments e ON e.id = r.environment_id
+WHERE r.ref_environment_id = $1
+ORDER BY e.name;
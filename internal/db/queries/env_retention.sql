@@ -0,0 +1,41 @@
+-- name: SetProjectRetention :exec
+UPDATE projects
+SET retention_keep_versions = $2, retention_keep_days = $3
+WHERE id = $1;
+
+-- name: SetEnvironmentRetention :one
+UPDATE environments
+SET retention_keep_versions = $3, retention_keep_days = $4
+WHERE project_id = $1 AND name = $2
+RETURNING *;
+
+-- name: ListEnvironmentsWithRetention :many
+SELECT
+    e.id,
+    e.project_id,
+    e.name,
+    e.retention_keep_versions,
+    e.retention_keep_days,
+    p.retention_keep_versions AS project_keep_versions,
+    p.retention_keep_days AS project_keep_days
+FROM environments e
+JOIN projects p ON p.id = e.project_id
+WHERE e.retention_keep_versions IS NOT NULL
+   OR e.retention_keep_days IS NOT NULL
+   OR p.retention_keep_versions IS NOT NULL
+   OR p.retention_keep_days IS NOT NULL;
+
+-- name: ListUnshreddedEnvVersions :many
+SELECT id, version, status, wrapped_dek, created_at
+FROM env_versions
+WHERE project_id = $1 AND env_name = $2 AND shredded_at IS NULL
+ORDER BY version DESC;
+
+-- name: ShredEnvVersion :execrows
+UPDATE env_versions
+SET ciphertext = '', nonce = '', wrapped_dek = NULL, dek_nonce = NULL,
+    shredded_at = CURRENT_TIMESTAMP, shredded_by = $2
+WHERE id = $1 AND shredded_at IS NULL;
+
+-- name: DeleteEnvVersionEntries :exec
+DELETE FROM env_entries WHERE env_version_id = $1;
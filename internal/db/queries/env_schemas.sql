@@ -0,0 +1,18 @@
+-- name: AddEnvSchema :one
+INSERT INTO env_schemas (id, project_id, version, schema, created_by)
+VALUES ($1, $2, $3, $4, $5)
+RETURNING *;
+
+-- name: GetEnvSchema :one
+SELECT *
+FROM env_schemas
+WHERE project_id = sqlc.arg('project_id')
+  AND (sqlc.narg('version') IS NULL OR version = sqlc.narg('version'))
+ORDER BY version DESC
+LIMIT 1;
+
+-- name: ListEnvSchemas :many
+SELECT *
+FROM env_schemas
+WHERE project_id = $1
+ORDER BY version DESC;
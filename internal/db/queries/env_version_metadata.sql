@@ -0,0 +1,68 @@
+-- name: SetEnvVersionMessage :exec
+UPDATE env_versions SET message = $2 WHERE id = $1;
+
+-- name: AddEnvVersionTag :exec
+INSERT INTO env_version_tags (env_version_id, tag)
+VALUES ($1, $2)
+ON CONFLICT (env_version_id, tag) DO NOTHING;
+
+-- name: ListEnvVersionTags :many
+SELECT tag FROM env_version_tags WHERE env_version_id = $1 ORDER BY tag;
+
+-- name: SearchEnvVersions :many
+SELECT
+    ev.id,
+    ev.env_name,
+    ev.version,
+    ev.metadata,
+    ev.parent_version,
+    ev.encryption_version,
+    CAST(LENGTH(ev.ciphertext) + COALESCE((
+        SELECT SUM(LENGTH(e.name_ciphertext) + LENGTH(e.value_ciphertext))
+        FROM env_entries e
+        WHERE e.env_version_id = ev.id
+    ), 0) AS BIGINT) AS size,
+    ev.created_by,
+    u.email AS created_by_email,
+    ev.created_at,
+    ev.shredded_at
+FROM env_versions ev
+JOIN users u ON u.id = ev.created_by
+WHERE ev.project_id = sqlc.arg('project_id')
+  AND ev.status = 'published'
+  AND (sqlc.narg('env_name') IS NULL OR ev.env_name = sqlc.narg('env_name'))
+  AND (sqlc.narg('tag') IS NULL OR EXISTS (
+      SELECT 1 FROM env_version_tags t
+      WHERE t.env_version_id = ev.id AND t.tag = sqlc.narg('tag')
+  ))
+  AND (sqlc.narg('message_pattern') IS NULL OR LOWER(ev.message) LIKE sqlc.narg('message_pattern') ESCAPE '\')
+ORDER BY ev.created_at DESC, ev.version DESC
+LIMIT sqlc.arg('limit_val');
+
+-- name: ListEnvVersionSummaries :many
+SELECT
+    ev.id,
+    ev.env_name,
+    ev.version,
+    ev.metadata,
+    ev.parent_version,
+    ev.encryption_version,
+    CAST(LENGTH(ev.ciphertext) + COALESCE((
+        SELECT SUM(LENGTH(e.name_ciphertext) + LENGTH(e.value_ciphertext))
+        FROM env_entries e
+        WHERE e.env_version_id = ev.id
+    ), 0) AS BIGINT) AS size,
+    ev.created_by,
+    u.email AS created_by_email,
+    ev.created_at,
+    ev.shredded_at
+FROM env_versions ev
+JOIN users u ON u.id = ev.created_by
+WHERE ev.project_id = sqlc.arg('project_id')
+  AND ev.env_name = sqlc.arg('env_name')
+  AND ev.status = 'published'
+  AND (sqlc.narg('before_version') IS NULL OR ev.version < sqlc.narg('before_version'))
+  AND (sqlc.narg('from_time') IS NULL OR ev.created_at >= sqlc.narg('from_time'))
+  AND (sqlc.narg('to_time') IS NULL OR ev.created_at <= sqlc.narg('to_time'))
+ORDER BY ev.version DESC
+LIMIT sqlc.arg('limit_val');
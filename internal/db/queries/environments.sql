@@ -0,0 +1,153 @@
+-- name: CreateEnvironment :one
+INSERT INTO environments (
+    id,
+    project_id,
+    name,
+    description,
+    protected,
+    created_by
+)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: GetEnvironment :one
+SELECT * FROM environments WHERE project_id = $1 AND name = $2;
+
+-- name: ListEnvironments :many
+SELECT * FROM environments WHERE project_id = $1 ORDER BY name;
+
+-- name: ListProtectedEnvironmentNames :many
+SELECT name FROM environments WHERE project_id = $1 AND protected = true ORDER BY name;
+
+-- name: RenameEnvironment :one
+UPDATE environments
+SET name = sqlc.arg('new_name')
+WHERE project_id = sqlc.arg('project_id') AND name = sqlc.arg('name')
+RETURNING *;
+
+-- name: RenameEnvVersions :exec
+UPDATE env_versions
+SET env_name = sqlc.arg('new_name')
+WHERE project_id = sqlc.arg('project_id') AND env_name = sqlc.arg('name');
+
+-- name: RenameDelegationEnv :exec
+UPDATE service_delegations
+SET env = sqlc.arg('new_name')
+WHERE project_id = sqlc.arg('project_id') AND env = sqlc.arg('name');
+
+-- name: ArchiveEnvironment :one
+UPDATE environments
+SET archived_at = CURRENT_TIMESTAMP
+WHERE project_id = $1 AND name = $2 AND archived_at IS NULL
+RETURNING *;
+
+-- name: SetEnvironmentProtected :execrows
+UPDATE environments
+SET protected = $3
+WHERE project_id = $1 AND name = $2;
+
+-- name: SetProjectAutoCreateEnvironments :exec
+UPDATE projects
+SET auto_create_environments = $2
+WHERE id = $1;
+
+-- name: AllocateEnvVersion :one
+-- a locked environment matches no row, so a push that checked the lock
+-- before it was taken still cannot commit
+UPDATE environments
+SET head_version = head_version + 1
+WHERE id = $1
+  AND (locked_at IS NULL OR locked_until <= CURRENT_TIMESTAMP)
+RETURNING head_version;
+
+-- name: GetEnvironmentForUpdate :one
+-- locks the row until the caller's transaction ends; a no-op UPDATE rather
+-- than SELECT ... FOR UPDATE, which SQLite does not accept
+UPDATE environments
+SET head_version = head_version
+WHERE id = $1
+RETURNING *;
+
+-- name: SyncEnvironmentHeads :exec
+UPDATE environments
+SET head_version = COALESCE((
+    SELECT MAX(ev.version)
+    FROM env_versions ev
+    WHERE ev.project_id = environments.project_id AND ev.env_name = environments.name
+), 0)
+WHERE project_id = $1;
+
+-- name: LockEnvironment :one
+UPDATE environments
+SET locked_at = CURRENT_TIMESTAMP, locked_by = $3, lock_reason = $4, locked_until = $5
+WHERE project_id = $1 AND name = $2
+RETURNING *;
+
+-- name: UnlockEnvironment :one
+UPDATE environments
+SET locked_at = NULL, locked_by = NULL, lock_reason = NULL, locked_until = NULL
+WHERE project_id = $1 AND name = $2
+RETURNING *;
+
+-- name: RenameAccessRequestEnv :exec
+UPDATE access_requests
+SET env_name = sqlc.arg('new_name')
+WHERE project_id = sqlc.arg('project_id') AND env_name = sqlc.arg('name');
+
+-- name: RenameBreakGlassEnv :exec
+UPDATE break_glass_grants
+SET env_name = sqlc.arg('new_name')
+WHERE project_id = sqlc.arg('project_id') AND env_name = sqlc.arg('name');
+
+-- name: RenameEventEnv :exec
+UPDATE events
+SET env_name = sqlc.arg('new_name')
+WHERE project_id = sqlc.arg('project_id') AND env_name = sqlc.arg('name');
+
+-- name: RestoreEnvironment :one
+UPDATE environments
+SET archived_at = NULL
+WHERE project_id = $1 AND name = $2 AND archived_at IS NOT NULL
+RETURNING *;
+
+-- name: DeleteEnvVersionsForEnv :exec
+DELETE FROM env_versions WHERE project_id = $1 AND env_name = $2;
+
+-- name: DeleteDelegationsForEnv :exec
+DELETE FROM service_delegations WHERE project_id = $1 AND env = $2;
+
+-- name: DeleteAccessRequestsForEnv :exec
+DELETE FROM access_requests WHERE project_id = $1 AND env_name = $2;
+
+-- name: DeleteBreakGlassGrantsForEnv :exec
+DELETE FROM break_glass_grants WHERE project_id = $1 AND env_name = $2;
+
+-- name: DeleteEventsForEnv :exec
+DELETE FROM events WHERE project_id = $1 AND env_name = $2;
+
+-- name: DeleteEnvironment :execrows
+DELETE FROM environments WHERE id = $1;
+
+-- name: GetEnvironmentByID :one
+SELECT * FROM environments WHERE id = $1;
+
+-- name: ListChildEnvironments :many
+SELECT * FROM environments WHERE parent_id = $1 ORDER BY name;
+
+-- name: SetEnvironmentParent :one
+UPDATE environments
+SET parent_id = $3
+WHERE project_id = $1 AND name = $2
+RETURNING *;
+
+-- name: AddEnvVersionParent :exec
+INSERT INTO env_version_parents (env_version_id, position, parent_version_id)
+VALUES ($1, $2, $3);
+
+-- name: ListEnvVersionParents :many
+SELECT parent_version_id FROM env_version_parents
+WHERE env_version_id = $1
+ORDER BY position;
+
+-- name: GetEnvVersionByID :one
+SELECT * FROM env_versions WHERE id = $1;
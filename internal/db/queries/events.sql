@@ -0,0 +1,32 @@
+-- name: NextEventID :one
+-- locks the sequence row until the caller's transaction commits
+UPDATE event_sequence SET last_id = last_id + 1 WHERE id = 1
+RETURNING last_id;
+
+-- name: AddEvent :exec
+INSERT INTO events (id, project_id, env_name, type, subject_user_id, payload)
+VALUES ($1, $2, $3, $4, $5, $6);
+
+-- name: GetLatestEventID :one
+SELECT CAST(COALESCE(MAX(id), 0) AS BIGINT) FROM events;
+
+-- name: ListEventsForUser :many
+-- events of projects the user is an active member of, plus access events about
+-- the user themselves so a revoked client learns why its stream went quiet
+SELECT e.id, e.project_id, e.env_name, e.type, e.payload, e.created_at
+FROM events e
+WHERE e.id > sqlc.arg('after_id')
+  AND (sqlc.narg('project_id') IS NULL OR e.project_id = sqlc.narg('project_id'))
+  AND (sqlc.narg('env_name') IS NULL OR e.env_name IS NULL OR e.env_name = sqlc.narg('env_name'))
+  AND (
+    e.subject_user_id = sqlc.arg('user_id')
+    OR EXISTS (
+      SELECT 1 FROM project_members pm
+      WHERE pm.project_id = e.project_id AND pm.user_id = sqlc.arg('user_id') AND pm.is_revoked = false
+    )
+  )
+ORDER BY e.id
+LIMIT sqlc.arg('limit_val');
+
+-- name: DeleteEventsBefore :execrows
+DELETE FROM events WHERE created_at < $1;
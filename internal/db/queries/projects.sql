@@ -29,8 +29,10 @@ SELECT
     p.name,
     p.created_by,
     p.created_at,
+    p.rotation_required,
     pm.role,
-    pm.is_revoked
+    pm.is_revoked,
+    pm.expires_at
 FROM projects p JOIN project_members pm ON pm.project_id = p.id
 WHERE pm.user_id = $1
 ORDER BY p.created_at DESC;
@@ -47,23 +49,63 @@ WHERE p.name = $1
 INSERT INTO project_members (
     project_id,
     user_id,
-    role
+    role,
+    expires_at
 )
 VALUES (
            $1,
            $2,
-            $3
+            $3,
+            $4
        )
 RETURNING *;
 
 -- name: GetUserProjectRole :one
-SELECT * FROM project_members WHERE project_id = $1 AND user_id = $2 and is_revoked = $3;
+SELECT * FROM project_members
+WHERE project_id = $1 AND user_id = $2 and is_revoked = $3
+  AND (expires_at IS NULL OR expires_at > CURRENT_TIMESTAMP);
+
+-- name: GetProjectMember :one
+SELECT * FROM project_members WHERE project_id = $1 AND user_id = $2;
 
 -- name: SetUserAccess :exec
 UPDATE project_members
 SET is_revoked = $3
 WHERE user_id = $1 AND project_id = $2;
 
+-- name: RestoreProjectMember :exec
+UPDATE project_members
+SET is_revoked = false, expires_at = $3, role = $4
+WHERE project_id = $1 AND user_id = $2;
+
+-- name: RenewProjectMember :one
+UPDATE project_members
+SET expires_at = $3
+WHERE project_id = $1 AND user_id = $2 AND is_revoked = false
+RETURNING *;
+
+-- name: ListExpiredMembers :many
+SELECT *
+FROM project_members
+WHERE is_revoked = false
+  AND expires_at IS NOT NULL
+  AND expires_at <= CURRENT_TIMESTAMP;
+
+-- name: RevokeExpiredMember :one
+UPDATE project_members
+SET is_revoked = true
+WHERE project_id = $1
+  AND user_id = $2
+  AND is_revoked = false
+  AND expires_at IS NOT NULL
+  AND expires_at <= CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: SetRotationRequired :exec
+UPDATE projects
+SET rotation_required = true
+WHERE id = $1;
+
 
 -- name: AddWrappedPRK :one
 INSERT INTO project_wrapped_keys (
@@ -83,9 +125,27 @@ VALUES (
        )
 RETURNING *;
 
+-- name: UpsertWrappedPRK :exec
+INSERT INTO project_wrapped_keys (
+    project_id,
+    user_id,
+
+    wrapped_prk,
+    wrap_nonce,
+    wrap_ephemeral_pub
+)
+VALUES ($1, $2, $3, $4, $5)
+ON CONFLICT (project_id, user_id) DO UPDATE
+SET wrapped_prk = EXCLUDED.wrapped_prk,
+    wrap_nonce = EXCLUDED.wrap_nonce,
+    wrap_ephemeral_pub = EXCLUDED.wrap_ephemeral_pub;
+
 -- name: GetProjectWrappedKey :one
 SELECT * FROM project_wrapped_keys WHERE project_id = $1 AND user_id = $2;
 
+-- name: DeleteWrappedPRK :exec
+DELETE FROM project_wrapped_keys WHERE project_id = $1 AND user_id = $2;
+
 
 -- name: AddEnv :one
 INSERT INTO env_versions (
@@ -99,35 +159,33 @@ INSERT INTO env_versions (
     dek_nonce,
     encryption_version,
     created_by,
-    metadata
+    metadata,
+    status,
+    candidate_expires_at,
+    parent_version
+)
+VALUES (
+    $1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14
 )
-SELECT
-    $1,
-    $2,
-    $3,
-    COALESCE(MAX(version), 0) + 1,
-    $4,
-    $5,
-    $6,
-    $7,
-    $8,
-    $9,
-    $10
-FROM env_versions
-WHERE project_id = $2 AND env_name = $3
 RETURNING *;
 
 
 
 
 -- name: GetEnv :one
-SELECT * FROM env_versions WHERE project_id = $1 AND env_name = $2 AND version = $3;
+SELECT * FROM env_versions WHERE project_id = $1 AND env_name = $2 AND version = $3 AND status = 'published';
 
 -- name: GetLatestEnv :one
-SELECT * FROM env_versions WHERE project_id = $1 AND env_name = $2 ORDER BY version DESC LIMIT 1;
+SELECT * FROM env_versions WHERE project_id = $1 AND env_name = $2 AND status = 'published' ORDER BY version DESC LIMIT 1;
+
+-- name: GetPublishedEnvVersion :one
+SELECT version FROM env_versions
+WHERE project_id = sqlc.arg('project_id') AND env_name = sqlc.arg('env_name') AND status = 'published'
+  AND (sqlc.narg('version') IS NULL OR version = sqlc.narg('version'))
+ORDER BY version DESC LIMIT 1;
 
 -- name: GetEnvVersions :many
-SELECT * FROM env_versions WHERE project_id = $1 AND env_name = $2 ORDER BY version DESC;
+SELECT * FROM env_versions WHERE project_id = $1 AND env_name = $2 AND status = 'published' ORDER BY version DESC;
 
 -- name: GetRotationData :many
 SELECT
@@ -146,7 +204,7 @@ FROM env_versions
 WHERE project_id = $1 AND wrapped_dek IS NOT NULL;
 
 -- name: GetAllEnvVersionsForProject :many
-SELECT * FROM env_versions WHERE project_id = $1;
+SELECT * FROM env_versions WHERE project_id = $1 AND status = 'published' AND shredded_at IS NULL;
 
 
 -- name: UpdateWrappedPRK :exec
@@ -161,7 +219,8 @@ WHERE id = $1;
 
 -- name: IncrementPRKVersion :one
 UPDATE projects
-SET prk_version = prk_version + 1
+SET prk_version = prk_version + 1,
+    rotation_required = false
 WHERE id = $1 AND prk_version = $2
 RETURNING prk_version;
 
@@ -40,7 +40,8 @@ INSERT INTO service_delegations (
     wrapped_prk,
     wrap_nonce,
     wrap_ephemeral_pub,
-    delegated_by
+    delegated_by,
+    pinned_label
 )
 VALUES (
            $1,  -- service_role_id
@@ -49,7 +50,8 @@ VALUES (
            $4,  -- wrapped_prk
            $5,  -- wrap_nonce
            $6,  -- wrap_ephemeral_pub (admin_eph_pub)
-           $7   -- delegated_by (admin user id)
+           $7,  -- delegated_by (admin user id)
+           $8   -- pinned_label
        )
 RETURNING
 service_role_id,
@@ -73,6 +75,7 @@ SELECT
     d.project_id,
     d.env,
     d.created_at,
+    d.pinned_label,
     p.name        AS project_name
 FROM service_delegations d
          JOIN projects p
@@ -0,0 +1,24 @@
+-- name: CreateShare :one
+INSERT INTO shares (id, created_by, ciphertext, nonce, max_views, expires_at)
+VALUES ($1, $2, $3, $4, $5, $6)
+RETURNING *;
+
+-- name: ViewShare :one
+UPDATE shares
+SET views = views + 1
+WHERE id = $1
+  AND views < max_views
+  AND expires_at > CURRENT_TIMESTAMP
+RETURNING *;
+
+-- name: DeleteShare :exec
+DELETE FROM shares
+WHERE id = $1;
+
+-- name: RevokeShare :execrows
+DELETE FROM shares
+WHERE id = $1 AND created_by = $2;
+
+-- name: DeleteSpentShares :execrows
+DELETE FROM shares
+WHERE views >= max_views OR expires_at <= CURRENT_TIMESTAMP;
@@ -0,0 +1,60 @@
+-- name: CreateWebhook :one
+INSERT INTO webhooks (id, project_id, url, secret_ciphertext, secret_nonce, events, created_by)
+VALUES ($1, $2, $3, $4, $5, $6, $7)
+RETURNING *;
+
+-- name: ListWebhooks :many
+SELECT * FROM webhooks WHERE project_id = $1 ORDER BY created_at;
+
+-- name: GetWebhook :one
+SELECT * FROM webhooks WHERE id = $1;
+
+-- name: DeleteWebhook :execrows
+DELETE FROM webhooks WHERE id = $1 AND project_id = $2;
+
+-- name: EnqueueWebhookDelivery :exec
+INSERT INTO webhook_deliveries (id, webhook_id, action, payload)
+VALUES ($1, $2, $3, $4);
+
+-- name: ClaimWebhookDeliveries :many
+-- pushing next_attempt_at out leases the rows, so a replica polling at the same
+-- time skips them; the outer check is re-evaluated against concurrent updates
+UPDATE webhook_deliveries
+SET next_attempt_at = sqlc.arg('lease_until')
+WHERE status = 'pending' AND next_attempt_at <= sqlc.arg('now')
+  AND id IN (
+    SELECT d.id FROM webhook_deliveries d
+    WHERE d.status = 'pending' AND d.next_attempt_at <= sqlc.arg('now')
+    ORDER BY d.next_attempt_at
+    LIMIT sqlc.arg('limit_val')
+  )
+RETURNING *;
+
+-- name: MarkWebhookDelivered :exec
+UPDATE webhook_deliveries
+SET status = 'delivered', attempts = attempts + 1, last_status_code = $2, last_error = NULL, delivered_at = CURRENT_TIMESTAMP
+WHERE id = $1;
+
+-- name: MarkWebhookAttemptFailed :exec
+UPDATE webhook_deliveries
+SET status = $2, attempts = attempts + 1, next_attempt_at = $3, last_status_code = $4, last_error = $5
+WHERE id = $1;
+
+-- name: ListWebhookDeliveries :many
+SELECT * FROM webhook_deliveries
+WHERE webhook_id = sqlc.arg('webhook_id')
+  AND (sqlc.narg('status') IS NULL OR status = sqlc.narg('status'))
+ORDER BY created_at DESC
+LIMIT sqlc.arg('limit_val');
+
+-- name: GetWebhookDelivery :one
+SELECT d.*, w.project_id
+FROM webhook_deliveries d
+JOIN webhooks w ON w.id = d.webhook_id
+WHERE d.id = $1;
+
+-- name: RequeueWebhookDelivery :one
+UPDATE webhook_deliveries
+SET status = 'pending', attempts = 0, next_attempt_at = CURRENT_TIMESTAMP, delivered_at = NULL
+WHERE id = $1
+RETURNING *;
@@ -0,0 +1,25 @@
+-- +goose Up
+CREATE TABLE access_requests (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    env_name TEXT NOT NULL,
+    reason TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'approved', 'rejected', 'cancelled', 'expired')),
+    decided_by TEXT NULL REFERENCES users(id) ON DELETE SET NULL,
+    decided_at TIMESTAMP NULL,
+    decision_note TEXT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL DEFAULT (datetime(CURRENT_TIMESTAMP, '+7 days'))
+);
+
+CREATE INDEX idx_access_requests_project
+    ON access_requests(project_id, status);
+
+CREATE UNIQUE INDEX idx_access_requests_one_pending
+    ON access_requests(project_id, user_id)
+    WHERE status = 'pending';
+
+-- +goose Down
+DROP TABLE IF EXISTS access_requests;
@@ -0,0 +1,12 @@
+-- +goose Up
+ALTER TABLE project_members ADD COLUMN expires_at TIMESTAMP NULL;
+ALTER TABLE projects ADD COLUMN rotation_required INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX idx_project_members_expiry
+    ON project_members(expires_at)
+    WHERE expires_at IS NOT NULL AND is_revoked = 0;
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_project_members_expiry;
+ALTER TABLE projects DROP COLUMN rotation_required;
+ALTER TABLE project_members DROP COLUMN expires_at;
@@ -0,0 +1,49 @@
+-- +goose Up
+ALTER TABLE audit_logs ADD COLUMN severity TEXT NOT NULL DEFAULT 'info';
+CREATE INDEX idx_audit_logs_severity ON audit_logs(severity);
+
+CREATE TABLE break_glass_policies (
+    project_id TEXT PRIMARY KEY REFERENCES projects(id) ON DELETE CASCADE,
+    escrow_user_id TEXT NULL REFERENCES users(id) ON DELETE SET NULL,
+    window_minutes INTEGER NOT NULL DEFAULT 60,
+    updated_by TEXT NOT NULL REFERENCES users(id),
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE TABLE break_glass_eligible_users (
+    project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    added_by TEXT NOT NULL REFERENCES users(id),
+    added_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, user_id)
+);
+
+CREATE TABLE break_glass_grants (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    user_id TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    env_name TEXT NOT NULL,
+    justification TEXT NOT NULL,
+    status TEXT NOT NULL DEFAULT 'pending'
+        CHECK (status IN ('pending', 'active', 'expired', 'revoked')),
+    window_minutes INTEGER NOT NULL,
+    fulfilled_by TEXT NULL REFERENCES users(id) ON DELETE SET NULL,
+    fulfilled_at TIMESTAMP NULL,
+    revoked_by TEXT NULL REFERENCES users(id) ON DELETE SET NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    expires_at TIMESTAMP NOT NULL
+);
+
+CREATE INDEX idx_break_glass_grants_project
+    ON break_glass_grants(project_id, status);
+
+CREATE UNIQUE INDEX idx_break_glass_grants_one_open
+    ON break_glass_grants(project_id, user_id)
+    WHERE status IN ('pending', 'active');
+
+-- +goose Down
+DROP TABLE IF EXISTS break_glass_grants;
+DROP TABLE IF EXISTS break_glass_eligible_users;
+DROP TABLE IF EXISTS break_glass_policies;
+DROP INDEX IF EXISTS idx_audit_logs_severity;
+ALTER TABLE audit_logs DROP COLUMN severity;
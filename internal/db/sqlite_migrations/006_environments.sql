@@ -0,0 +1,68 @@
+-- +goose Up
+CREATE TABLE environments (
+    id TEXT PRIMARY KEY,
+    project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    description TEXT NOT NULL DEFAULT '',
+    protected INTEGER NOT NULL DEFAULT 0,
+    created_by TEXT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    archived_at TIMESTAMP NULL,
+    UNIQUE (project_id, name)
+);
+
+ALTER TABLE projects ADD COLUMN auto_create_environments INTEGER NOT NULL DEFAULT 0;
+
+INSERT INTO environments (id, project_id, name, created_by, created_at)
+SELECT
+    lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+    substr(lower(hex(randomblob(2))), 2) || '-a' ||
+    substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))),
+    ev.project_id, ev.env_name, p.created_by, MIN(ev.created_at)
+FROM env_versions ev
+         JOIN projects p ON p.id = ev.project_id
+GROUP BY ev.project_id, ev.env_name, p.created_by;
+
+INSERT OR IGNORE INTO environments (id, project_id, name, created_by, created_at)
+SELECT
+    lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+    substr(lower(hex(randomblob(2))), 2) || '-a' ||
+    substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))),
+    d.project_id, d.env, p.created_by, MIN(d.created_at)
+FROM service_delegations d
+         JOIN projects p ON p.id = d.project_id
+GROUP BY d.project_id, d.env, p.created_by;
+
+INSERT OR IGNORE INTO environments (id, project_id, name, created_by, created_at)
+SELECT
+    lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+    substr(lower(hex(randomblob(2))), 2) || '-a' ||
+    substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))),
+    project_id, env_name, created_by, created_at
+FROM protected_environments;
+
+UPDATE environments
+SET protected = 1
+WHERE EXISTS (
+    SELECT 1 FROM protected_environments pe
+    WHERE pe.project_id = environments.project_id AND pe.env_name = environments.name
+);
+
+DROP TABLE protected_environments;
+
+-- +goose Down
+CREATE TABLE protected_environments (
+    project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    env_name TEXT NOT NULL,
+    created_by TEXT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, env_name)
+);
+
+INSERT INTO protected_environments (project_id, env_name, created_by, created_at)
+SELECT project_id, name, created_by, created_at
+FROM environments
+WHERE protected = 1;
+
+ALTER TABLE projects DROP COLUMN auto_create_environments;
+DROP TABLE IF EXISTS environments;
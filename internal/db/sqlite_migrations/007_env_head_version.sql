@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE environments ADD COLUMN head_version INTEGER NOT NULL DEFAULT 0;
+
+UPDATE environments
+SET head_version = COALESCE((
+    SELECT MAX(ev.version)
+    FROM env_versions ev
+    WHERE ev.project_id = environments.project_id AND ev.env_name = environments.name
+), 0);
+
+ALTER TABLE env_versions ADD COLUMN parent_version INTEGER NULL;
+
+-- +goose Down
+ALTER TABLE env_versions DROP COLUMN parent_version;
+ALTER TABLE environments DROP COLUMN head_version;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE env_entries (
+    env_version_id TEXT NOT NULL REFERENCES env_versions(id) ON DELETE CASCADE,
+    key_hmac BLOB NOT NULL,
+    name_ciphertext BLOB NOT NULL,
+    name_nonce BLOB NOT NULL,
+    value_ciphertext BLOB NOT NULL,
+    value_nonce BLOB NOT NULL,
+    changed_in_version INTEGER NOT NULL,
+    PRIMARY KEY (env_version_id, key_hmac)
+);
+
+CREATE INDEX idx_env_entries_key_hmac ON env_entries(key_hmac);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_env_entries_key_hmac;
+DROP TABLE IF EXISTS env_entries;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE env_versions ADD COLUMN message TEXT NULL;
+
+CREATE TABLE env_version_tags (
+    env_version_id TEXT NOT NULL REFERENCES env_versions(id) ON DELETE CASCADE,
+    tag TEXT NOT NULL,
+    PRIMARY KEY (env_version_id, tag)
+);
+
+CREATE INDEX idx_env_version_tags_tag ON env_version_tags(tag);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_env_version_tags_tag;
+DROP TABLE IF EXISTS env_version_tags;
+ALTER TABLE env_versions DROP COLUMN message;
@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE env_labels (
+    id TEXT PRIMARY KEY,
+    environment_id TEXT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    name TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    updated_by TEXT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    updated_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (environment_id, name)
+);
+
+ALTER TABLE service_delegations ADD COLUMN pinned_label TEXT NULL;
+
+-- +goose Down
+ALTER TABLE service_delegations DROP COLUMN pinned_label;
+DROP TABLE IF EXISTS env_labels;
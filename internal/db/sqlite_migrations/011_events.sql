@@ -0,0 +1,16 @@
+-- +goose Up
+CREATE TABLE events (
+    id INTEGER PRIMARY KEY AUTOINCREMENT,
+    project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    env_name TEXT NULL,
+    type TEXT NOT NULL,
+    subject_user_id TEXT NULL REFERENCES users(id) ON DELETE CASCADE,
+    payload TEXT NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_events_project_id ON events(project_id, id);
+CREATE INDEX idx_events_created_at ON events(created_at);
+
+-- +goose Down
+DROP TABLE IF EXISTS events;
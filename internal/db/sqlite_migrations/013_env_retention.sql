@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE projects ADD COLUMN retention_keep_versions INTEGER NULL;
+ALTER TABLE projects ADD COLUMN retention_keep_days INTEGER NULL;
+ALTER TABLE environments ADD COLUMN retention_keep_versions INTEGER NULL;
+ALTER TABLE environments ADD COLUMN retention_keep_days INTEGER NULL;
+
+ALTER TABLE env_versions ADD COLUMN shredded_at TIMESTAMP NULL;
+ALTER TABLE env_versions ADD COLUMN shredded_by TEXT NULL REFERENCES users(id) ON DELETE SET NULL;
+
+-- +goose Down
+ALTER TABLE env_versions DROP COLUMN shredded_by;
+ALTER TABLE env_versions DROP COLUMN shredded_at;
+ALTER TABLE environments DROP COLUMN retention_keep_days;
+ALTER TABLE environments DROP COLUMN retention_keep_versions;
+ALTER TABLE projects DROP COLUMN retention_keep_days;
+ALTER TABLE projects DROP COLUMN retention_keep_versions;
@@ -0,0 +1,11 @@
+-- +goose Up
+ALTER TABLE environments ADD COLUMN locked_at TIMESTAMP NULL;
+ALTER TABLE environments ADD COLUMN locked_by TEXT NULL REFERENCES users(id) ON DELETE SET NULL;
+ALTER TABLE environments ADD COLUMN lock_reason TEXT NULL;
+ALTER TABLE environments ADD COLUMN locked_until TIMESTAMP NULL;
+
+-- +goose Down
+ALTER TABLE environments DROP COLUMN locked_until;
+ALTER TABLE environments DROP COLUMN lock_reason;
+ALTER TABLE environments DROP COLUMN locked_by;
+ALTER TABLE environments DROP COLUMN locked_at;
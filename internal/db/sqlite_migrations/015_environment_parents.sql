@@ -0,0 +1,16 @@
+-- +goose Up
+ALTER TABLE environments ADD COLUMN parent_id TEXT NULL REFERENCES environments(id) ON DELETE SET NULL;
+
+CREATE INDEX idx_environments_parent ON environments(parent_id);
+
+CREATE TABLE env_version_parents (
+    env_version_id TEXT NOT NULL REFERENCES env_versions(id) ON DELETE CASCADE,
+    position INTEGER NOT NULL,
+    parent_version_id TEXT NOT NULL,
+    PRIMARY KEY (env_version_id, position)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS env_version_parents;
+DROP INDEX IF EXISTS idx_environments_parent;
+ALTER TABLE environments DROP COLUMN parent_id;
@@ -0,0 +1,15 @@
+-- +goose Up
+CREATE TABLE env_consumers (
+    id TEXT PRIMARY KEY,
+    environment_id TEXT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    consumer TEXT NOT NULL,
+    version INTEGER NOT NULL,
+    label TEXT NULL,
+    parent_versions TEXT NOT NULL DEFAULT '[]',
+    pulled_by TEXT NOT NULL,
+    pulled_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    UNIQUE (environment_id, consumer)
+);
+
+-- +goose Down
+DROP TABLE IF EXISTS env_consumers;
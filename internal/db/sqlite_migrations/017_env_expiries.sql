@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE env_expiries (
+    id TEXT PRIMARY KEY,
+    env_version_id TEXT NOT NULL REFERENCES env_versions(id) ON DELETE CASCADE,
+    key_hmac BLOB NULL,
+    expires_at TIMESTAMP NOT NULL,
+    reminded_at TIMESTAMP NULL,
+    overdue_notified_at TIMESTAMP NULL
+);
+
+CREATE INDEX idx_env_expiries_expires_at ON env_expiries(expires_at);
+CREATE INDEX idx_env_expiries_version ON env_expiries(env_version_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_env_expiries_version;
+DROP INDEX IF EXISTS idx_env_expiries_expires_at;
+DROP TABLE IF EXISTS env_expiries;
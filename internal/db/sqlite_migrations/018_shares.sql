@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE shares (
+    id TEXT PRIMARY KEY,
+    created_by TEXT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+    ciphertext BLOB NOT NULL,
+    nonce BLOB NOT NULL,
+    max_views INTEGER NOT NULL,
+    views INTEGER NOT NULL DEFAULT 0,
+    expires_at TIMESTAMP NOT NULL,
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE INDEX idx_shares_expires_at ON shares(expires_at);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_shares_expires_at;
+DROP TABLE IF EXISTS shares;
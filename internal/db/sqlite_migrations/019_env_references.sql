@@ -0,0 +1,17 @@
+-- +goose Up
+CREATE TABLE env_references (
+    id TEXT PRIMARY KEY,
+    environment_id TEXT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    ref_environment_id TEXT NOT NULL REFERENCES environments(id) ON DELETE CASCADE,
+    key_hmac BLOB NULL,
+    created_by TEXT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP
+);
+
+CREATE UNIQUE INDEX idx_env_references_unique ON env_references(environment_id, ref_environment_id, COALESCE(key_hmac, X''));
+CREATE INDEX idx_env_references_ref ON env_references(ref_environment_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_env_references_ref;
+DROP INDEX IF EXISTS idx_env_references_unique;
+DROP TABLE IF EXISTS env_references;
@@ -0,0 +1,11 @@
+-- +goose Up
+CREATE TABLE event_sequence (
+    id INTEGER PRIMARY KEY CHECK (id = 1),
+    last_id INTEGER NOT NULL
+);
+
+INSERT INTO event_sequence (id, last_id)
+SELECT 1, COALESCE(MAX(id), 0) FROM events;
+
+-- +goose Down
+DROP TABLE IF EXISTS event_sequence;
@@ -0,0 +1,15 @@
+-- +goose Up
+ALTER TABLE audit_logs ADD COLUMN environment_id TEXT NULL;
+
+UPDATE audit_logs
+SET environment_id = (
+    SELECT e.id FROM environments e
+    WHERE e.project_id = audit_logs.project_id AND e.name = audit_logs.environment
+)
+WHERE environment IS NOT NULL;
+
+CREATE INDEX idx_audit_logs_environment_id ON audit_logs(environment_id);
+
+-- +goose Down
+DROP INDEX IF EXISTS idx_audit_logs_environment_id;
+ALTER TABLE audit_logs DROP COLUMN environment_id;
@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE IF NOT EXISTS protected_environments (
+    project_id TEXT NOT NULL REFERENCES projects(id) ON DELETE CASCADE,
+    env_name TEXT NOT NULL,
+    created_by TEXT NOT NULL REFERENCES users(id),
+    created_at TIMESTAMP NOT NULL DEFAULT CURRENT_TIMESTAMP,
+    PRIMARY KEY (project_id, env_name)
+);
+
+INSERT OR IGNORE INTO environments (id, project_id, name, created_by, created_at)
+SELECT
+    lower(hex(randomblob(4))) || '-' || lower(hex(randomblob(2))) || '-4' ||
+    substr(lower(hex(randomblob(2))), 2) || '-a' ||
+    substr(lower(hex(randomblob(2))), 2) || '-' || lower(hex(randomblob(6))),
+    project_id, env_name, created_by, created_at
+FROM protected_environments;
+
+UPDATE environments
+SET protected = 1
+WHERE EXISTS (
+    SELECT 1 FROM protected_environments pe
+    WHERE pe.project_id = environments.project_id AND pe.env_name = environments.name
+);
+
+DROP TABLE protected_environments;
+
+-- +goose Down
@@ -16,6 +16,8 @@ const (
 	CodeForbidden    Code = "FORBIDDEN"
 	CodeNotFound     Code = "NOT_FOUND"
 	CodeConflict     Code = "CONFLICT"
+	CodeGone         Code = "GONE"
+	CodeLocked       Code = "LOCKED"
 	CodeInternal     Code = "INTERNAL_ERROR"
 )
 
@@ -76,6 +78,14 @@ func Conflict(msg, hint string) *AppError {
 	return &AppError{Status: http.StatusConflict, Code: CodeConflict, Msg: msg, Hint: hint}
 }
 
+func Gone(msg, hint string) *AppError {
+	return &AppError{Status: http.StatusGone, Code: CodeGone, Msg: msg, Hint: hint}
+}
+
+func Locked(msg, hint string) *AppError {
+	return &AppError{Status: http.StatusLocked, Code: CodeLocked, Msg: msg, Hint: hint}
+}
+
 func Internal(err error) *AppError {
 	return Wrap(CodeInternal, http.StatusInternalServerError, "Internal server error", err)
 }
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+func (handler *Handler) CreateAccessRequest(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.AccessRequestCreateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if requestBody.Reason == "" {
+		validationErrors["reason"] = "reason is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	accessRequest, err := handler.Services.AccessRequests.Create(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusCreated, config.AccessRequestCreateResponse{
+		Message:       "Access request submitted",
+		AccessRequest: *accessRequest,
+	})
+	return nil
+}
+
+func (handler *Handler) ListAccessRequests(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.AccessRequestListRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ProjectID == uuid.Nil {
+		return errors.Validation(map[string]string{"project_id": "project_id is required"})
+	}
+
+	resp, err := handler.Services.AccessRequests.List(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) ListMyAccessRequests(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.AccessRequestMineRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	resp, err := handler.Services.AccessRequests.ListMine(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) ApproveAccessRequest(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.AccessRequestApproveRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.RequestID == uuid.Nil {
+		return errors.Validation(map[string]string{"request_id": "request_id is required"})
+	}
+
+	accessRequest, err := handler.Services.AccessRequests.Approve(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.AccessRequestDecisionResponse{
+		Message:       "Access request approved",
+		AccessRequest: *accessRequest,
+	})
+	return nil
+}
+
+func (handler *Handler) RejectAccessRequest(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.AccessRequestRejectRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.RequestID == uuid.Nil {
+		return errors.Validation(map[string]string{"request_id": "request_id is required"})
+	}
+
+	accessRequest, err := handler.Services.AccessRequests.Reject(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.AccessRequestDecisionResponse{
+		Message:       "Access request rejected",
+		AccessRequest: *accessRequest,
+	})
+	return nil
+}
+
+func (handler *Handler) CancelAccessRequest(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.AccessRequestCancelRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.RequestID == uuid.Nil {
+		return errors.Validation(map[string]string{"request_id": "request_id is required"})
+	}
+
+	accessRequest, err := handler.Services.AccessRequests.Cancel(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.AccessRequestDecisionResponse{
+		Message:       "Access request cancelled",
+		AccessRequest: *accessRequest,
+	})
+	return nil
+}
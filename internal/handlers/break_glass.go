@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+func (handler *Handler) SetBreakGlassPolicy(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.BreakGlassPolicyRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ProjectID == uuid.Nil {
+		return errors.Validation(map[string]string{"project_id": "project_id is required"})
+	}
+
+	resp, err := handler.Services.BreakGlass.SetPolicy(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) GetBreakGlassPolicy(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.BreakGlassPolicyGetRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ProjectID == uuid.Nil {
+		return errors.Validation(map[string]string{"project_id": "project_id is required"})
+	}
+
+	resp, err := handler.Services.BreakGlass.GetPolicy(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) AddBreakGlassUser(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.BreakGlassEligibleRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if err := validateBreakGlassEligible(requestBody); err != nil {
+		return err
+	}
+
+	resp, err := handler.Services.BreakGlass.AddEligibleUser(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) RemoveBreakGlassUser(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.BreakGlassEligibleRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if err := validateBreakGlassEligible(requestBody); err != nil {
+		return err
+	}
+
+	resp, err := handler.Services.BreakGlass.RemoveEligibleUser(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) RequestBreakGlass(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.BreakGlassCreateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if len(requestBody.Justification) < 10 {
+		validationErrors["justification"] = "justification is required and must be at least 10 characters"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	grant, err := handler.Services.BreakGlass.Request(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusCreated, config.BreakGlassGrantResponse{
+		Message: "Break-glass request submitted",
+		Grant:   *grant,
+	})
+	return nil
+}
+
+func (handler *Handler) ListPendingBreakGlass(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.BreakGlassPendingRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ProjectID == uuid.Nil {
+		return errors.Validation(map[string]string{"project_id": "project_id is required"})
+	}
+
+	resp, err := handler.Services.BreakGlass.Pending(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) FulfillBreakGlass(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.BreakGlassFulfillRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.GrantID == uuid.Nil {
+		return errors.Validation(map[string]string{"grant_id": "grant_id is required"})
+	}
+
+	grant, err := handler.Services.BreakGlass.Fulfill(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.BreakGlassGrantResponse{
+		Message: "Break-glass access granted",
+		Grant:   *grant,
+	})
+	return nil
+}
+
+func (handler *Handler) RevokeBreakGlass(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.BreakGlassRevokeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.GrantID == uuid.Nil {
+		return errors.Validation(map[string]string{"grant_id": "grant_id is required"})
+	}
+
+	grant, err := handler.Services.BreakGlass.Revoke(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.BreakGlassGrantResponse{
+		Message: "Break-glass access revoked",
+		Grant:   *grant,
+	})
+	return nil
+}
+
+func validateBreakGlassEligible(requestBody config.BreakGlassEligibleRequest) error {
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.UserID == uuid.Nil {
+		validationErrors["user_id"] = "user_id is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+	return nil
+}
@@ -3,7 +3,12 @@ package handlers
 import (
 	"encoding/json"
 	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
+	"github.com/google/uuid"
 	"github.com/vijayvenkatj/envcrypt/internal/config"
 	"github.com/vijayvenkatj/envcrypt/internal/errors"
 	"github.com/vijayvenkatj/envcrypt/internal/helpers"
@@ -18,11 +23,24 @@ func (handler *Handler) GetEnv(w http.ResponseWriter, r *http.Request) error {
 	}
 	defer r.Body.Close()
 
+	if RequestBody.Version != nil && RequestBody.Label != nil {
+		return errors.Validation(map[string]string{"label": "send either version or label, not both"})
+	}
+	RequestBody.KnownTags = knownTagsFromRequest(r)
+
 	resp, err := handler.Services.Env.GetEnv(r.Context(), RequestBody)
 	if err != nil {
 		return err
 	}
 
+	if !resp.Composite {
+		w.Header().Set("ETag", versionETag(resp.EnvironmentID, resp.Version))
+	}
+	if resp.NotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
 	helpers.WriteResponse(w, http.StatusOK, resp)
 	return nil
 }
@@ -53,15 +71,22 @@ func (handler *Handler) AddEnv(w http.ResponseWriter, r *http.Request) error {
 	}
 	defer r.Body.Close()
 
-	if err := handler.Services.Env.AddEnv(r.Context(), RequestBody); err != nil {
+	if err := validateMetadata(RequestBody.Metadata); err != nil {
 		return err
 	}
 
-	helpers.WriteResponse(w, http.StatusCreated, struct {
-		Message string `json:"message"`
-	}{
-		Message: "env added successfully",
-	})
+	expectedVersion, expectedEnvironmentID, err := expectedVersionFromRequest(r, RequestBody.ExpectedVersion)
+	if err != nil {
+		return err
+	}
+	RequestBody.ExpectedVersion, RequestBody.ExpectedEnvironmentID = expectedVersion, expectedEnvironmentID
+
+	resp, err := handler.Services.Env.AddEnv(r.Context(), RequestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, pushStatusCode(resp.Status), resp)
 	return nil
 }
 
@@ -72,15 +97,22 @@ func (handler *Handler) UpdateEnv(w http.ResponseWriter, r *http.Request) error
 	}
 	defer r.Body.Close()
 
-	if err := handler.Services.Env.UpdateEnv(r.Context(), RequestBody); err != nil {
+	if err := validateMetadata(RequestBody.Metadata); err != nil {
 		return err
 	}
 
-	helpers.WriteResponse(w, http.StatusCreated, struct {
-		Message string `json:"message"`
-	}{
-		Message: "env updated successfully",
-	})
+	expectedVersion, expectedEnvironmentID, err := expectedVersionFromRequest(r, RequestBody.ExpectedVersion)
+	if err != nil {
+		return err
+	}
+	RequestBody.ExpectedVersion, RequestBody.ExpectedEnvironmentID = expectedVersion, expectedEnvironmentID
+
+	resp, err := handler.Services.Env.UpdateEnv(r.Context(), RequestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, pushStatusCode(resp.Status), resp)
 	return nil
 }
 
@@ -92,11 +124,608 @@ func (handler *Handler) GetCIEnv(w http.ResponseWriter, r *http.Request) error {
 	}
 	defer r.Body.Close()
 
+	if requestBody.SessionID == uuid.Nil {
+		return errors.Unauthorized("SESSION_MISSING", "Session ID is required", "Log in through OIDC to obtain a session")
+	}
+
+	if err := validateConsumer(requestBody.Consumer); err != nil {
+		return err
+	}
+
+	requestBody.KnownTags = knownTagsFromRequest(r)
+
 	responseBody, err := handler.Services.Env.GetEnvForCI(r.Context(), requestBody)
 	if err != nil {
 		return err
 	}
 
+	if !responseBody.Composite {
+		w.Header().Set("ETag", versionETag(responseBody.EnvironmentID, responseBody.Version))
+	}
+	if responseBody.NotModified {
+		w.WriteHeader(http.StatusNotModified)
+		return nil
+	}
+
 	helpers.WriteResponse(w, http.StatusOK, responseBody)
 	return nil
 }
+
+func (handler *Handler) ProtectEnv(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.ProtectEnvRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.EnvName == "" {
+		return errors.Validation(map[string]string{"env_name": "env_name is required"})
+	}
+
+	resp, err := handler.Services.Env.SetProtection(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) ListEnvCandidates(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvCandidatesRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	resp, err := handler.Services.Env.ListCandidates(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) GetEnvCandidate(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvCandidateGetRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	resp, err := handler.Services.Env.GetCandidate(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) ApproveEnvCandidate(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvCandidateReviewRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	candidate, err := handler.Services.Env.ApproveCandidate(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvCandidateReviewResponse{
+		Message:   "env change approved and published",
+		Candidate: *candidate,
+	})
+	return nil
+}
+
+func (handler *Handler) RejectEnvCandidate(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvCandidateReviewRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	candidate, err := handler.Services.Env.RejectCandidate(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvCandidateReviewResponse{
+		Message:   "env change rejected",
+		Candidate: *candidate,
+	})
+	return nil
+}
+
+func (handler *Handler) RollbackEnv(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.RollbackEnvRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if requestBody.ToVersion < 1 {
+		validationErrors["to_version"] = "to_version is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	expectedVersion, expectedEnvironmentID, err := expectedVersionFromRequest(r, requestBody.ExpectedVersion)
+	if err != nil {
+		return err
+	}
+	requestBody.ExpectedVersion, requestBody.ExpectedEnvironmentID = expectedVersion, expectedEnvironmentID
+
+	resp, err := handler.Services.Env.Rollback(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, pushStatusCode(resp.Status), resp)
+	return nil
+}
+
+func (handler *Handler) PromoteEnv(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.PromoteEnvRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.FromEnv == "" {
+		validationErrors["from_env"] = "from_env is required"
+	}
+	if requestBody.ToEnv == "" {
+		validationErrors["to_env"] = "to_env is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	expectedVersion, expectedEnvironmentID, err := expectedVersionFromRequest(r, requestBody.ExpectedVersion)
+	if err != nil {
+		return err
+	}
+	requestBody.ExpectedVersion, requestBody.ExpectedEnvironmentID = expectedVersion, expectedEnvironmentID
+
+	resp, err := handler.Services.Env.Promote(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, pushStatusCode(resp.Status), resp)
+	return nil
+}
+
+func (handler *Handler) DiffEnv(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.DiffEnvRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if requestBody.FromVersion < 1 {
+		validationErrors["from_version"] = "from_version is required"
+	}
+	if requestBody.ToVersion < 1 {
+		validationErrors["to_version"] = "to_version is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Env.Diff(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) ListEnvVersions(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.ListEnvVersionsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if requestBody.From != nil && requestBody.To != nil && requestBody.To.Before(*requestBody.From) {
+		validationErrors["to"] = "to must not be before from"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Env.ListVersions(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) FetchEnvVersions(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.FetchEnvVersionsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if len(requestBody.Versions) == 0 || len(requestBody.Versions) > maxFetchVersions {
+		validationErrors["versions"] = "versions must list between 1 and 50 versions"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Env.FetchVersions(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) SearchEnvVersions(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.SearchEnvVersionsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	resp, err := handler.Services.Env.SearchVersions(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+// pushStatusCode answers held pushes with 202 since nothing is published yet.
+func pushStatusCode(status string) int {
+	if status == config.EnvVersionPending {
+		return http.StatusAccepted
+	}
+	return http.StatusCreated
+}
+
+// expectedVersionFromRequest prefers expected_version from the body and falls
+// back to an If-Match header carrying the ETag of a pull, or just the version,
+// e.g. If-Match: "7". An ETag also names the environment the pull was from.
+func expectedVersionFromRequest(r *http.Request, fromBody *int32) (*int32, *uuid.UUID, error) {
+	if fromBody != nil {
+		return fromBody, nil, nil
+	}
+
+	ifMatch := strings.TrimSpace(r.Header.Get("If-Match"))
+	if ifMatch == "" || ifMatch == "*" {
+		return nil, nil, nil
+	}
+
+	ifMatch = strings.Trim(strings.TrimPrefix(ifMatch, "W/"), `"`)
+	if tag, ok := parseEnvTag(ifMatch); ok {
+		return &tag.Version, &tag.EnvironmentID, nil
+	}
+
+	version, err := strconv.ParseInt(ifMatch, 10, 32)
+	if err != nil || version < 0 {
+		return nil, nil, errors.BadRequest("Invalid If-Match header", "Send the ETag of your last pull")
+	}
+
+	expected := int32(version)
+	return &expected, nil, nil
+}
+
+// knownTagsFromRequest reads the tags of an If-None-Match header. Tags are the
+// ones pulls send in ETag, so a client can echo them back unchanged; "*" and
+// tags that are not ours are ignored, they never match.
+func knownTagsFromRequest(r *http.Request) []config.EnvTag {
+	var tags []config.EnvTag
+	for _, tag := range strings.Split(r.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if len(tag) < 2 || tag[0] != '"' || tag[len(tag)-1] != '"' {
+			continue
+		}
+		if parsed, ok := parseEnvTag(tag[1 : len(tag)-1]); ok {
+			tags = append(tags, parsed)
+		}
+	}
+	return tags
+}
+
+// versionETag is the strong validator of a published version. It names the
+// environment as well as the version, so it never matches an environment that
+// was deleted and created again; If-Match accepts the same value.
+func versionETag(environmentID uuid.UUID, version int32) string {
+	return `"` + environmentID.String() + "." + strconv.Itoa(int(version)) + `"`
+}
+
+// parseEnvTag reads the unquoted value of a tag made by versionETag.
+func parseEnvTag(value string) (config.EnvTag, bool) {
+	id, version, ok := strings.Cut(value, ".")
+	if !ok {
+		return config.EnvTag{}, false
+	}
+	environmentID, err := uuid.Parse(id)
+	if err != nil {
+		return config.EnvTag{}, false
+	}
+	number, err := strconv.ParseInt(version, 10, 32)
+	if err != nil || number < 1 {
+		return config.EnvTag{}, false
+	}
+	return config.EnvTag{EnvironmentID: environmentID, Version: int32(number)}, true
+}
+
+// maxFetchVersions caps how many versions one fetch may return with ciphertext.
+const maxFetchVersions = 50
+
+// maxBatchItems caps how many environments one batch fetch may read.
+const maxBatchItems = 50
+
+// maxConsumerLength caps the consumer name a pull may report.
+const maxConsumerLength = 200
+
+func validateConsumer(consumer *string) error {
+	if consumer == nil {
+		return nil
+	}
+	if *consumer == "" || utf8.RuneCountInString(*consumer) > maxConsumerLength {
+		return errors.Validation(map[string]string{"consumer": "consumer must be 1-200 characters"})
+	}
+	return nil
+}
+
+// Limits on client-supplied version metadata.
+const (
+	maxSummaryKeys         = 5000
+	maxMetadataMessage     = 1000
+	maxMetadataTags        = 20
+	maxMetadataAnnotations = 50
+	maxAnnotationValue     = 1024
+)
+
+var metadataTagPattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+// validateMetadata checks version metadata on write so every stored version
+// decodes the same way when read back.
+func validateMetadata(metadata config.Metadata) error {
+	validationErrors := make(map[string]string)
+
+	if utf8.RuneCountInString(metadata.Message) > maxMetadataMessage {
+		validationErrors["metadata.message"] = "message must be at most 1000 characters"
+	}
+
+	switch metadata.Source {
+	case "", config.MetadataSourceCLI, config.MetadataSourceCI, config.MetadataSourceAPI, config.MetadataSourceImport:
+	default:
+		validationErrors["metadata.source"] = "source must be one of cli, ci, api or import"
+	}
+
+	if len(metadata.ClientVersion) > 64 {
+		validationErrors["metadata.client_version"] = "client_version must be at most 64 characters"
+	}
+
+	if len(metadata.Tags) > maxMetadataTags {
+		validationErrors["metadata.tags"] = "at most 20 tags are allowed"
+	}
+	seenTags := make(map[string]bool, len(metadata.Tags))
+	for _, tag := range metadata.Tags {
+		if !metadataTagPattern.MatchString(tag) || seenTags[tag] {
+			validationErrors["metadata.tags"] = "tags must be unique and 1-63 lowercase letters, digits, '.', '_' or '-'"
+			break
+		}
+		seenTags[tag] = true
+	}
+
+	if len(metadata.Annotations) > maxMetadataAnnotations {
+		validationErrors["metadata.annotations"] = "at most 50 annotations are allowed"
+	}
+	for key, value := range metadata.Annotations {
+		if key == "" || len(key) > 64 || len(value) > maxAnnotationValue {
+			validationErrors["metadata.annotations"] = "annotation keys must be 1-64 characters and values at most 1024"
+			break
+		}
+	}
+
+	if metadata.ExpiresAt != nil && metadata.ExpiresAt.IsZero() {
+		validationErrors["metadata.expires_at"] = "expires_at must be a valid time"
+	}
+
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	return validateKeySummary(metadata.Keys)
+}
+
+func validateKeySummary(keys []config.KeySummary) error {
+	if len(keys) > maxSummaryKeys {
+		return errors.Validation(map[string]string{"metadata.keys": "at most 5000 keys can be summarized"})
+	}
+
+	seen := make(map[string]bool, len(keys))
+	for _, summary := range keys {
+		if len(summary.KeyHMAC) == 0 || len(summary.KeyHMAC) > maxKeyHMACSize || len(summary.ValueFingerprint) == 0 || len(summary.ValueFingerprint) > maxKeyHMACSize {
+			return errors.Validation(map[string]string{"metadata.keys": "key_hmac and value_fingerprint must be 1-64 bytes"})
+		}
+		if seen[string(summary.KeyHMAC)] {
+			return errors.Validation(map[string]string{"metadata.keys": "each key_hmac may only appear once"})
+		}
+		if summary.ExpiresAt != nil && summary.ExpiresAt.IsZero() {
+			return errors.Validation(map[string]string{"metadata.keys": "expires_at must be a valid time"})
+		}
+		seen[string(summary.KeyHMAC)] = true
+	}
+	return nil
+}
+
+func (handler *Handler) ShredEnv(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.ShredEnvRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if len(requestBody.Versions) == 0 {
+		validationErrors["versions"] = "versions is required"
+	}
+	if strings.TrimSpace(requestBody.Reason) == "" {
+		validationErrors["reason"] = "reason is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Env.Shred(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) BatchFetchEnv(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.BatchEnvRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.UserID == uuid.Nil {
+		validationErrors["user_id"] = "user_id is required"
+	}
+	if len(requestBody.Items) == 0 {
+		validationErrors["items"] = "items is required"
+	}
+	if len(requestBody.Items) > maxBatchItems {
+		validationErrors["items"] = "at most " + strconv.Itoa(maxBatchItems) + " items per batch"
+	}
+	for i, item := range requestBody.Items {
+		field := "items[" + strconv.Itoa(i) + "]"
+		if item.ProjectID == uuid.Nil {
+			validationErrors[field+".project_id"] = "project_id is required"
+		}
+		if item.EnvName == "" {
+			validationErrors[field+".env_name"] = "env_name is required"
+		}
+		if item.Version != nil && item.Label != nil {
+			validationErrors[field+".label"] = "send either version or label, not both"
+		}
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Env.BatchFetch(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) EnvDrift(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvDriftRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.UserID == uuid.Nil {
+		validationErrors["user_id"] = "user_id is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Env.Drift(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) EnvExpiring(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvExpiringRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.UserID == uuid.Nil {
+		validationErrors["user_id"] = "user_id is required"
+	}
+	if requestBody.WithinDays != nil && (*requestBody.WithinDays < 0 || *requestBody.WithinDays > 365) {
+		validationErrors["within_days"] = "within_days must be between 0 and 365"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Env.Expiring(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+// maxKeyHMACSize bounds blinded key names; HMAC-SHA256 and SHA-512 fit.
+const maxKeyHMACSize = 64
+
+func (handler *Handler) PushEnvEntries(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.PushEnvEntriesRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if err := validatePushEnvEntries(requestBody); err != nil {
+		return err
+	}
+	if err := validateMetadata(requestBody.Metadata); err != nil {
+		return err
+	}
+
+	expectedVersion, expectedEnvironmentID, err := expectedVersionFromRequest(r, requestBody.ExpectedVersion)
+	if err != nil {
+		return err
+	}
+	requestBody.ExpectedVersion, requestBody.ExpectedEnvironmentID = expectedVersion, expectedEnvironmentID
+
+	resp, err := handler.Services.Env.PushEntries(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, pushStatusCode(resp.Status), resp)
+	return nil
+}
+
+func (handler *Handler) GetEnvEntryHistory(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvEntryHistoryRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if len(requestBody.KeyHMAC) == 0 {
+		validationErrors["key_hmac"] = "key_hmac is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Env.EntryHistory(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func validatePushEnvEntries(requestBody config.PushEnvEntriesRequest) error {
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+
+	if requestBody.Replace {
+		if len(requestBody.WrappedDEK) == 0 || len(requestBody.DekNonce) == 0 {
+			validationErrors["wrapped_dek"] = "wrapped_dek and dek_nonce are required with replace"
+		}
+		if len(requestBody.Delete) > 0 {
+			validationErrors["delete"] = "delete cannot be combined with replace, leave the keys out instead"
+		}
+	} else {
+		if len(requestBody.WrappedDEK) > 0 {
+			validationErrors["wrapped_dek"] = "partial updates reuse the current DEK, set replace to rotate it"
+		}
+		if len(requestBody.Set) == 0 && len(requestBody.Delete) == 0 {
+			validationErrors["set"] = "set or delete must contain at least one entry"
+		}
+	}
+
+	seen := make(map[string]bool)
+	for _, write := range requestBody.Set {
+		if len(write.KeyHMAC) == 0 || len(write.KeyHMAC) > maxKeyHMACSize {
+			validationErrors["set"] = "every entry needs a key_hmac of at most 64 bytes"
+			break
+		}
+		if len(write.NameCiphertext) == 0 || len(write.NameNonce) == 0 || len(write.ValueCiphertext) == 0 || len(write.ValueNonce) == 0 {
+			validationErrors["set"] = "every entry needs name and value ciphertexts with their nonces"
+			break
+		}
+		if seen[string(write.KeyHMAC)] {
+			validationErrors["set"] = "each key may only appear once per push"
+			break
+		}
+		seen[string(write.KeyHMAC)] = true
+	}
+	for _, del := range requestBody.Delete {
+		if len(del.KeyHMAC) == 0 || len(del.KeyHMAC) > maxKeyHMACSize {
+			validationErrors["delete"] = "every entry needs a key_hmac of at most 64 bytes"
+			break
+		}
+		if seen[string(del.KeyHMAC)] {
+			validationErrors["delete"] = "each key may only appear once per push"
+			break
+		}
+		seen[string(del.KeyHMAC)] = true
+	}
+
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+	return nil
+}
@@ -0,0 +1,89 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+func (handler *Handler) ListEnvLabels(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvLabelsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.EnvName == "" {
+		return errors.Validation(map[string]string{"env_name": "env_name is required"})
+	}
+
+	resp, err := handler.Services.Env.ListLabels(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) SetEnvLabel(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvLabelSetRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if requestBody.Version < 1 {
+		validationErrors["version"] = "version is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	label, err := handler.Services.Env.SetLabel(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvLabelResponse{
+		Message: "Label updated",
+		Label:   *label,
+	})
+	return nil
+}
+
+func (handler *Handler) DeleteEnvLabel(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvLabelDeleteRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if requestBody.Label == "" {
+		validationErrors["label"] = "label is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	if err := handler.Services.Env.DeleteLabel(r.Context(), requestBody); err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvLabelDeleteResponse{Message: "Label deleted"})
+	return nil
+}
@@ -0,0 +1,520 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+func (handler *Handler) ListEnvironments(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentListRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ProjectID == uuid.Nil {
+		return errors.Validation(map[string]string{"project_id": "project_id is required"})
+	}
+
+	resp, err := handler.Services.Environments.List(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) CreateEnvironment(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentCreateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ProjectID == uuid.Nil {
+		return errors.Validation(map[string]string{"project_id": "project_id is required"})
+	}
+
+	environment, err := handler.Services.Environments.Create(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusCreated, config.EnvironmentResponse{
+		Message:     "Environment created",
+		Environment: *environment,
+	})
+	return nil
+}
+
+func (handler *Handler) RenameEnvironment(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentRenameRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	environment, err := handler.Services.Environments.Rename(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvironmentResponse{
+		Message:     "Environment renamed",
+		Environment: *environment,
+	})
+	return nil
+}
+
+func (handler *Handler) ArchiveEnvironment(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentArchiveRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	environment, err := handler.Services.Environments.Archive(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvironmentResponse{
+		Message:     "Environment archived",
+		Environment: *environment,
+	})
+	return nil
+}
+
+func (handler *Handler) SetEnvironmentParent(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentParentRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if requestBody.Parent != nil && *requestBody.Parent == "" {
+		validationErrors["parent"] = "parent must not be empty"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	environment, err := handler.Services.Environments.SetParent(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	message := "Environment parent updated"
+	if requestBody.Parent == nil {
+		message = "Environment no longer inherits"
+	}
+	helpers.WriteResponse(w, http.StatusOK, config.EnvironmentResponse{
+		Message:     message,
+		Environment: *environment,
+	})
+	return nil
+}
+
+func (handler *Handler) RestoreEnvironment(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentRestoreRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	environment, err := handler.Services.Environments.Restore(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvironmentResponse{
+		Message:     "Environment restored",
+		Environment: *environment,
+	})
+	return nil
+}
+
+func (handler *Handler) DeleteEnvironment(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentDeleteRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if requestBody.Confirm == "" {
+		validationErrors["confirm"] = "confirm is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Environments.Delete(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) LockEnvironment(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentLockRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if strings.TrimSpace(requestBody.Reason) == "" {
+		validationErrors["reason"] = "reason is required"
+	}
+	if requestBody.Until != nil && !requestBody.Until.After(time.Now()) {
+		validationErrors["until"] = "until must be in the future"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	environment, err := handler.Services.Environments.Lock(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvironmentResponse{
+		Message:     "Environment locked",
+		Environment: *environment,
+	})
+	return nil
+}
+
+func (handler *Handler) UnlockEnvironment(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentUnlockRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	environment, err := handler.Services.Environments.Unlock(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvironmentResponse{
+		Message:     "Environment unlocked",
+		Environment: *environment,
+	})
+	return nil
+}
+
+func (handler *Handler) UpdateEnvironmentSettings(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentSettingsRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ProjectID == uuid.Nil {
+		return errors.Validation(map[string]string{"project_id": "project_id is required"})
+	}
+
+	resp, err := handler.Services.Environments.SetAutoCreate(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) SetEnvironmentRetention(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentRetentionRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name != nil && *requestBody.Name == "" {
+		validationErrors["name"] = "name must not be empty"
+	}
+	if requestBody.KeepVersions != nil && *requestBody.KeepVersions < 1 {
+		validationErrors["keep_versions"] = "keep_versions must be at least 1"
+	}
+	if requestBody.KeepDays != nil && *requestBody.KeepDays < 1 {
+		validationErrors["keep_days"] = "keep_days must be at least 1"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Environments.SetRetention(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) ListEnvironmentReferences(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentReferencesRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	resp, err := handler.Services.Environments.References(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) AddEnvironmentReference(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentReferenceAddRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if requestBody.RefProjectID == uuid.Nil {
+		validationErrors["ref_project_id"] = "ref_project_id is required"
+	}
+	if requestBody.RefEnvName == "" {
+		validationErrors["ref_env_name"] = "ref_env_name is required"
+	}
+	if requestBody.KeyHMAC != nil && (len(requestBody.KeyHMAC) == 0 || len(requestBody.KeyHMAC) > maxKeyHMACSize) {
+		validationErrors["key_hmac"] = "key_hmac must be 1-64 bytes"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	reference, err := handler.Services.Environments.AddReference(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusCreated, config.EnvironmentReferenceResponse{
+		Message:   "Reference added",
+		Reference: *reference,
+	})
+	return nil
+}
+
+func (handler *Handler) RemoveEnvironmentReference(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvironmentReferenceRemoveRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.Name == "" {
+		validationErrors["name"] = "name is required"
+	}
+	if requestBody.ID == uuid.Nil {
+		validationErrors["id"] = "id is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	if err := handler.Services.Environments.RemoveReference(r.Context(), requestBody); err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvironmentReferenceRemoveResponse{Message: "Reference removed"})
+	return nil
+}
+
+// maxSchemaVariables caps how many variables one environment schema may define.
+const maxSchemaVariables = 500
+
+func (handler *Handler) GetEnvSchema(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvSchemaRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ProjectID == uuid.Nil {
+		return errors.Validation(map[string]string{"project_id": "project_id is required"})
+	}
+
+	schema, err := handler.Services.Environments.Schema(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, schema)
+	return nil
+}
+
+func (handler *Handler) SetEnvSchema(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvSchemaSetRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if len(requestBody.Schema.Variables) > maxSchemaVariables {
+		validationErrors["schema.variables"] = "at most 500 variables are allowed"
+	}
+	if requestBody.ExpectedVersion != nil && *requestBody.ExpectedVersion < 0 {
+		validationErrors["expected_version"] = "expected_version must not be negative"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	schema, err := handler.Services.Environments.SetSchema(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.EnvSchemaResponse{
+		Message: "Schema updated",
+		Schema:  *schema,
+	})
+	return nil
+}
+
+func (handler *Handler) EnvSchemaHistory(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EnvSchemaHistoryRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ProjectID == uuid.Nil {
+		return errors.Validation(map[string]string{"project_id": "project_id is required"})
+	}
+
+	resp, err := handler.Services.Environments.SchemaHistory(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
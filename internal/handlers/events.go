@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+)
+
+// Events are read from the event log rather than an in-process channel, so a
+// stream sees changes made through any replica.
+const (
+	eventPollInterval      = 2 * time.Second
+	eventHeartbeatInterval = 15 * time.Second
+	eventReconnectDelay    = 3 * time.Second
+)
+
+// StreamEvents streams change events as Server-Sent Events until the client
+// disconnects or its session expires.
+func (handler *Handler) StreamEvents(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.EventStreamRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.UserID == uuid.Nil {
+		return errors.Validation(map[string]string{"user_id": "user_id is required"})
+	}
+
+	ctx := r.Context()
+	if err := handler.Services.Events.RequireSessionUser(ctx, requestBody.UserID); err != nil {
+		return err
+	}
+
+	if lastEventID := strings.TrimSpace(r.Header.Get("Last-Event-ID")); lastEventID != "" {
+		id, err := strconv.ParseInt(lastEventID, 10, 64)
+		if err != nil || id < 0 {
+			return errors.BadRequest("Invalid Last-Event-ID header", "Send the id of the last event you received")
+		}
+		requestBody.LastEventID = &id
+	}
+
+	sessionID, _ := ctx.Value("session_id").(uuid.UUID)
+
+	var cursor int64
+	if requestBody.LastEventID != nil {
+		cursor = *requestBody.LastEventID
+	} else {
+		tail, err := handler.Services.Events.Tail(ctx)
+		if err != nil {
+			return err
+		}
+		cursor = tail
+	}
+
+	// the first page is read before the stream starts, so errors still render as JSON
+	events, more, err := handler.Services.Events.List(ctx, requestBody, cursor)
+	if err != nil {
+		return err
+	}
+
+	controller := http.NewResponseController(w)
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	fmt.Fprintf(w, "retry: %d\n\n", eventReconnectDelay.Milliseconds())
+
+	poll := time.NewTicker(eventPollInterval)
+	defer poll.Stop()
+	heartbeat := time.NewTicker(eventHeartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		for _, event := range events {
+			if err := writeEvent(w, event); err != nil {
+				return nil
+			}
+			cursor = event.ID
+		}
+		if err := controller.Flush(); err != nil {
+			return nil
+		}
+
+		// the client is still catching up, read the next page without waiting
+		if more {
+			if events, more, err = handler.Services.Events.List(ctx, requestBody, cursor); err != nil {
+				log.Printf("event stream read failed: user=%s err=%v", requestBody.UserID, err)
+				return nil
+			}
+			continue
+		}
+		events = nil
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-heartbeat.C:
+			// a stream must not outlive the session that opened it
+			if err := handler.Services.SessionService.GetSession(ctx, sessionID); err != nil {
+				fmt.Fprint(w, "event: session.expired\ndata: {}\n\n")
+				controller.Flush()
+				return nil
+			}
+			fmt.Fprint(w, ": keepalive\n\n")
+		case <-poll.C:
+			if events, more, err = handler.Services.Events.List(ctx, requestBody, cursor); err != nil {
+				log.Printf("event stream read failed: user=%s err=%v", requestBody.UserID, err)
+				return nil
+			}
+		}
+	}
+}
+
+func writeEvent(w io.Writer, event config.Event) error {
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
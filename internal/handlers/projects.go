@@ -108,6 +108,39 @@ func (handler *Handler) SetUserAccess(w http.ResponseWriter, r *http.Request) er
 	return nil
 }
 
+func (handler *Handler) RenewMembership(w http.ResponseWriter, r *http.Request) error {
+
+	var requestBody config.RenewMembershipRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.ProjectID == uuid.Nil {
+		validationErrors["project_id"] = "project_id is required"
+	}
+	if requestBody.UserID == uuid.Nil {
+		validationErrors["user_id"] = "user_id is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	expiresAt, err := handler.Services.Projects.RenewMembership(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	var responseBody = config.RenewMembershipResponse{
+		Message:   "Membership renewed successfully!",
+		ExpiresAt: expiresAt,
+	}
+	helpers.WriteResponse(w, http.StatusOK, responseBody)
+	return nil
+}
+
 func (handler *Handler) GetUserProjectKeys(w http.ResponseWriter, r *http.Request) error {
 
 	var requestBody config.GetUserProjectRequest
@@ -126,3 +126,33 @@ func (handler *Handler) GetPerms(w http.ResponseWriter, r *http.Request) error {
 	helpers.WriteResponse(w, http.StatusOK, responseBody)
 	return nil
 }
+
+func (handler *Handler) PinServiceRole(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.ServiceRolePinRequest
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.RepoPrincipal == "" {
+		validationErrors["repo_principal"] = "repo_principal is required"
+	}
+	if requestBody.EnvName == "" {
+		validationErrors["env_name"] = "env_name is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	if err := handler.Services.ServiceRoles.Pin(r.Context(), requestBody); err != nil {
+		return err
+	}
+
+	message := "Service role now follows the latest version"
+	if requestBody.Label != nil {
+		message = "Service role pinned to label " + *requestBody.Label
+	}
+	helpers.WriteResponse(w, http.StatusOK, config.ServiceRolePinResponse{Message: message})
+	return nil
+}
@@ -0,0 +1,106 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+// Limits on shares. The payload is meant for a handful of credentials, not files.
+const (
+	maxShareCiphertext = 64 * 1024
+	maxShareViews      = 100
+	maxShareMinutes    = 7 * 24 * 60
+)
+
+func (handler *Handler) CreateShare(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.ShareCreateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.UserID == uuid.Nil {
+		validationErrors["user_id"] = "user_id is required"
+	}
+	if len(requestBody.Ciphertext) == 0 || len(requestBody.Ciphertext) > maxShareCiphertext {
+		validationErrors["ciphertext"] = "ciphertext must be 1 byte to 64 KiB"
+	}
+	if len(requestBody.Nonce) == 0 {
+		validationErrors["nonce"] = "nonce is required"
+	}
+	if requestBody.MaxViews != nil && (*requestBody.MaxViews < 1 || *requestBody.MaxViews > maxShareViews) {
+		validationErrors["max_views"] = "max_views must be between 1 and 100"
+	}
+	if requestBody.ExpiresInMinutes != nil && (*requestBody.ExpiresInMinutes < 1 || *requestBody.ExpiresInMinutes > maxShareMinutes) {
+		validationErrors["expires_in_minutes"] = "expires_in_minutes must be between 1 and 10080"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	share, err := handler.Services.Shares.Create(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusCreated, config.ShareCreateResponse{
+		Message: "Share created",
+		Share:   *share,
+	})
+	return nil
+}
+
+func (handler *Handler) ViewShare(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.ShareViewRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.ID == uuid.Nil {
+		return errors.Validation(map[string]string{"id": "id is required"})
+	}
+
+	resp, err := handler.Services.Shares.View(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) RevokeShare(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.ShareRevokeRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	validationErrors := make(map[string]string)
+	if requestBody.UserID == uuid.Nil {
+		validationErrors["user_id"] = "user_id is required"
+	}
+	if requestBody.ID == uuid.Nil {
+		validationErrors["id"] = "id is required"
+	}
+	if len(validationErrors) > 0 {
+		return errors.Validation(validationErrors)
+	}
+
+	if err := handler.Services.Shares.Revoke(r.Context(), requestBody); err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.ShareRevokeResponse{Message: "Share revoked"})
+	return nil
+}
@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/url"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+// maxWebhookURL caps the length of a webhook endpoint URL.
+const maxWebhookURL = 2048
+
+func (handler *Handler) CreateWebhook(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.WebhookCreateRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if err := validateWebhook(requestBody); err != nil {
+		return err
+	}
+
+	resp, err := handler.Services.Webhooks.Create(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusCreated, resp)
+	return nil
+}
+
+func (handler *Handler) ListWebhooks(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.WebhookListRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	resp, err := handler.Services.Webhooks.List(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) DeleteWebhook(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.WebhookDeleteRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.WebhookID == uuid.Nil {
+		return errors.Validation(map[string]string{"webhook_id": "webhook_id is required"})
+	}
+
+	if err := handler.Services.Webhooks.Delete(r.Context(), requestBody); err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, config.WebhookDeleteResponse{Message: "Webhook deleted"})
+	return nil
+}
+
+func (handler *Handler) ListWebhookDeliveries(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.WebhookDeliveriesRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.WebhookID == uuid.Nil {
+		return errors.Validation(map[string]string{"webhook_id": "webhook_id is required"})
+	}
+	if status := requestBody.Status; status != nil {
+		switch *status {
+		case config.WebhookDeliveryPending, config.WebhookDeliveryDelivered, config.WebhookDeliveryDead:
+		default:
+			return errors.Validation(map[string]string{"status": "status must be pending, delivered or dead"})
+		}
+	}
+
+	resp, err := handler.Services.Webhooks.Deliveries(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusOK, resp)
+	return nil
+}
+
+func (handler *Handler) RedeliverWebhook(w http.ResponseWriter, r *http.Request) error {
+	var requestBody config.WebhookRedeliverRequest
+
+	if err := json.NewDecoder(r.Body).Decode(&requestBody); err != nil {
+		return errors.BadRequest("Invalid request body", "")
+	}
+	defer r.Body.Close()
+
+	if requestBody.DeliveryID == uuid.Nil {
+		return errors.Validation(map[string]string{"delivery_id": "delivery_id is required"})
+	}
+
+	resp, err := handler.Services.Webhooks.Redeliver(r.Context(), requestBody)
+	if err != nil {
+		return err
+	}
+
+	helpers.WriteResponse(w, http.StatusAccepted, resp)
+	return nil
+}
+
+func validateWebhook(req config.WebhookCreateRequest) error {
+	fields := map[string]string{}
+
+	endpoint, err := url.Parse(req.URL)
+	switch {
+	case req.URL == "":
+		fields["url"] = "url is required"
+	case len(req.URL) > maxWebhookURL:
+		fields["url"] = "url is too long"
+	case err != nil || endpoint.Scheme != "https" || endpoint.Host == "":
+		fields["url"] = "url must be an absolute https URL"
+	case endpoint.User != nil:
+		fields["url"] = "url must not contain credentials"
+	}
+
+	if len(req.Events) == 0 {
+		fields["events"] = "subscribe to at least one event"
+	}
+	for _, event := range req.Events {
+		if !config.WebhookActions[event] {
+			fields["events"] = event + " is not a subscribable event"
+			break
+		}
+	}
+
+	if len(fields) > 0 {
+		return errors.Validation(fields)
+	}
+	return nil
+}
@@ -1,26 +1,25 @@
 package server
 
 import (
-	"database/sql"
 	"net/http"
 
-	"github.com/vijayvenkatj/envcrypt/database"
 	"github.com/vijayvenkatj/envcrypt/internal/handlers"
 	"github.com/vijayvenkatj/envcrypt/internal/services"
 )
 
-func NewRouter(dbQueries *database.Queries, db *sql.DB, debug bool) *http.ServeMux {
+func NewRouter(service *services.Services, debug bool) *http.ServeMux {
 	router := http.NewServeMux()
 
-	auditService := services.NewAuditService(dbQueries)
-	service := services.NewServices(dbQueries, auditService, db)
 	handler := handlers.NewHandler(service)
 
 	router.Handle("/users/", http.StripPrefix("/users", UserRouter(handler, debug)))
 	router.Handle("/projects/", http.StripPrefix("/projects", ProjectRouter(handler, debug)))
 	router.Handle("/env/", http.StripPrefix("/env", EnvRouter(handler, debug)))
+	router.Handle("/environments/", http.StripPrefix("/environments", EnvironmentRouter(handler, debug)))
 	router.Handle("/service_role/", http.StripPrefix("/service_role", ServiceRoleRouter(handler, debug)))
 	router.Handle("/oidc/", http.StripPrefix("/oidc", OIDCRouter(handler, debug)))
+	router.Handle("/events/", http.StripPrefix("/events", EventRouter(handler, debug)))
+	router.Handle("/shares/", http.StripPrefix("/shares", ShareRouter(handler, debug)))
 
 	return router
 }
@@ -50,6 +49,7 @@ func ProjectRouter(handler *handlers.Handler, debug bool) *http.ServeMux {
 	projectRouter.HandleFunc("POST /delete", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.DeleteProject)))
 	projectRouter.HandleFunc("POST /addUser", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.AddUserToProject)))
 	projectRouter.HandleFunc("POST /access", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.SetUserAccess)))
+	projectRouter.HandleFunc("POST /access/renew", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RenewMembership)))
 	projectRouter.HandleFunc("POST /rotate/init", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RotateInit)))
 	projectRouter.HandleFunc("POST /rotate/commit", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RotateCommit)))
 
@@ -57,6 +57,28 @@ func ProjectRouter(handler *handlers.Handler, debug bool) *http.ServeMux {
 	projectRouter.HandleFunc("POST /snapshot/import", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.SnapshotImport)))
 	projectRouter.HandleFunc("POST /audit", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.HandleProjectAuditLogs)))
 
+	projectRouter.HandleFunc("POST /access-requests/create", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.CreateAccessRequest)))
+	projectRouter.HandleFunc("POST /access-requests/list", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListAccessRequests)))
+	projectRouter.HandleFunc("POST /access-requests/mine", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListMyAccessRequests)))
+	projectRouter.HandleFunc("POST /access-requests/approve", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ApproveAccessRequest)))
+	projectRouter.HandleFunc("POST /access-requests/reject", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RejectAccessRequest)))
+	projectRouter.HandleFunc("POST /access-requests/cancel", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.CancelAccessRequest)))
+
+	projectRouter.HandleFunc("POST /break-glass/policy", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.SetBreakGlassPolicy)))
+	projectRouter.HandleFunc("POST /break-glass/policy/get", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.GetBreakGlassPolicy)))
+	projectRouter.HandleFunc("POST /break-glass/eligible/add", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.AddBreakGlassUser)))
+	projectRouter.HandleFunc("POST /break-glass/eligible/remove", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RemoveBreakGlassUser)))
+	projectRouter.HandleFunc("POST /break-glass/request", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RequestBreakGlass)))
+	projectRouter.HandleFunc("POST /break-glass/pending", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListPendingBreakGlass)))
+	projectRouter.HandleFunc("POST /break-glass/fulfill", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.FulfillBreakGlass)))
+	projectRouter.HandleFunc("POST /break-glass/revoke", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RevokeBreakGlass)))
+
+	projectRouter.HandleFunc("POST /webhooks/create", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.CreateWebhook)))
+	projectRouter.HandleFunc("POST /webhooks/list", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListWebhooks)))
+	projectRouter.HandleFunc("POST /webhooks/delete", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.DeleteWebhook)))
+	projectRouter.HandleFunc("POST /webhooks/deliveries", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListWebhookDeliveries)))
+	projectRouter.HandleFunc("POST /webhooks/redeliver", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RedeliverWebhook)))
+
 	return projectRouter
 }
 
@@ -65,14 +87,59 @@ func EnvRouter(handler *handlers.Handler, debug bool) *http.ServeMux {
 
 	envRouter.HandleFunc("POST /search", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.GetEnv)))
 	envRouter.HandleFunc("POST /search/all", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.GetEnvVersions)))
+	envRouter.HandleFunc("POST /batch", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.BatchFetchEnv)))
 	envRouter.HandleFunc("POST /create", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.AddEnv)))
 	envRouter.HandleFunc("POST /update", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.UpdateEnv)))
+	envRouter.HandleFunc("POST /labels", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListEnvLabels)))
+	envRouter.HandleFunc("POST /labels/set", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.SetEnvLabel)))
+	envRouter.HandleFunc("POST /labels/delete", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.DeleteEnvLabel)))
+	envRouter.HandleFunc("POST /versions", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListEnvVersions)))
+	envRouter.HandleFunc("POST /versions/fetch", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.FetchEnvVersions)))
+	envRouter.HandleFunc("POST /versions/search", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.SearchEnvVersions)))
+	envRouter.HandleFunc("POST /diff", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.DiffEnv)))
+	envRouter.HandleFunc("POST /drift", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.EnvDrift)))
+	envRouter.HandleFunc("POST /expiring", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.EnvExpiring)))
+	envRouter.HandleFunc("POST /rollback", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RollbackEnv)))
+	envRouter.HandleFunc("POST /promote", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.PromoteEnv)))
+	envRouter.HandleFunc("POST /shred", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ShredEnv)))
+	envRouter.HandleFunc("POST /entries/push", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.PushEnvEntries)))
+	envRouter.HandleFunc("POST /entries/history", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.GetEnvEntryHistory)))
+
+	envRouter.HandleFunc("POST /protect", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ProtectEnv)))
+	envRouter.HandleFunc("POST /candidates", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListEnvCandidates)))
+	envRouter.HandleFunc("POST /candidates/get", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.GetEnvCandidate)))
+	envRouter.HandleFunc("POST /candidates/approve", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ApproveEnvCandidate)))
+	envRouter.HandleFunc("POST /candidates/reject", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RejectEnvCandidate)))
 
 	envRouter.HandleFunc("POST /ci/search", WithErrors(debug, handler.GetCIEnv))
 
 	return envRouter
 }
 
+func EnvironmentRouter(handler *handlers.Handler, debug bool) *http.ServeMux {
+	environmentRouter := http.NewServeMux()
+
+	environmentRouter.HandleFunc("POST /list", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListEnvironments)))
+	environmentRouter.HandleFunc("POST /create", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.CreateEnvironment)))
+	environmentRouter.HandleFunc("POST /rename", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RenameEnvironment)))
+	environmentRouter.HandleFunc("POST /parent", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.SetEnvironmentParent)))
+	environmentRouter.HandleFunc("POST /references", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListEnvironmentReferences)))
+	environmentRouter.HandleFunc("POST /references/add", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.AddEnvironmentReference)))
+	environmentRouter.HandleFunc("POST /references/remove", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RemoveEnvironmentReference)))
+	environmentRouter.HandleFunc("POST /archive", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ArchiveEnvironment)))
+	environmentRouter.HandleFunc("POST /restore", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RestoreEnvironment)))
+	environmentRouter.HandleFunc("POST /delete", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.DeleteEnvironment)))
+	environmentRouter.HandleFunc("POST /lock", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.LockEnvironment)))
+	environmentRouter.HandleFunc("POST /unlock", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.UnlockEnvironment)))
+	environmentRouter.HandleFunc("POST /settings", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.UpdateEnvironmentSettings)))
+	environmentRouter.HandleFunc("POST /retention", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.SetEnvironmentRetention)))
+	environmentRouter.HandleFunc("POST /schema", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.GetEnvSchema)))
+	environmentRouter.HandleFunc("POST /schema/set", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.SetEnvSchema)))
+	environmentRouter.HandleFunc("POST /schema/history", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.EnvSchemaHistory)))
+
+	return environmentRouter
+}
+
 func ServiceRoleRouter(handler *handlers.Handler, debug bool) *http.ServeMux {
 	serviceRoleRouter := http.NewServeMux()
 
@@ -81,6 +148,7 @@ func ServiceRoleRouter(handler *handlers.Handler, debug bool) *http.ServeMux {
 	serviceRoleRouter.HandleFunc("POST /get/all", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.ListServiceRoles)))
 	serviceRoleRouter.HandleFunc("POST /delete", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.DeleteServiceRole)))
 	serviceRoleRouter.HandleFunc("POST /delegate", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.DelegateAccess)))
+	serviceRoleRouter.HandleFunc("POST /pin", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.PinServiceRole)))
 	serviceRoleRouter.HandleFunc("POST /perms", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.GetPerms)))
 
 	serviceRoleRouter.HandleFunc("POST /project-keys", WithErrors(debug, handler.GetProjectKeys))
@@ -95,3 +163,22 @@ func OIDCRouter(handler *handlers.Handler, debug bool) *http.ServeMux {
 
 	return oidcRouter
 }
+
+func EventRouter(handler *handlers.Handler, debug bool) *http.ServeMux {
+	eventRouter := http.NewServeMux()
+
+	eventRouter.HandleFunc("POST /stream", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.StreamEvents)))
+
+	return eventRouter
+}
+
+func ShareRouter(handler *handlers.Handler, debug bool) *http.ServeMux {
+	shareRouter := http.NewServeMux()
+
+	shareRouter.HandleFunc("POST /create", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.CreateShare)))
+	shareRouter.HandleFunc("POST /revoke", WithErrors(debug, AuthMiddleware(handler.Services.SessionService, handler.RevokeShare)))
+	// recipients open shares without an account
+	shareRouter.HandleFunc("POST /view", WithErrors(debug, handler.ViewShare))
+
+	return shareRouter
+}
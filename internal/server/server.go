@@ -1,17 +1,21 @@
 package server
 
 import (
+	"context"
 	"database/sql"
+	"encoding/hex"
 	"log"
 	"net/http"
 
 	"github.com/vijayvenkatj/envcrypt/database"
 	"github.com/vijayvenkatj/envcrypt/internal/config"
 	dbdriver "github.com/vijayvenkatj/envcrypt/internal/db/driver"
+	"github.com/vijayvenkatj/envcrypt/internal/services"
 )
 
 type Server struct {
 	HttpServer *http.Server
+	Services   *services.Services
 }
 
 func NewServer(cfg *config.Config) *Server {
@@ -34,17 +38,27 @@ func NewServer(cfg *config.Config) *Server {
 	dbQueries := database.New(conn)
 
 	debug := cfg.Env != "production"
-	router := NewRouter(dbQueries, conn, debug)
+	webhookKey, err := hex.DecodeString(cfg.WebhookKey)
+	if err != nil || (len(webhookKey) != 0 && len(webhookKey) != 32) {
+		log.Fatal("WEBHOOK_KEY must be 64 hex characters")
+	}
+
+	auditService := services.NewAuditService(dbQueries)
+	service := services.NewServices(dbQueries, auditService, conn, webhookKey)
+	router := NewRouter(service, debug)
 	handler := RequestMiddleware(router)
 	return &Server{
 		HttpServer: &http.Server{
 			Addr:    cfg.Addr,
 			Handler: handler,
 		},
+		Services: service,
 	}
 }
 
 func (s *Server) Start() error {
+	s.Services.Jobs.Start(context.Background())
+
 	log.Printf("Server listening on %s", s.HttpServer.Addr)
 
 	err := s.HttpServer.ListenAndServe()
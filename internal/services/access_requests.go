@@ -0,0 +1,409 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// accessRequestTTL is how long a request stays pending before it expires.
+const accessRequestTTL = 7 * 24 * time.Hour
+
+type AccessRequestService struct {
+	q     *database.Queries
+	db    *sql.DB
+	audit *AuditService
+}
+
+func NewAccessRequestService(q *database.Queries, db *sql.DB) *AccessRequestService {
+	return &AccessRequestService{q: q, db: db}
+}
+
+func (s *AccessRequestService) Create(ctx context.Context, req config.AccessRequestCreateRequest) (*config.AccessRequest, error) {
+	requester, err := s.q.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("User", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	if _, err = s.q.GetProjectById(ctx, req.ProjectID); err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Project", "Check the project ID")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	_, err = s.q.GetUserProjectRole(ctx, database.GetUserProjectRoleParams{
+		ProjectID: req.ProjectID,
+		UserID:    req.UserID,
+		IsRevoked: false,
+	})
+	if err == nil {
+		return nil, errors.Conflict("You are already a member of this project", "")
+	}
+	if !dberrors.IsNoRows(err) {
+		return nil, errors.Internal(err)
+	}
+
+	s.ExpirePending(ctx)
+
+	accessRequest, err := s.q.CreateAccessRequest(ctx, database.CreateAccessRequestParams{
+		ID:        uuid.New(),
+		ProjectID: req.ProjectID,
+		UserID:    req.UserID,
+		EnvName:   req.EnvName,
+		Reason:    req.Reason,
+		ExpiresAt: time.Now().UTC().Add(accessRequestTTL),
+	})
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionAccessRequestCreate, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: requester.Email, ProjectID: &req.ProjectID, Environment: &req.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
+		if dberrors.IsUniqueViolation(err) {
+			return nil, errors.Conflict("You already have a pending access request for this project", "Wait for an admin to decide or cancel it")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionAccessRequestCreate, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: requester.Email, ProjectID: &req.ProjectID, Environment: &req.EnvName, TargetID: helpers.Ptr(accessRequest.ID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"reason": req.Reason})})
+
+	resp := toAccessRequest(accessRequest)
+	return &resp, nil
+}
+
+func (s *AccessRequestService) List(ctx context.Context, req config.AccessRequestListRequest) (*config.AccessRequestListResponse, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	s.ExpirePending(ctx)
+
+	var status sql.NullString
+	if req.Status != nil {
+		status = sql.NullString{String: *req.Status, Valid: true}
+	}
+
+	rows, err := s.q.ListAccessRequests(ctx, database.ListAccessRequestsParams{
+		ProjectID: req.ProjectID,
+		Status:    status,
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.AccessRequestListResponse{
+		AccessRequests: make([]config.AccessRequest, len(rows)),
+	}
+	for i, row := range rows {
+		resp.AccessRequests[i] = toAccessRequest(database.AccessRequest{
+			ID:           row.ID,
+			ProjectID:    row.ProjectID,
+			UserID:       row.UserID,
+			EnvName:      row.EnvName,
+			Reason:       row.Reason,
+			Status:       row.Status,
+			DecidedBy:    row.DecidedBy,
+			DecidedAt:    row.DecidedAt,
+			DecisionNote: row.DecisionNote,
+			CreatedAt:    row.CreatedAt,
+			ExpiresAt:    row.ExpiresAt,
+		})
+		resp.AccessRequests[i].UserEmail = row.UserEmail
+		resp.AccessRequests[i].UserPublicKey = row.UserPublicKey
+	}
+
+	return resp, nil
+}
+
+func (s *AccessRequestService) ListMine(ctx context.Context, req config.AccessRequestMineRequest) (*config.AccessRequestListResponse, error) {
+	s.ExpirePending(ctx)
+
+	rows, err := s.q.ListUserAccessRequests(ctx, req.UserID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.AccessRequestListResponse{
+		AccessRequests: make([]config.AccessRequest, len(rows)),
+	}
+	for i, row := range rows {
+		resp.AccessRequests[i] = toAccessRequest(row)
+	}
+
+	return resp, nil
+}
+
+func (s *AccessRequestService) Approve(ctx context.Context, req config.AccessRequestApproveRequest) (*config.AccessRequest, error) {
+	admin, err := s.q.GetUserByID(ctx, req.AdminID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("User", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	accessRequest, err := s.getPending(ctx, req.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = requireProjectAdmin(ctx, s.q, accessRequest.ProjectID, req.AdminID); err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionAccessRequestApprove, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: admin.Email, ProjectID: &accessRequest.ProjectID, Environment: &accessRequest.EnvName, TargetID: helpers.Ptr(accessRequest.ID.String()), Status: config.StatusFailure, ErrMsg: helpers.Ptr("permission denied")})
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.InternalMessage("Unable to begin approval transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	decided, err := txQ.DecideAccessRequest(ctx, database.DecideAccessRequestParams{
+		ID:           accessRequest.ID,
+		Status:       config.AccessRequestApproved,
+		DecidedBy:    uuid.NullUUID{UUID: req.AdminID, Valid: true},
+		DecisionNote: nullString(req.Note),
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.Conflict("Access request is no longer pending", "Refresh the list of access requests")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	err = grantMembership(ctx, txQ, membershipGrant{
+		ProjectID:          accessRequest.ProjectID,
+		UserID:             accessRequest.UserID,
+		ExpiresAt:          req.ExpiresAt,
+		Role:               "member",
+		WrappedPRK:         req.WrappedPRK,
+		WrapNonce:          req.WrapNonce,
+		EphemeralPublicKey: req.EphemeralPublicKey,
+	})
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionAccessRequestApprove, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: admin.Email, ProjectID: &accessRequest.ProjectID, Environment: &accessRequest.EnvName, TargetID: helpers.Ptr(accessRequest.ID.String()), Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.InternalMessage("Unable to commit approval transaction", err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionAccessRequestApprove, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: admin.Email, ProjectID: &accessRequest.ProjectID, Environment: &accessRequest.EnvName, TargetID: helpers.Ptr(accessRequest.ID.String()), Status: config.StatusSuccess})
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: admin.Email, ProjectID: &accessRequest.ProjectID, TargetID: helpers.Ptr(accessRequest.UserID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"access_request_id": accessRequest.ID, "expires_at": req.ExpiresAt})})
+
+	resp := toAccessRequest(decided)
+	return &resp, nil
+}
+
+func (s *AccessRequestService) Reject(ctx context.Context, req config.AccessRequestRejectRequest) (*config.AccessRequest, error) {
+	admin, err := s.q.GetUserByID(ctx, req.AdminID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("User", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	accessRequest, err := s.getPending(ctx, req.RequestID)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err = requireProjectAdmin(ctx, s.q, accessRequest.ProjectID, req.AdminID); err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionAccessRequestReject, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: admin.Email, ProjectID: &accessRequest.ProjectID, Environment: &accessRequest.EnvName, TargetID: helpers.Ptr(accessRequest.ID.String()), Status: config.StatusFailure, ErrMsg: helpers.Ptr("permission denied")})
+		return nil, err
+	}
+
+	decided, err := s.q.DecideAccessRequest(ctx, database.DecideAccessRequestParams{
+		ID:           accessRequest.ID,
+		Status:       config.AccessRequestRejected,
+		DecidedBy:    uuid.NullUUID{UUID: req.AdminID, Valid: true},
+		DecisionNote: nullString(req.Note),
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.Conflict("Access request is no longer pending", "Refresh the list of access requests")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionAccessRequestReject, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: admin.Email, ProjectID: &accessRequest.ProjectID, Environment: &accessRequest.EnvName, TargetID: helpers.Ptr(accessRequest.ID.String()), Status: config.StatusSuccess})
+
+	resp := toAccessRequest(decided)
+	return &resp, nil
+}
+
+func (s *AccessRequestService) Cancel(ctx context.Context, req config.AccessRequestCancelRequest) (*config.AccessRequest, error) {
+	requester, err := s.q.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("User", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	cancelled, err := s.q.CancelAccessRequest(ctx, database.CancelAccessRequestParams{
+		ID:     req.RequestID,
+		UserID: req.UserID,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Access request", "Only your own pending requests can be cancelled")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionAccessRequestCancel, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: requester.Email, ProjectID: &cancelled.ProjectID, Environment: &cancelled.EnvName, TargetID: helpers.Ptr(cancelled.ID.String()), Status: config.StatusSuccess})
+
+	resp := toAccessRequest(cancelled)
+	return &resp, nil
+}
+
+// ExpirePending marks pending requests past their expiry as expired and audits each one.
+func (s *AccessRequestService) ExpirePending(ctx context.Context) {
+	expired, err := s.q.ExpireAccessRequests(ctx)
+	if err != nil {
+		return
+	}
+
+	for _, accessRequest := range expired {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionAccessRequestExpire, ActorType: config.ActorTypeSystem, ProjectID: &accessRequest.ProjectID, Environment: &accessRequest.EnvName, TargetID: helpers.Ptr(accessRequest.ID.String()), Status: config.StatusSuccess})
+	}
+}
+
+func (s *AccessRequestService) getPending(ctx context.Context, requestID uuid.UUID) (database.AccessRequest, error) {
+	s.ExpirePending(ctx)
+
+	accessRequest, err := s.q.GetAccessRequest(ctx, requestID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.AccessRequest{}, errors.NotFound("Access request", "")
+		}
+		return database.AccessRequest{}, errors.Internal(err)
+	}
+
+	if accessRequest.Status != config.AccessRequestPending {
+		return database.AccessRequest{}, errors.Conflict("Access request is already "+accessRequest.Status, "")
+	}
+
+	return accessRequest, nil
+}
+
+// membershipGrant describes a membership handed out outside of /projects/addUser.
+type membershipGrant struct {
+	ProjectID uuid.UUID
+	UserID    uuid.UUID
+	ExpiresAt *time.Time
+	// Role is the role the grant hands out. A restored membership takes it
+	// over instead of keeping the role it had before it was revoked.
+	Role string
+
+	WrappedPRK         []byte
+	WrapNonce          []byte
+	EphemeralPublicKey []byte
+}
+
+// grantMembership adds the user as a member, or restores a revoked or expired
+// membership, and stores their wrapped PRK.
+func grantMembership(ctx context.Context, q *database.Queries, grant membershipGrant) error {
+	if len(grant.WrappedPRK) == 0 || len(grant.WrapNonce) == 0 || len(grant.EphemeralPublicKey) == 0 {
+		return errors.Validation(map[string]string{"wrapped_prk": "wrapped_prk, wrap_nonce and ephemeral_public_key are required"})
+	}
+
+	var expiresAt sql.NullTime
+	if grant.ExpiresAt != nil {
+		if !grant.ExpiresAt.After(time.Now()) {
+			return errors.Validation(map[string]string{"expires_at": "expires_at must be in the future"})
+		}
+		expiresAt = sql.NullTime{Time: grant.ExpiresAt.UTC(), Valid: true}
+	}
+
+	member, err := q.GetProjectMember(ctx, database.GetProjectMemberParams{
+		ProjectID: grant.ProjectID,
+		UserID:    grant.UserID,
+	})
+	switch {
+	case err == nil:
+		active := !member.IsRevoked && (!member.ExpiresAt.Valid || member.ExpiresAt.Time.After(time.Now().UTC()))
+		if active {
+			return errors.Conflict("User is already a member of this project", "")
+		}
+		err = q.RestoreProjectMember(ctx, database.RestoreProjectMemberParams{
+			ProjectID: grant.ProjectID,
+			UserID:    grant.UserID,
+			ExpiresAt: expiresAt,
+			Role:      grant.Role,
+		})
+		if err != nil {
+			return errors.InternalMessage("Unable to restore project membership", err)
+		}
+	case dberrors.IsNoRows(err):
+		_, err = q.AddUserToProject(ctx, database.AddUserToProjectParams{
+			ProjectID: grant.ProjectID,
+			UserID:    grant.UserID,
+			Role:      grant.Role,
+			ExpiresAt: expiresAt,
+		})
+		if err != nil {
+			if dberrors.IsUniqueViolation(err) {
+				return errors.Conflict("User is already a member of this project", "")
+			}
+			return errors.InternalMessage("Unable to add user to project", err)
+		}
+	default:
+		return errors.Internal(err)
+	}
+
+	err = q.UpsertWrappedPRK(ctx, database.UpsertWrappedPRKParams{
+		ProjectID:        grant.ProjectID,
+		UserID:           grant.UserID,
+		WrappedPrk:       grant.WrappedPRK,
+		WrapNonce:        grant.WrapNonce,
+		WrapEphemeralPub: grant.EphemeralPublicKey,
+	})
+	if err != nil {
+		return errors.InternalMessage("Unable to store wrapped PRK", err)
+	}
+
+	return nil
+}
+
+func toAccessRequest(row database.AccessRequest) config.AccessRequest {
+	accessRequest := config.AccessRequest{
+		ID:        row.ID,
+		ProjectID: row.ProjectID,
+		UserID:    row.UserID,
+		EnvName:   row.EnvName,
+		Reason:    row.Reason,
+		Status:    row.Status,
+		CreatedAt: row.CreatedAt,
+		ExpiresAt: row.ExpiresAt,
+	}
+	if row.DecidedBy.Valid {
+		accessRequest.DecidedBy = &row.DecidedBy.UUID
+	}
+	if row.DecidedAt.Valid {
+		accessRequest.DecidedAt = &row.DecidedAt.Time
+	}
+	if row.DecisionNote.Valid {
+		accessRequest.DecisionNote = &row.DecisionNote.String
+	}
+	return accessRequest
+}
+
+func nullString(v *string) sql.NullString {
+	if v == nil {
+		return sql.NullString{}
+	}
+	return sql.NullString{String: *v, Valid: true}
+}
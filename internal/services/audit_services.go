@@ -18,7 +18,8 @@ import (
 )
 
 type AuditService struct {
-	q *database.Queries
+	q        *database.Queries
+	webhooks *WebhookService
 }
 
 func NewAuditService(q *database.Queries) *AuditService {
@@ -36,6 +37,7 @@ type AuditEntry struct {
 	Status      string
 	ErrMsg      *string
 	Metadata    json.RawMessage
+	Severity    string
 }
 
 func (s *AuditService) Log(ctx context.Context, e AuditEntry) {
@@ -56,11 +58,19 @@ func (s *AuditService) Log(ctx context.Context, e AuditEntry) {
 	if e.ActorEmail == "" {
 		e.ActorEmail = "system@envcrypt"
 	}
+	if e.Severity == "" {
+		e.Severity = config.SeverityInfo
+	}
 
 	auditLog := buildAuditLog(e, reqID, ip, ua)
 
 	if err := s.create(ctx, auditLog); err != nil {
 		log.Printf("failed to write audit log: %v", err)
+		return
+	}
+
+	if s.webhooks != nil && auditLog.Status == config.StatusSuccess && auditLog.ProjectID != nil && config.WebhookActions[auditLog.Action] {
+		s.webhooks.enqueue(ctx, auditLog)
 	}
 }
 
@@ -81,7 +91,18 @@ func buildAuditLog(e AuditEntry, reqID string, ip *string, ua *string) *config.A
 		Status:       e.Status,
 		ErrorMessage: e.ErrMsg,
 		Metadata:     e.Metadata,
+		Severity:     e.Severity,
+	}
+}
+
+// actorEmail looks up the email recorded with an audit entry. A failed
+// lookup leaves it empty rather than failing the change being audited.
+func actorEmail(ctx context.Context, q *database.Queries, userID uuid.UUID) string {
+	user, err := q.GetUserByID(ctx, userID)
+	if err != nil {
+		return ""
 	}
+	return user.Email
 }
 
 func (s *AuditService) create(ctx context.Context, auditLog *config.AuditLog) error {
@@ -142,6 +163,7 @@ func (s *AuditService) create(ctx context.Context, auditLog *config.AuditLog) er
 		Status:       auditLog.Status,
 		ErrorMessage: errMsg,
 		Metadata:     meta,
+		Severity:     auditLog.Severity,
 	})
 }
 
@@ -206,6 +228,10 @@ func (s *AuditService) GetProjectAuditLogs(
 	if req.Status != nil {
 		status = sql.NullString{String: *req.Status, Valid: true}
 	}
+	var severity sql.NullString
+	if req.Severity != nil {
+		severity = sql.NullString{String: *req.Severity, Valid: true}
+	}
 	var fromTime sql.NullTime
 	if req.From != nil {
 		fromTime = sql.NullTime{Time: *req.From, Valid: true}
@@ -215,29 +241,38 @@ func (s *AuditService) GetProjectAuditLogs(
 		toTime = sql.NullTime{Time: *req.To, Valid: true}
 	}
 
+	var environment sql.NullString
+	if req.Environment != nil {
+		environment = sql.NullString{String: *req.Environment, Valid: true}
+	}
+
 	projectIDNull := uuid.NullUUID{UUID: req.ProjectID, Valid: true}
 
 	logs, err := s.q.GetProjectAuditLogsPaginated(ctx, database.GetProjectAuditLogsPaginatedParams{
-		ProjectID:  projectIDNull,
-		ActorEmail: actorEmail,
-		Action:     action,
-		Status:     status,
-		FromTime:   fromTime,
-		ToTime:     toTime,
-		LimitVal:   limit,
-		OffsetVal:  offset,
+		ProjectID:   projectIDNull,
+		ActorEmail:  actorEmail,
+		Action:      action,
+		Status:      status,
+		Severity:    severity,
+		FromTime:    fromTime,
+		ToTime:      toTime,
+		Environment: environment,
+		LimitVal:    limit,
+		OffsetVal:   offset,
 	})
 	if err != nil {
 		return config.ProjectAuditResponse{}, err
 	}
 
 	total, err := s.q.CountProjectAuditLogs(ctx, database.CountProjectAuditLogsParams{
-		ProjectID:  projectIDNull,
-		ActorEmail: actorEmail,
-		Action:     action,
-		Status:     status,
-		FromTime:   fromTime,
-		ToTime:     toTime,
+		ProjectID:   projectIDNull,
+		ActorEmail:  actorEmail,
+		Action:      action,
+		Status:      status,
+		Severity:    severity,
+		FromTime:    fromTime,
+		ToTime:      toTime,
+		Environment: environment,
 	})
 	if err != nil {
 		return config.ProjectAuditResponse{}, err
@@ -260,6 +295,10 @@ func (s *AuditService) GetProjectAuditLogs(
 		if log.Environment.Valid {
 			envStr = &log.Environment.String
 		}
+		var currentEnvStr *string
+		if log.CurrentEnvironment.Valid && log.CurrentEnvironment != log.Environment {
+			currentEnvStr = &log.CurrentEnvironment.String
+		}
 		var targetStr *string
 		if log.TargetID.Valid {
 			targetStr = &log.TargetID.String
@@ -277,21 +316,23 @@ func (s *AuditService) GetProjectAuditLogs(
 			projID = &log.ProjectID.UUID
 		}
 		resp.Logs[i] = config.AuditLog{
-			ID:           log.ID,
-			Timestamp:    log.Timestamp,
-			RequestID:    log.RequestID,
-			ActorType:    log.ActorType,
-			ActorID:      log.ActorID,
-			ActorEmail:   log.ActorEmail,
-			Action:       log.Action,
-			ProjectID:    projID,
-			Environment:  envStr,
-			TargetID:     targetStr,
-			IPAddress:    ipAddr,
-			UserAgent:    uaStr,
-			Status:       log.Status,
-			ErrorMessage: errStr,
-			Metadata:     meta,
+			ID:                 log.ID,
+			Timestamp:          log.Timestamp,
+			RequestID:          log.RequestID,
+			ActorType:          log.ActorType,
+			ActorID:            log.ActorID,
+			ActorEmail:         log.ActorEmail,
+			Action:             log.Action,
+			ProjectID:          projID,
+			Environment:        envStr,
+			TargetID:           targetStr,
+			CurrentEnvironment: currentEnvStr,
+			IPAddress:          ipAddr,
+			UserAgent:          uaStr,
+			Status:             log.Status,
+			ErrorMessage:       errStr,
+			Metadata:           meta,
+			Severity:           log.Severity,
 		}
 	}
 	resp.Pagination.Limit = limit
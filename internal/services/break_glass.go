@@ -0,0 +1,523 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+const (
+	// breakGlassFulfillTTL is how long a break-glass request waits for an escrow holder or admin.
+	breakGlassFulfillTTL = 30 * time.Minute
+
+	breakGlassDefaultWindow = 60
+	breakGlassMaxWindow     = 24 * 60
+)
+
+type BreakGlassService struct {
+	q     *database.Queries
+	db    *sql.DB
+	audit *AuditService
+}
+
+func NewBreakGlassService(q *database.Queries, db *sql.DB) *BreakGlassService {
+	return &BreakGlassService{q: q, db: db}
+}
+
+func (s *BreakGlassService) SetPolicy(ctx context.Context, req config.BreakGlassPolicyRequest) (*config.BreakGlassPolicyResponse, error) {
+	admin, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID)
+	if err != nil {
+		return nil, err
+	}
+
+	window := req.WindowMinutes
+	if window == 0 {
+		window = breakGlassDefaultWindow
+	}
+	if window < 0 || window > breakGlassMaxWindow {
+		return nil, errors.Validation(map[string]string{"window_minutes": "window_minutes must be between 1 and 1440"})
+	}
+
+	var escrowUserID uuid.NullUUID
+	if req.EscrowUserID != nil {
+		// the escrow holder wraps the PRK for the requester, so they need it themselves
+		if _, err = requireProjectMember(ctx, s.q, req.ProjectID, *req.EscrowUserID); err != nil {
+			return nil, errors.Validation(map[string]string{"escrow_user_id": "escrow user must be an active member of the project"})
+		}
+		escrowUserID = uuid.NullUUID{UUID: *req.EscrowUserID, Valid: true}
+	}
+
+	_, err = s.q.UpsertBreakGlassPolicy(ctx, database.UpsertBreakGlassPolicyParams{
+		ProjectID:     req.ProjectID,
+		EscrowUserID:  escrowUserID,
+		WindowMinutes: window,
+		UpdatedBy:     admin.UserID,
+	})
+	if err != nil {
+		return nil, errors.InternalMessage("Unable to save break-glass policy", err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionBreakGlassPolicy, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Status: config.StatusSuccess, Severity: config.SeverityWarning, Metadata: mustJSON(map[string]any{"escrow_user_id": req.EscrowUserID, "window_minutes": window})})
+
+	return s.getPolicy(ctx, req.ProjectID)
+}
+
+func (s *BreakGlassService) GetPolicy(ctx context.Context, req config.BreakGlassPolicyGetRequest) (*config.BreakGlassPolicyResponse, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+	return s.getPolicy(ctx, req.ProjectID)
+}
+
+func (s *BreakGlassService) AddEligibleUser(ctx context.Context, req config.BreakGlassEligibleRequest) (*config.BreakGlassPolicyResponse, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	if _, err := s.q.GetUserByID(ctx, req.UserID); err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("User", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	err := s.q.AddBreakGlassEligibleUser(ctx, database.AddBreakGlassEligibleUserParams{
+		ProjectID: req.ProjectID,
+		UserID:    req.UserID,
+		AddedBy:   req.AdminID,
+	})
+	if err != nil {
+		return nil, errors.InternalMessage("Unable to add break-glass user", err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionBreakGlassPolicy, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, TargetID: helpers.Ptr(req.UserID.String()), Status: config.StatusSuccess, Severity: config.SeverityWarning, Metadata: mustJSON(map[string]any{"eligible": true})})
+
+	return s.getPolicy(ctx, req.ProjectID)
+}
+
+func (s *BreakGlassService) RemoveEligibleUser(ctx context.Context, req config.BreakGlassEligibleRequest) (*config.BreakGlassPolicyResponse, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	removed, err := s.q.RemoveBreakGlassEligibleUser(ctx, database.RemoveBreakGlassEligibleUserParams{
+		ProjectID: req.ProjectID,
+		UserID:    req.UserID,
+	})
+	if err != nil {
+		return nil, errors.InternalMessage("Unable to remove break-glass user", err)
+	}
+	if removed == 0 {
+		return nil, errors.NotFound("Break-glass user", "")
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionBreakGlassPolicy, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, TargetID: helpers.Ptr(req.UserID.String()), Status: config.StatusSuccess, Severity: config.SeverityWarning, Metadata: mustJSON(map[string]any{"eligible": false})})
+
+	return s.getPolicy(ctx, req.ProjectID)
+}
+
+// Request opens a break-glass grant for a pre-approved user. It stays pending
+// until the escrow holder or an admin wraps the PRK for the requester.
+func (s *BreakGlassService) Request(ctx context.Context, req config.BreakGlassCreateRequest) (*config.BreakGlassGrant, error) {
+	requester, err := s.q.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("User", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	entry := AuditEntry{Action: config.ActionBreakGlassRequest, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: requester.Email, ProjectID: &req.ProjectID, Environment: &req.EnvName, Severity: config.SeverityCritical}
+
+	policy, err := s.q.GetBreakGlassPolicy(ctx, req.ProjectID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Break-glass policy", "Break-glass access is not configured for this project")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	_, err = s.q.IsBreakGlassEligible(ctx, database.IsBreakGlassEligibleParams{
+		ProjectID: req.ProjectID,
+		UserID:    req.UserID,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("user is not eligible for break-glass access")
+			s.audit.Log(ctx, entry)
+			return nil, errors.Forbidden("You are not pre-approved for break-glass access to this project", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	if _, err = requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err == nil {
+		return nil, errors.Conflict("You are already a member of this project", "")
+	}
+
+	s.expire(ctx)
+
+	grant, err := s.q.CreateBreakGlassGrant(ctx, database.CreateBreakGlassGrantParams{
+		ID:            uuid.New(),
+		ProjectID:     req.ProjectID,
+		UserID:        req.UserID,
+		EnvName:       req.EnvName,
+		Justification: req.Justification,
+		WindowMinutes: policy.WindowMinutes,
+		ExpiresAt:     time.Now().UTC().Add(breakGlassFulfillTTL),
+	})
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		if dberrors.IsUniqueViolation(err) {
+			return nil, errors.Conflict("You already have an open break-glass grant for this project", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	entry.Status, entry.TargetID = config.StatusSuccess, helpers.Ptr(grant.ID.String())
+	entry.Metadata = mustJSON(map[string]any{"justification": req.Justification, "window_minutes": grant.WindowMinutes})
+	s.audit.Log(ctx, entry)
+
+	resp := toBreakGlassGrant(grant)
+	return &resp, nil
+}
+
+// Pending lists open requests the caller can fulfill: admins and the project's escrow holder.
+func (s *BreakGlassService) Pending(ctx context.Context, req config.BreakGlassPendingRequest) (*config.BreakGlassPendingResponse, error) {
+	if err := s.requireFulfiller(ctx, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	s.expire(ctx)
+
+	rows, err := s.q.ListPendingBreakGlassGrants(ctx, req.ProjectID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.BreakGlassPendingResponse{
+		Grants: make([]config.BreakGlassGrant, len(rows)),
+	}
+	for i, row := range rows {
+		resp.Grants[i] = config.BreakGlassGrant{
+			ID:            row.ID,
+			ProjectID:     row.ProjectID,
+			UserID:        row.UserID,
+			UserEmail:     row.UserEmail,
+			UserPublicKey: row.UserPublicKey,
+			EnvName:       row.EnvName,
+			Justification: row.Justification,
+			Status:        config.BreakGlassPending,
+			WindowMinutes: row.WindowMinutes,
+			CreatedAt:     row.CreatedAt,
+			ExpiresAt:     row.ExpiresAt,
+		}
+	}
+
+	return resp, nil
+}
+
+// Fulfill hands the requester a membership that expires at the end of the
+// policy window. ExpireGrants revokes it when the window closes.
+func (s *BreakGlassService) Fulfill(ctx context.Context, req config.BreakGlassFulfillRequest) (*config.BreakGlassGrant, error) {
+	s.expire(ctx)
+
+	grant, err := s.q.GetBreakGlassGrant(ctx, req.GrantID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Break-glass grant", "")
+		}
+		return nil, errors.Internal(err)
+	}
+	if grant.Status != config.BreakGlassPending {
+		return nil, errors.Conflict("Break-glass grant is already "+grant.Status, "")
+	}
+
+	fulfillerEmail := actorEmail(ctx, s.q, req.FulfillerID)
+	entry := AuditEntry{Action: config.ActionBreakGlassFulfill, ActorType: config.ActorTypeUser, ActorID: req.FulfillerID.String(), ActorEmail: fulfillerEmail, ProjectID: &grant.ProjectID, Environment: &grant.EnvName, TargetID: helpers.Ptr(grant.ID.String()), Severity: config.SeverityCritical}
+
+	if req.FulfillerID == grant.UserID {
+		return nil, errors.Forbidden("You cannot fulfill your own break-glass request", "")
+	}
+	if err = s.requireFulfiller(ctx, grant.ProjectID, req.FulfillerID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.InternalMessage("Unable to begin break-glass transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	expiresAt := time.Now().UTC().Add(time.Duration(grant.WindowMinutes) * time.Minute)
+
+	activated, err := txQ.ActivateBreakGlassGrant(ctx, database.ActivateBreakGlassGrantParams{
+		ID:          grant.ID,
+		FulfilledBy: uuid.NullUUID{UUID: req.FulfillerID, Valid: true},
+		ExpiresAt:   expiresAt,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.Conflict("Break-glass grant is no longer pending", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	err = grantMembership(ctx, txQ, membershipGrant{
+		ProjectID:          grant.ProjectID,
+		UserID:             grant.UserID,
+		ExpiresAt:          &expiresAt,
+		Role:               "member",
+		WrappedPRK:         req.WrappedPRK,
+		WrapNonce:          req.WrapNonce,
+		EphemeralPublicKey: req.EphemeralPublicKey,
+	})
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	// the requester has seen the PRK, so it must be rotated once the emergency is over
+	if err = txQ.SetRotationRequired(ctx, grant.ProjectID); err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.InternalMessage("Unable to commit break-glass transaction", err)
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"user_id": grant.UserID, "justification": grant.Justification, "expires_at": expiresAt})
+	s.audit.Log(ctx, entry)
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeUser, ActorID: req.FulfillerID.String(), ActorEmail: fulfillerEmail, ProjectID: &grant.ProjectID, TargetID: helpers.Ptr(grant.UserID.String()), Status: config.StatusSuccess, Severity: config.SeverityCritical, Metadata: mustJSON(map[string]any{"break_glass_grant_id": grant.ID, "expires_at": expiresAt})})
+
+	resp := toBreakGlassGrant(activated)
+	return &resp, nil
+}
+
+// Revoke ends a pending or active grant early.
+func (s *BreakGlassService) Revoke(ctx context.Context, req config.BreakGlassRevokeRequest) (*config.BreakGlassGrant, error) {
+	grant, err := s.q.GetBreakGlassGrant(ctx, req.GrantID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Break-glass grant", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	if _, err = requireProjectAdmin(ctx, s.q, grant.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.InternalMessage("Unable to begin break-glass transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	revoked, err := txQ.RevokeBreakGlassGrant(ctx, database.RevokeBreakGlassGrantParams{
+		ID:        grant.ID,
+		RevokedBy: uuid.NullUUID{UUID: req.AdminID, Valid: true},
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.Conflict("Break-glass grant is already "+grant.Status, "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	if grant.Status == config.BreakGlassActive {
+		if err = txQ.SetUserAccess(ctx, database.SetUserAccessParams{
+			UserID:    grant.UserID,
+			ProjectID: grant.ProjectID,
+			IsRevoked: true,
+		}); err != nil {
+			return nil, errors.Internal(err)
+		}
+		if err = txQ.DeleteWrappedPRK(ctx, database.DeleteWrappedPRKParams{
+			ProjectID: grant.ProjectID,
+			UserID:    grant.UserID,
+		}); err != nil {
+			return nil, errors.Internal(err)
+		}
+		if err = txQ.SetRotationRequired(ctx, grant.ProjectID); err != nil {
+			return nil, errors.Internal(err)
+		}
+		if err = recordEvent(ctx, txQ, accessRevokedEvent(grant.ProjectID, grant.UserID, "break_glass_revoked")); err != nil {
+			return nil, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.InternalMessage("Unable to commit break-glass transaction", err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionBreakGlassRevoke, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &grant.ProjectID, Environment: &grant.EnvName, TargetID: helpers.Ptr(grant.ID.String()), Status: config.StatusSuccess, Severity: config.SeverityCritical, Metadata: mustJSON(map[string]any{"user_id": grant.UserID, "previous_status": grant.Status})})
+
+	resp := toBreakGlassGrant(revoked)
+	return &resp, nil
+}
+
+// ExpireGrants closes grants whose fulfillment deadline or access window has
+// passed. Access from an active grant is revoked right away rather than left
+// to the membership expiry job. A grant whose access cannot be revoked is
+// audited as a failure and skipped, so it does not hold up the others.
+func (s *BreakGlassService) ExpireGrants(ctx context.Context) error {
+	expired, err := s.q.ExpireBreakGlassGrants(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, grant := range expired {
+		wasActive := grant.FulfilledBy.Valid
+		entry := AuditEntry{Action: config.ActionBreakGlassExpire, ActorType: config.ActorTypeSystem, ProjectID: &grant.ProjectID, Environment: &grant.EnvName, TargetID: helpers.Ptr(grant.ID.String()), Severity: config.SeverityCritical, Metadata: mustJSON(map[string]any{"user_id": grant.UserID, "was_active": wasActive})}
+
+		if wasActive {
+			if err := s.revokeExpiredGrant(ctx, grant); err != nil {
+				entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+				s.audit.Log(ctx, entry)
+				continue
+			}
+		}
+
+		entry.Status = config.StatusSuccess
+		s.audit.Log(ctx, entry)
+	}
+
+	return nil
+}
+
+// revokeExpiredGrant takes away the access an expired grant handed out and
+// announces it, in one transaction.
+func (s *BreakGlassService) revokeExpiredGrant(ctx context.Context, grant database.BreakGlassGrant) error {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	if err = txQ.SetUserAccess(ctx, database.SetUserAccessParams{
+		UserID:    grant.UserID,
+		ProjectID: grant.ProjectID,
+		IsRevoked: true,
+	}); err != nil {
+		return err
+	}
+	if err = txQ.DeleteWrappedPRK(ctx, database.DeleteWrappedPRKParams{
+		ProjectID: grant.ProjectID,
+		UserID:    grant.UserID,
+	}); err != nil {
+		return err
+	}
+	if err = txQ.SetRotationRequired(ctx, grant.ProjectID); err != nil {
+		return err
+	}
+	if err = recordEvent(ctx, txQ, accessRevokedEvent(grant.ProjectID, grant.UserID, "break_glass_expired")); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (s *BreakGlassService) expire(ctx context.Context) {
+	_ = s.ExpireGrants(ctx)
+}
+
+// requireFulfiller allows project admins and the policy's escrow holder.
+func (s *BreakGlassService) requireFulfiller(ctx context.Context, projectID, userID uuid.UUID) error {
+	member, err := requireProjectMember(ctx, s.q, projectID, userID)
+	if err != nil {
+		return err
+	}
+	if member.Role == "admin" {
+		return nil
+	}
+
+	policy, err := s.q.GetBreakGlassPolicy(ctx, projectID)
+	if err != nil && !dberrors.IsNoRows(err) {
+		return errors.Internal(err)
+	}
+	if err == nil && policy.EscrowUserID.Valid && policy.EscrowUserID.UUID == userID {
+		return nil
+	}
+
+	return errors.Forbidden("Only project admins or the escrow holder can fulfill break-glass requests", "")
+}
+
+func (s *BreakGlassService) getPolicy(ctx context.Context, projectID uuid.UUID) (*config.BreakGlassPolicyResponse, error) {
+	policy, err := s.q.GetBreakGlassPolicy(ctx, projectID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Break-glass policy", "Break-glass access is not configured for this project")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	users, err := s.q.ListBreakGlassEligibleUsers(ctx, projectID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.BreakGlassPolicyResponse{
+		Policy: config.BreakGlassPolicy{
+			ProjectID:     policy.ProjectID,
+			WindowMinutes: policy.WindowMinutes,
+			UpdatedBy:     policy.UpdatedBy,
+			UpdatedAt:     policy.UpdatedAt,
+		},
+		EligibleUsers: make([]config.BreakGlassEligibleUser, len(users)),
+	}
+	if policy.EscrowUserID.Valid {
+		resp.Policy.EscrowUserID = &policy.EscrowUserID.UUID
+	}
+	for i, user := range users {
+		resp.EligibleUsers[i] = config.BreakGlassEligibleUser{
+			UserID:  user.UserID,
+			Email:   user.Email,
+			AddedBy: user.AddedBy,
+			AddedAt: user.AddedAt,
+		}
+	}
+
+	return resp, nil
+}
+
+func toBreakGlassGrant(row database.BreakGlassGrant) config.BreakGlassGrant {
+	grant := config.BreakGlassGrant{
+		ID:            row.ID,
+		ProjectID:     row.ProjectID,
+		UserID:        row.UserID,
+		EnvName:       row.EnvName,
+		Justification: row.Justification,
+		Status:        row.Status,
+		WindowMinutes: row.WindowMinutes,
+		CreatedAt:     row.CreatedAt,
+		ExpiresAt:     row.ExpiresAt,
+	}
+	if row.FulfilledBy.Valid {
+		grant.FulfilledBy = &row.FulfilledBy.UUID
+	}
+	if row.FulfilledAt.Valid {
+		grant.FulfilledAt = &row.FulfilledAt.Time
+	}
+	if row.RevokedBy.Valid {
+		grant.RevokedBy = &row.RevokedBy.UUID
+	}
+	return grant
+}
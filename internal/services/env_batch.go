@@ -0,0 +1,177 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// BatchFetch reads several environments, possibly across projects, in one
+// call. Items fail independently: an item the caller may not read carries its
+// error and the rest are still returned. Every item is audited as a pull.
+// Only a user's own session may batch; CI sessions pull one environment at a
+// time through GetEnvForCI, which enforces their delegation.
+func (s *EnvServices) BatchFetch(ctx context.Context, req config.BatchEnvRequest) (*config.BatchEnvResponse, error) {
+	email, err := s.batchSessionUser(ctx, req.UserID)
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error()), Metadata: mustJSON(map[string]any{"batch": len(req.Items)})})
+		return nil, err
+	}
+
+	resp := &config.BatchEnvResponse{
+		Items: make([]config.BatchEnvResult, 0, len(req.Items)),
+		Keys:  []config.GetUserProjectResponse{},
+	}
+
+	// membership and key lookups are shared by every item of a project
+	projectErrs := make(map[uuid.UUID]error)
+	for _, item := range req.Items {
+		result := config.BatchEnvResult{ProjectID: item.ProjectID, EnvName: item.EnvName}
+
+		projectErr, seen := projectErrs[item.ProjectID]
+		if !seen {
+			var key *config.GetUserProjectResponse
+			key, projectErr = s.batchProjectKey(ctx, item.ProjectID, req.UserID)
+			projectErrs[item.ProjectID] = projectErr
+			if projectErr == nil {
+				resp.Keys = append(resp.Keys, *key)
+			}
+		}
+
+		err := projectErr
+		if err == nil {
+			result.Env, err = s.batchItem(ctx, item, req)
+		}
+
+		entry := AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: email, ProjectID: &item.ProjectID, Environment: &item.EnvName}
+		if err != nil {
+			result.Error = batchError(err)
+			entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(result.Error.Message)
+		} else {
+			entry.Status = config.StatusSuccess
+			entry.Metadata = pullMetadata(result.Env.Version, item.Label, nil)
+		}
+		s.audit.Log(ctx, entry)
+
+		resp.Items = append(resp.Items, result)
+	}
+
+	return resp, nil
+}
+
+// batchSessionUser checks that the request's session is a user session of
+// userID and returns that user's email for the audit log.
+func (s *EnvServices) batchSessionUser(ctx context.Context, userID uuid.UUID) (string, error) {
+	sessionID, ok := ctx.Value("session_id").(uuid.UUID)
+	if !ok {
+		return "", errors.Unauthorized("SESSION_MISSING", "User not authenticated", "")
+	}
+
+	session, err := s.q.GetSession(ctx, sessionID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return "", errors.Unauthorized("SESSION_EXPIRED", "Session is invalid or expired", "Please log in again")
+		}
+		return "", errors.Internal(err)
+	}
+	if session.IdentityType != "user" {
+		return "", errors.Forbidden("CI sessions cannot batch fetch", "Pull each environment through the CI endpoint")
+	}
+	if !session.UserID.Valid || session.UserID.UUID != userID {
+		return "", errors.Forbidden("Session does not belong to this user", "")
+	}
+
+	user, err := s.q.GetUserByID(ctx, userID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return "", errors.NotFound("User", "")
+		}
+		return "", errors.Internal(err)
+	}
+	return user.Email, nil
+}
+
+func (s *EnvServices) batchProjectKey(ctx context.Context, projectID, userID uuid.UUID) (*config.GetUserProjectResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, projectID, userID); err != nil {
+		return nil, err
+	}
+
+	wrappedKey, err := s.q.GetProjectWrappedKey(ctx, database.GetProjectWrappedKeyParams{
+		ProjectID: projectID,
+		UserID:    userID,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Project key", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	return &config.GetUserProjectResponse{
+		ProjectId:          projectID,
+		WrappedPRK:         wrappedKey.WrappedPrk,
+		WrapNonce:          wrappedKey.WrapNonce,
+		EphemeralPublicKey: wrappedKey.WrapEphemeralPub,
+	}, nil
+}
+
+func (s *EnvServices) batchItem(ctx context.Context, item config.BatchEnvItem, req config.BatchEnvRequest) (*config.GetEnvResponse, error) {
+	environment, err := s.getEnvironment(ctx, item.ProjectID, item.EnvName)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := s.resolveEnvVersion(ctx, item.ProjectID, item.EnvName, item.Version, item.Label)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := listEnvEntries(ctx, s.q, env)
+	if err != nil {
+		return nil, err
+	}
+
+	parents, err := s.parentChain(ctx, environment, env, item.Version != nil || item.Label != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	references, err := s.q.ListEnvReferences(ctx, environment.ID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+	resolved, referenceKeys := s.resolveReferences(ctx, environment, references, req.UserID)
+
+	return &config.GetEnvResponse{
+		CipherText:        env.Ciphertext,
+		Nonce:             env.Nonce,
+		WrappedDEK:        env.WrappedDek,
+		DekNonce:          env.DekNonce,
+		EncryptionVersion: env.EncryptionVersion,
+		Version:           env.Version,
+		EnvironmentID:     environment.ID,
+		Entries:           entries,
+		Parents:           parents,
+		References:        resolved,
+		ReferenceKeys:     referenceKeys,
+	}, nil
+}
+
+// batchError reports an item failure the way the error middleware would
+// report a whole request, without internal details.
+func batchError(err error) *config.BatchEnvError {
+	appErr, ok := err.(*errors.AppError)
+	if !ok {
+		appErr = errors.Internal(err)
+	}
+	return &config.BatchEnvError{
+		Code:    string(appErr.Code),
+		Message: appErr.Msg,
+		Hint:    appErr.Hint,
+	}
+}
@@ -0,0 +1,341 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// envCandidateTTL is how long a push to a protected environment waits for review.
+const envCandidateTTL = 72 * time.Hour
+
+// pushStatus decides whether a new version is published straight away or held
+// as a candidate because the environment is protected.
+func pushStatus(environment database.Environment) (string, sql.NullTime) {
+	if !environment.Protected {
+		return config.EnvVersionPublished, sql.NullTime{}
+	}
+	return config.EnvVersionPending, sql.NullTime{Time: time.Now().UTC().Add(envCandidateTTL), Valid: true}
+}
+
+func (s *EnvServices) SetProtection(ctx context.Context, req config.ProtectEnvRequest) (*config.ProtectEnvResponse, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	updated, err := s.q.SetEnvironmentProtected(ctx, database.SetEnvironmentProtectedParams{
+		ProjectID: req.ProjectID,
+		Name:      req.EnvName,
+		Protected: req.Protected,
+	})
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvProtect, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
+		return nil, errors.InternalMessage("Unable to update environment protection", err)
+	}
+	if updated == 0 {
+		return nil, errors.NotFound("Environment", "Check the environment name")
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvProtect, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.EnvName, Status: config.StatusSuccess, Severity: config.SeverityWarning, Metadata: mustJSON(map[string]any{"protected": req.Protected})})
+
+	protected, err := s.q.ListProtectedEnvironmentNames(ctx, req.ProjectID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	return &config.ProtectEnvResponse{
+		Message:               "Environment protection updated",
+		ProtectedEnvironments: protected,
+	}, nil
+}
+
+func (s *EnvServices) ListCandidates(ctx context.Context, req config.EnvCandidatesRequest) (*config.EnvCandidatesResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	_ = s.ExpireCandidates(ctx)
+
+	rows, err := s.q.ListEnvCandidates(ctx, database.ListEnvCandidatesParams{
+		ProjectID: req.ProjectID,
+		EnvName:   nullString(req.EnvName),
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.EnvCandidatesResponse{
+		Candidates: make([]config.EnvCandidate, len(rows)),
+	}
+	for i, row := range rows {
+		resp.Candidates[i] = config.EnvCandidate{
+			ID:             row.ID,
+			EnvName:        row.EnvName,
+			Version:        row.Version,
+			Status:         row.Status,
+			CreatedBy:      row.CreatedBy,
+			CreatedByEmail: row.CreatedByEmail,
+			CreatedAt:      row.CreatedAt,
+			Metadata:       decodeMetadata(row.Metadata, row.ParentVersion),
+		}
+		if row.CandidateExpiresAt.Valid {
+			resp.Candidates[i].ExpiresAt = &row.CandidateExpiresAt.Time
+		}
+	}
+
+	return resp, nil
+}
+
+// GetCandidate returns a candidate with its ciphertext so reviewers can decrypt and diff it.
+func (s *EnvServices) GetCandidate(ctx context.Context, req config.EnvCandidateGetRequest) (*config.EnvCandidateGetResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	env, err := s.q.GetEnvCandidate(ctx, database.GetEnvCandidateParams{
+		ProjectID: req.ProjectID,
+		EnvName:   req.EnvName,
+		Version:   req.Version,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Candidate", "Check the environment name and version")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	envResponse, err := s.envResponse(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+
+	return &config.EnvCandidateGetResponse{
+		Candidate: toEnvCandidate(env),
+		Env:       envResponse,
+	}, nil
+}
+
+// ApproveCandidate publishes a pending version. The approver must be an admin
+// other than the person who pushed it.
+func (s *EnvServices) ApproveCandidate(ctx context.Context, req config.EnvCandidateReviewRequest) (*config.EnvCandidate, error) {
+	entry := AuditEntry{Action: config.ActionEnvCandidateApprove, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.EnvName, TargetID: helpers.Ptr(strconv.Itoa(int(req.Version)))}
+
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	candidate, err := s.getPendingCandidate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	if candidate.CreatedBy == req.AdminID {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("self-approval")
+		s.audit.Log(ctx, entry)
+		return nil, errors.Forbidden("A different admin must approve this change", "")
+	}
+
+	environment, err := s.getEnvironment(ctx, req.ProjectID, req.EnvName)
+	if err != nil {
+		return nil, err
+	}
+
+	approved, err := s.approveCandidate(ctx, environment, candidate, req)
+	if err != nil {
+		return nil, err
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"version": approved.Version, "pushed_by": approved.CreatedBy, "note": req.Note})
+	s.audit.Log(ctx, entry)
+
+	resp := toEnvCandidate(approved)
+	return &resp, nil
+}
+
+// approveCandidate publishes a candidate under the environment's row lock, the
+// one pushes allocate versions under. Two candidates pushed on the same
+// version cannot then both pass the check that nothing was published since,
+// and a lock taken after the request started is still seen.
+func (s *EnvServices) approveCandidate(ctx context.Context, environment database.Environment, candidate database.EnvVersion, req config.EnvCandidateReviewRequest) (database.EnvVersion, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.EnvVersion{}, errors.InternalMessage("Unable to begin approval transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	environment, err = txQ.GetEnvironmentForUpdate(ctx, environment.ID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.EnvVersion{}, errors.NotFound("Environment", "Check the environment name")
+		}
+		return database.EnvVersion{}, errors.Internal(err)
+	}
+	if err = requireUnlocked(ctx, txQ, environment); err != nil {
+		return database.EnvVersion{}, err
+	}
+
+	latest, err := txQ.GetLatestEnv(ctx, database.GetLatestEnvParams{
+		ProjectID: environment.ProjectID,
+		EnvName:   environment.Name,
+	})
+	var current int32
+	switch {
+	case err == nil:
+		current = latest.Version
+	case !dberrors.IsNoRows(err):
+		return database.EnvVersion{}, errors.Internal(err)
+	}
+	stale := current > candidate.Version
+	if candidate.ParentVersion.Valid {
+		stale = current != candidate.ParentVersion.Int32
+	}
+	if stale {
+		return database.EnvVersion{}, errors.Conflict("A newer version has been published since this change was pushed", "Reject this candidate and push again")
+	}
+
+	approved, err := txQ.ApproveEnvCandidate(ctx, database.ApproveEnvCandidateParams{
+		ID:         candidate.ID,
+		ReviewedBy: uuid.NullUUID{UUID: req.AdminID, Valid: true},
+		ReviewNote: nullString(req.Note),
+		CreatedBy:  req.AdminID,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.EnvVersion{}, errors.Conflict("Candidate is no longer pending", "")
+		}
+		return database.EnvVersion{}, errors.Internal(err)
+	}
+
+	if err = recordVersionEvent(ctx, txQ, approved); err != nil {
+		return database.EnvVersion{}, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return database.EnvVersion{}, errors.InternalMessage("Unable to commit approval transaction", err)
+	}
+	return approved, nil
+}
+
+func (s *EnvServices) RejectCandidate(ctx context.Context, req config.EnvCandidateReviewRequest) (*config.EnvCandidate, error) {
+	entry := AuditEntry{Action: config.ActionEnvCandidateReject, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.EnvName, TargetID: helpers.Ptr(strconv.Itoa(int(req.Version)))}
+
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	candidate, err := s.getPendingCandidate(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+
+	rejected, err := s.q.RejectEnvCandidate(ctx, database.RejectEnvCandidateParams{
+		ID:         candidate.ID,
+		ReviewedBy: uuid.NullUUID{UUID: req.AdminID, Valid: true},
+		ReviewNote: nullString(req.Note),
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.Conflict("Candidate is no longer pending", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"version": rejected.Version, "pushed_by": rejected.CreatedBy, "note": req.Note})
+	s.audit.Log(ctx, entry)
+
+	resp := toEnvCandidate(rejected)
+	return &resp, nil
+}
+
+// ExpireCandidates marks candidates that were never reviewed as expired.
+func (s *EnvServices) ExpireCandidates(ctx context.Context) error {
+	expired, err := s.q.ExpireEnvCandidates(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, candidate := range expired {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvCandidateExpire, ActorType: config.ActorTypeSystem, ProjectID: &candidate.ProjectID, Environment: &candidate.EnvName, TargetID: helpers.Ptr(strconv.Itoa(int(candidate.Version))), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"version": candidate.Version, "pushed_by": candidate.CreatedBy})})
+	}
+
+	return nil
+}
+
+func (s *EnvServices) getPendingCandidate(ctx context.Context, req config.EnvCandidateReviewRequest) (database.EnvVersion, error) {
+	_ = s.ExpireCandidates(ctx)
+
+	candidate, err := s.q.GetEnvCandidate(ctx, database.GetEnvCandidateParams{
+		ProjectID: req.ProjectID,
+		EnvName:   req.EnvName,
+		Version:   req.Version,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.EnvVersion{}, errors.NotFound("Candidate", "Check the environment name and version")
+		}
+		return database.EnvVersion{}, errors.Internal(err)
+	}
+
+	if candidate.Status != config.EnvVersionPending {
+		return database.EnvVersion{}, errors.Conflict("Version is already "+candidate.Status, "")
+	}
+
+	return candidate, nil
+}
+
+func toEnvCandidate(env database.EnvVersion) config.EnvCandidate {
+	candidate := config.EnvCandidate{
+		ID:        env.ID,
+		EnvName:   env.EnvName,
+		Version:   env.Version,
+		Status:    env.Status,
+		CreatedBy: env.CreatedBy,
+		CreatedAt: env.CreatedAt,
+		Metadata:  decodeMetadata(env.Metadata, env.ParentVersion),
+	}
+	if env.CandidateExpiresAt.Valid {
+		candidate.ExpiresAt = &env.CandidateExpiresAt.Time
+	}
+	if env.ReviewedBy.Valid {
+		candidate.ReviewedBy = &env.ReviewedBy.UUID
+	}
+	if env.ReviewedAt.Valid {
+		candidate.ReviewedAt = &env.ReviewedAt.Time
+	}
+	if env.ReviewNote.Valid {
+		candidate.ReviewNote = &env.ReviewNote.String
+	}
+	return candidate
+}
+
+// pushAuditMetadata records the candidate status alongside the env metadata for held pushes.
+func pushAuditMetadata(env database.EnvVersion, metadata json.RawMessage) json.RawMessage {
+	if env.Status == config.EnvVersionPublished {
+		return metadata
+	}
+	return mustJSON(map[string]any{"metadata": metadata, "version": env.Version, "status": env.Status})
+}
+
+func pushMessage(status, published string) string {
+	if status == config.EnvVersionPending {
+		return "env change submitted for approval"
+	}
+	return published
+}
@@ -0,0 +1,111 @@
+package services
+
+import (
+	"context"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+// setupCandidates publishes version 1 of a protected environment and returns
+// two candidates pushed on top of it by different members.
+func setupCandidates(t *testing.T) (s *Services, projectID, reviewerID uuid.UUID, first, second int32) {
+	t.Helper()
+	ctx := context.Background()
+
+	s = newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	reviewerID = createTestUser(t, s, "reviewer@example.com")
+	aliceID := createTestUser(t, s, "alice@example.com")
+	bobID := createTestUser(t, s, "bob@example.com")
+	projectID = createTestProject(t, s, adminID, map[uuid.UUID]string{reviewerID: "admin", aliceID: "member", bobID: "member"})
+	createTestEnvironment(t, s, projectID, adminID, "production", false)
+
+	if _, err := pushTestEnv(s, projectID, adminID, "production", nil); err != nil {
+		t.Fatalf("push version 1: %v", err)
+	}
+	if _, err := s.Env.SetProtection(ctx, config.ProtectEnvRequest{ProjectID: projectID, AdminID: adminID, EnvName: "production", Protected: true}); err != nil {
+		t.Fatalf("protect environment: %v", err)
+	}
+
+	for i, userID := range []uuid.UUID{aliceID, bobID} {
+		resp, err := pushTestEnv(s, projectID, userID, "production", helpers.Ptr(int32(1)))
+		if err != nil {
+			t.Fatalf("push candidate %d: %v", i+1, err)
+		}
+		if resp.Status != config.EnvVersionPending {
+			t.Fatalf("candidate %d has status %q, want %q", i+1, resp.Status, config.EnvVersionPending)
+		}
+		if i == 0 {
+			first = resp.Version
+		} else {
+			second = resp.Version
+		}
+	}
+	return s, projectID, reviewerID, first, second
+}
+
+func approveTestCandidate(s *Services, projectID, adminID uuid.UUID, version int32) error {
+	_, err := s.Env.ApproveCandidate(context.Background(), config.EnvCandidateReviewRequest{
+		ProjectID: projectID,
+		AdminID:   adminID,
+		EnvName:   "production",
+		Version:   version,
+	})
+	return err
+}
+
+func TestApproveCandidateRejectsStaleParent(t *testing.T) {
+	s, projectID, reviewerID, first, second := setupCandidates(t)
+
+	if err := approveTestCandidate(s, projectID, reviewerID, first); err != nil {
+		t.Fatalf("approve first candidate: %v", err)
+	}
+	err := approveTestCandidate(s, projectID, reviewerID, second)
+	requireErrorCode(t, err, errors.CodeConflict)
+}
+
+func TestApproveCandidateConcurrentApprovals(t *testing.T) {
+	s, projectID, reviewerID, first, second := setupCandidates(t)
+
+	var wg sync.WaitGroup
+	errs := make([]error, 2)
+	for i, version := range []int32{first, second} {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs[i] = approveTestCandidate(s, projectID, reviewerID, version)
+		}()
+	}
+	wg.Wait()
+
+	approved := 0
+	for _, err := range errs {
+		if err == nil {
+			approved++
+			continue
+		}
+		requireErrorCode(t, err, errors.CodeConflict)
+	}
+	if approved != 1 {
+		t.Fatalf("%d candidates approved, want exactly 1", approved)
+	}
+}
+
+func TestApproveCandidateRequiresAnotherAdmin(t *testing.T) {
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "production", true)
+
+	resp, err := pushTestEnv(s, projectID, adminID, "production", nil)
+	if err != nil {
+		t.Fatalf("push candidate: %v", err)
+	}
+	err = approveTestCandidate(s, projectID, adminID, resp.Version)
+	requireErrorCode(t, err, errors.CodeForbidden)
+}
@@ -0,0 +1,168 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"slices"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// Drift reports the version every known consumer last pulled against the
+// version it should be running.
+func (s *EnvServices) Drift(ctx context.Context, req config.EnvDriftRequest) (*config.EnvDriftResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.q.ListEnvConsumers(ctx, database.ListEnvConsumersParams{
+		ProjectID: req.ProjectID,
+		EnvName:   nullString(req.EnvName),
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	var (
+		latest        = make(map[string]int32)
+		labels        = make(map[string]*int32)
+		latestParents = make(map[uuid.UUID][]uuid.UUID)
+	)
+	resp := &config.EnvDriftResponse{
+		Consumers: make([]config.EnvConsumer, 0, len(rows)),
+	}
+	for _, row := range rows {
+		latestVersion, ok := latest[row.EnvName]
+		if !ok {
+			latestVersion, err = s.q.GetPublishedEnvVersion(ctx, database.GetPublishedEnvVersionParams{
+				ProjectID: req.ProjectID,
+				EnvName:   row.EnvName,
+			})
+			if err != nil && !dberrors.IsNoRows(err) {
+				return nil, errors.Internal(err)
+			}
+			latest[row.EnvName] = latestVersion
+		}
+
+		consumer := config.EnvConsumer{
+			EnvName:       row.EnvName,
+			Consumer:      row.Consumer,
+			Version:       row.Version,
+			LatestVersion: latestVersion,
+			Drifted:       row.Version < latestVersion,
+			PulledBy:      row.PulledBy,
+			PulledAt:      row.PulledAt,
+		}
+
+		// a consumer whose label was deleted is measured against the latest version
+		if row.Label.Valid {
+			consumer.Label = &row.Label.String
+
+			key := row.EnvName + "/" + row.Label.String
+			labelVersion, ok := labels[key]
+			if !ok {
+				version, err := s.q.ResolveEnvLabel(ctx, database.ResolveEnvLabelParams{
+					ProjectID: req.ProjectID,
+					EnvName:   row.EnvName,
+					Label:     row.Label.String,
+				})
+				switch {
+				case err == nil:
+					labelVersion = &version
+				case !dberrors.IsNoRows(err):
+					return nil, errors.Internal(err)
+				}
+				labels[key] = labelVersion
+			}
+			if labelVersion != nil {
+				consumer.LabelVersion = labelVersion
+				consumer.Drifted = row.Version != *labelVersion
+			}
+		}
+
+		// a pinned pull merges the parents recorded with its version, so only
+		// a consumer of the latest version can fall behind its parents
+		if !row.Label.Valid {
+			current, ok := latestParents[row.EnvironmentID]
+			if !ok {
+				current, err = s.latestParentIDs(ctx, row.EnvironmentID)
+				if err != nil {
+					return nil, err
+				}
+				latestParents[row.EnvironmentID] = current
+			}
+
+			var pulled []uuid.UUID
+			if err := json.Unmarshal(row.ParentVersions, &pulled); err != nil || !slices.Equal(pulled, current) {
+				consumer.ParentsDrifted = true
+				consumer.Drifted = true
+			}
+		}
+
+		if req.DriftedOnly && !consumer.Drifted {
+			continue
+		}
+		resp.Consumers = append(resp.Consumers, consumer)
+	}
+
+	return resp, nil
+}
+
+// recordConsumer remembers the version and parent layers a named consumer
+// pulled. Only CI pulls are tracked, under the service role of their session,
+// so a consumer's entry cannot be written by anyone else. Tracking is best
+// effort, a failure is logged and does not fail the pull.
+func (s *EnvServices) recordConsumer(ctx context.Context, environment database.Environment, consumer *string, version int32, label *string, parents []config.ParentEnv, serviceRoleID uuid.UUID) {
+	if consumer == nil {
+		return
+	}
+
+	parentVersions := make([]uuid.UUID, len(parents))
+	for i, parent := range parents {
+		parentVersions[i] = parent.VersionID
+	}
+
+	err := s.q.UpsertEnvConsumer(ctx, database.UpsertEnvConsumerParams{
+		ID:             uuid.New(),
+		EnvironmentID:  environment.ID,
+		Consumer:       *consumer,
+		Version:        version,
+		Label:          nullString(label),
+		ParentVersions: mustJSON(parentVersions),
+		PulledBy:       serviceRoleID.String(),
+	})
+	if err != nil {
+		log.Printf("failed to record consumer: project=%s env=%s consumer=%s err=%v", environment.ProjectID, environment.Name, *consumer, err)
+	}
+}
+
+// latestParentIDs lists the latest published version of every ancestor of
+// the environment, root first.
+func (s *EnvServices) latestParentIDs(ctx context.Context, environmentID uuid.UUID) ([]uuid.UUID, error) {
+	environment, err := s.q.GetEnvironmentByID(ctx, environmentID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	layers, err := latestParentVersions(ctx, s.q, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	ids := make([]uuid.UUID, len(layers))
+	for i, layer := range layers {
+		ids[i] = layer.ID
+	}
+	return ids, nil
+}
+
+// pullMetadata is the audit metadata of a successful pull, so the log answers
+// which version was handed out and to what.
+func pullMetadata(version int32, label, consumer *string) json.RawMessage {
+	return mustJSON(map[string]any{"version": version, "label": label, "consumer": consumer})
+}
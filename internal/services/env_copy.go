@@ -0,0 +1,152 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+// Rollback republishes an earlier version of an environment as its newest version.
+func (s *EnvServices) Rollback(ctx context.Context, req config.RollbackEnvRequest) (*config.CopyEnvResponse, error) {
+	entry := AuditEntry{Action: config.ActionEnvRollback, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID, Environment: &req.EnvName}
+
+	source, err := s.getCopySource(ctx, req.ProjectID, req.UserID, req.EnvName, &req.ToVersion)
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	provenance := config.Provenance{Operation: "rollback", EnvName: source.EnvName, Version: source.Version}
+	env, err := s.copyVersion(ctx, source, req.EnvName, req.UserID, req.ExpectedVersion, req.ExpectedEnvironmentID, config.Metadata{
+		Type:       config.MetadataTypeRollback,
+		Message:    "Rolled back to version " + strconv.Itoa(int(source.Version)),
+		Source:     config.MetadataSourceRollback,
+		Provenance: &provenance,
+	})
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"from_version": source.Version, "version": env.Version, "status": env.Status})
+	s.audit.Log(ctx, entry)
+
+	return &config.CopyEnvResponse{
+		Message:    pushMessage(env.Status, "env rolled back successfully"),
+		Version:    env.Version,
+		Status:     env.Status,
+		Provenance: provenance,
+	}, nil
+}
+
+// Promote copies a version of one environment into another environment of the
+// same project. The wrapped DEK is under the project PRK, so no re-encryption
+// is needed.
+func (s *EnvServices) Promote(ctx context.Context, req config.PromoteEnvRequest) (*config.CopyEnvResponse, error) {
+	entry := AuditEntry{Action: config.ActionEnvPromote, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID, Environment: &req.ToEnv}
+
+	if req.FromEnv == req.ToEnv {
+		return nil, errors.Validation(map[string]string{"to_env": "to_env must differ from from_env, use rollback instead"})
+	}
+
+	source, err := s.getCopySource(ctx, req.ProjectID, req.UserID, req.FromEnv, req.FromVersion)
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	provenance := config.Provenance{Operation: "promote", EnvName: source.EnvName, Version: source.Version}
+	env, err := s.copyVersion(ctx, source, req.ToEnv, req.UserID, req.ExpectedVersion, req.ExpectedEnvironmentID, config.Metadata{
+		Type:       config.MetadataTypePromote,
+		Message:    "Promoted from " + source.EnvName + " version " + strconv.Itoa(int(source.Version)),
+		Source:     config.MetadataSourcePromote,
+		Provenance: &provenance,
+	})
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"from_env": source.EnvName, "from_version": source.Version, "version": env.Version, "status": env.Status})
+	s.audit.Log(ctx, entry)
+
+	return &config.CopyEnvResponse{
+		Message:    pushMessage(env.Status, "env promoted successfully"),
+		Version:    env.Version,
+		Status:     env.Status,
+		Provenance: provenance,
+	}, nil
+}
+
+// getCopySource checks membership and loads a published version, the latest one when version is nil.
+func (s *EnvServices) getCopySource(ctx context.Context, projectID, userID uuid.UUID, envName string, version *int32) (database.EnvVersion, error) {
+	if _, err := requireProjectMember(ctx, s.q, projectID, userID); err != nil {
+		return database.EnvVersion{}, err
+	}
+
+	return s.resolveEnvVersion(ctx, projectID, envName, version, nil)
+}
+
+func (s *EnvServices) copyVersion(ctx context.Context, source database.EnvVersion, targetEnv string, userID uuid.UUID, expectedVersion *int32, expectedEnvironmentID *uuid.UUID, metadata config.Metadata) (database.EnvVersion, error) {
+	environment, err := s.environments.ResolveForWrite(ctx, source.ProjectID, targetEnv, userID)
+	if err != nil {
+		return database.EnvVersion{}, err
+	}
+	if err = requireExpectedEnvironment(environment, expectedEnvironmentID); err != nil {
+		return database.EnvVersion{}, err
+	}
+
+	// the copy has the same contents, so it keeps the source's key summary
+	// and expiry dates
+	var sourceMetadata config.Metadata
+	if json.Unmarshal(source.Metadata, &sourceMetadata) == nil {
+		metadata.Keys = sourceMetadata.Keys
+		metadata.ExpiresAt = sourceMetadata.ExpiresAt
+	}
+
+	rawMetadata, err := encodeMetadata(metadata)
+	if err != nil {
+		return database.EnvVersion{}, err
+	}
+
+	status, candidateExpiresAt := pushStatus(environment)
+
+	return s.insertEnvVersion(ctx, environment, expectedVersion, database.AddEnvParams{
+		ID:                 uuid.New(),
+		ProjectID:          source.ProjectID,
+		EnvName:            targetEnv,
+		Ciphertext:         source.Ciphertext,
+		Nonce:              source.Nonce,
+		WrappedDek:         source.WrappedDek,
+		DekNonce:           source.DekNonce,
+		EncryptionVersion:  source.EncryptionVersion,
+		CreatedBy:          userID,
+		Metadata:           rawMetadata,
+		Status:             status,
+		CandidateExpiresAt: candidateExpiresAt,
+	}, func(txQ *database.Queries, env database.EnvVersion, _ *database.EnvVersion) error {
+		if source.EncryptionVersion != config.EncryptionVersionEntries {
+			return nil
+		}
+		if err := txQ.CopyEnvEntriesAsWritten(ctx, database.CopyEnvEntriesAsWrittenParams{
+			TargetVersionID:  env.ID,
+			ChangedInVersion: env.Version,
+			SourceVersionID:  source.ID,
+		}); err != nil {
+			return errors.Internal(err)
+		}
+		return nil
+	})
+}
@@ -0,0 +1,121 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"sort"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// Diff compares the key summaries of two published versions of an environment.
+// Only blinded identifiers are compared, the server never sees names or values.
+func (s *EnvServices) Diff(ctx context.Context, req config.DiffEnvRequest) (*config.DiffEnvResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	from, fromSummary, err := s.versionKeys(ctx, req.ProjectID, req.EnvName, req.FromVersion)
+	if err != nil {
+		return nil, err
+	}
+	to, toSummary, err := s.versionKeys(ctx, req.ProjectID, req.EnvName, req.ToVersion)
+	if err != nil {
+		return nil, err
+	}
+	// value fingerprints and write versions cannot be compared with each other
+	comparable := fromSummary == toSummary
+
+	resp := &config.DiffEnvResponse{
+		FromVersion: req.FromVersion,
+		ToVersion:   req.ToVersion,
+		Added:       [][]byte{},
+		Removed:     [][]byte{},
+		Changed:     [][]byte{},
+		Unknown:     [][]byte{},
+	}
+	for key, fingerprint := range to {
+		previous, ok := from[key]
+		switch {
+		case !ok:
+			resp.Added = append(resp.Added, []byte(key))
+		case !comparable:
+			resp.Unknown = append(resp.Unknown, []byte(key))
+		case !bytes.Equal(previous, fingerprint):
+			resp.Changed = append(resp.Changed, []byte(key))
+		default:
+			resp.Unchanged++
+		}
+	}
+	for key := range from {
+		if _, ok := to[key]; !ok {
+			resp.Removed = append(resp.Removed, []byte(key))
+		}
+	}
+
+	sortKeys(resp.Added)
+	sortKeys(resp.Removed)
+	sortKeys(resp.Changed)
+	sortKeys(resp.Unknown)
+
+	return resp, nil
+}
+
+// versionKeys returns the key summary of a version as key HMAC -> value
+// fingerprint, and whether the fingerprints came from the client's summary.
+// Per-key versions without one fall back to their entries, fingerprinted by
+// the version each entry was last written in: ciphertexts are re-encrypted
+// under every new DEK, so they differ even for values that did not change.
+func (s *EnvServices) versionKeys(ctx context.Context, projectID uuid.UUID, envName string, version int32) (map[string][]byte, bool, error) {
+	env, err := s.q.GetEnv(ctx, database.GetEnvParams{
+		ProjectID: projectID,
+		EnvName:   envName,
+		Version:   version,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, false, errors.NotFound("Version "+strconv.Itoa(int(version)), "Check the environment name and version")
+		}
+		return nil, false, errors.Internal(err)
+	}
+
+	var metadata config.Metadata
+	_ = json.Unmarshal(env.Metadata, &metadata)
+
+	keys := make(map[string][]byte)
+	if metadata.Keys != nil {
+		for _, summary := range metadata.Keys {
+			keys[string(summary.KeyHMAC)] = summary.ValueFingerprint
+		}
+		return keys, true, nil
+	}
+
+	if env.ShreddedAt.Valid {
+		return nil, false, errors.Gone("Version "+strconv.Itoa(int(version))+" has been shredded", "Its entries were destroyed and it has no key summary to compare")
+	}
+
+	if env.EncryptionVersion != config.EncryptionVersionEntries {
+		return nil, false, errors.BadRequest("Version "+strconv.Itoa(int(version))+" has no key summary", "Only versions pushed with metadata.keys can be compared")
+	}
+
+	entries, err := s.q.ListEnvEntries(ctx, env.ID)
+	if err != nil {
+		return nil, false, errors.Internal(err)
+	}
+	for _, entry := range entries {
+		keys[string(entry.KeyHmac)] = []byte(strconv.Itoa(int(entry.ChangedInVersion)))
+	}
+	return keys, false, nil
+}
+
+func sortKeys(keys [][]byte) {
+	sort.Slice(keys, func(i, j int) bool {
+		return bytes.Compare(keys[i], keys[j]) < 0
+	})
+}
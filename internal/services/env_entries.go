@@ -0,0 +1,255 @@
+package services
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// PushEntries writes a per-key version. Unless Replace is set it patches the
+// latest published version: untouched entries are carried over with their
+// expiry dates and new ones must be encrypted under the same DEK, so pushes
+// that change different keys do not conflict with each other.
+func (s *EnvServices) PushEntries(ctx context.Context, req config.PushEnvEntriesRequest) (*config.PushEnvEntriesResponse, error) {
+	entry := AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID, Environment: &req.EnvName}
+
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	metadata, err := encodeMetadata(req.Metadata)
+	if err != nil {
+		return nil, err
+	}
+
+	env, err := s.pushEntries(ctx, req, metadata)
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"metadata": json.RawMessage(metadata), "version": env.Version, "status": env.Status, "replace": req.Replace, "set": len(req.Set), "deleted": len(req.Delete)})
+	s.audit.Log(ctx, entry)
+
+	return &config.PushEnvEntriesResponse{
+		Message: pushMessage(env.Status, "env entries pushed successfully"),
+		Version: env.Version,
+		Status:  env.Status,
+	}, nil
+}
+
+func (s *EnvServices) pushEntries(ctx context.Context, req config.PushEnvEntriesRequest, metadata json.RawMessage) (database.EnvVersion, error) {
+	environment, err := s.environments.ResolveForWrite(ctx, req.ProjectID, req.EnvName, req.UserID)
+	if err != nil {
+		return database.EnvVersion{}, err
+	}
+	if err = requireExpectedEnvironment(environment, req.ExpectedEnvironmentID); err != nil {
+		return database.EnvVersion{}, err
+	}
+	status, candidateExpiresAt := pushStatus(environment)
+
+	params := database.AddEnvParams{
+		ID:                 uuid.New(),
+		ProjectID:          req.ProjectID,
+		EnvName:            req.EnvName,
+		Ciphertext:         []byte{},
+		Nonce:              []byte{},
+		WrappedDek:         req.WrappedDEK,
+		DekNonce:           req.DekNonce,
+		EncryptionVersion:  config.EncryptionVersionEntries,
+		CreatedBy:          req.UserID,
+		Metadata:           metadata,
+		Status:             status,
+		CandidateExpiresAt: candidateExpiresAt,
+	}
+	if !req.Replace {
+		// insertEnvVersion takes the DEK of the per-key version being patched
+		params.WrappedDek, params.DekNonce = nil, nil
+	}
+
+	return s.insertEnvVersion(ctx, environment, req.ExpectedVersion, params, func(txQ *database.Queries, env database.EnvVersion, parent *database.EnvVersion) error {
+		return writeEnvEntries(ctx, txQ, req, env, parent)
+	})
+}
+
+// writeEnvEntries fills a per-key version inside the push transaction: the
+// parent's entries are carried over unless the push replaces them, then the
+// deletes and writes are applied.
+func writeEnvEntries(ctx context.Context, txQ *database.Queries, req config.PushEnvEntriesRequest, env database.EnvVersion, parent *database.EnvVersion) error {
+	// only a per-key parent has entries to patch or to check expectations against
+	if parent != nil && parent.EncryptionVersion != config.EncryptionVersionEntries {
+		parent = nil
+	}
+
+	for _, write := range req.Set {
+		if err := checkEntryVersion(ctx, txQ, parent, write.KeyHMAC, write.ExpectedVersion); err != nil {
+			return err
+		}
+	}
+	for _, del := range req.Delete {
+		if err := checkEntryVersion(ctx, txQ, parent, del.KeyHMAC, del.ExpectedVersion); err != nil {
+			return err
+		}
+	}
+
+	if !req.Replace {
+		if err := txQ.CopyEnvEntries(ctx, database.CopyEnvEntriesParams{
+			TargetVersionID: env.ID,
+			SourceVersionID: parent.ID,
+		}); err != nil {
+			return errors.Internal(err)
+		}
+	}
+
+	for _, del := range req.Delete {
+		deleted, err := txQ.DeleteEnvEntry(ctx, database.DeleteEnvEntryParams{
+			EnvVersionID: env.ID,
+			KeyHmac:      del.KeyHMAC,
+		})
+		if err != nil {
+			return errors.Internal(err)
+		}
+		if deleted == 0 {
+			return errors.NotFound("Entry "+base64.StdEncoding.EncodeToString(del.KeyHMAC), "Pull the latest version, the key may already be deleted")
+		}
+	}
+
+	for _, write := range req.Set {
+		if err := txQ.UpsertEnvEntry(ctx, database.UpsertEnvEntryParams{
+			EnvVersionID:     env.ID,
+			KeyHmac:          write.KeyHMAC,
+			NameCiphertext:   write.NameCiphertext,
+			NameNonce:        write.NameNonce,
+			ValueCiphertext:  write.ValueCiphertext,
+			ValueNonce:       write.ValueNonce,
+			ChangedInVersion: env.Version,
+		}); err != nil {
+			return errors.Internal(err)
+		}
+	}
+
+	if !req.Replace {
+		touched := make([][]byte, 0, len(req.Set)+len(req.Delete))
+		for _, write := range req.Set {
+			touched = append(touched, write.KeyHMAC)
+		}
+		for _, del := range req.Delete {
+			touched = append(touched, del.KeyHMAC)
+		}
+		if err := carryEnvExpiries(ctx, txQ, parent.ID, env.ID, touched); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// EntryHistory lists the published versions in which a key was written, newest first.
+func (s *EnvServices) EntryHistory(ctx context.Context, req config.EnvEntryHistoryRequest) (*config.EnvEntryHistoryResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.q.ListEnvEntryHistory(ctx, database.ListEnvEntryHistoryParams{
+		ProjectID: req.ProjectID,
+		EnvName:   req.EnvName,
+		KeyHmac:   req.KeyHMAC,
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.EnvEntryHistoryResponse{
+		Revisions: make([]config.EnvEntryRevision, len(rows)),
+	}
+	for i, row := range rows {
+		resp.Revisions[i] = config.EnvEntryRevision{
+			Version:         row.Version,
+			WrappedDEK:      row.WrappedDek,
+			DekNonce:        row.DekNonce,
+			NameCiphertext:  row.NameCiphertext,
+			NameNonce:       row.NameNonce,
+			ValueCiphertext: row.ValueCiphertext,
+			ValueNonce:      row.ValueNonce,
+			CreatedBy:       row.CreatedBy,
+			CreatedAt:       row.CreatedAt,
+		}
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID, Environment: &req.EnvName, Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"key_hmac": req.KeyHMAC, "revisions": len(rows)})})
+
+	return resp, nil
+}
+
+// listEnvEntries loads the entries of a per-key version; whole-file versions have none.
+func listEnvEntries(ctx context.Context, q *database.Queries, env database.EnvVersion) ([]config.EnvEntry, error) {
+	if env.EncryptionVersion != config.EncryptionVersionEntries {
+		return nil, nil
+	}
+
+	rows, err := q.ListEnvEntries(ctx, env.ID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	entries := make([]config.EnvEntry, len(rows))
+	for i, row := range rows {
+		entries[i] = toEnvEntry(row)
+	}
+	return entries, nil
+}
+
+// checkEntryVersion enforces a per-key expectation against the parent version.
+// A key missing from the parent counts as version 0.
+func checkEntryVersion(ctx context.Context, txQ *database.Queries, parent *database.EnvVersion, key []byte, expected *int32) error {
+	if expected == nil {
+		return nil
+	}
+
+	var current int32
+	if parent != nil {
+		existing, err := txQ.GetEnvEntry(ctx, database.GetEnvEntryParams{
+			EnvVersionID: parent.ID,
+			KeyHmac:      key,
+		})
+		switch {
+		case err == nil:
+			current = existing.ChangedInVersion
+		case !dberrors.IsNoRows(err):
+			return errors.Internal(err)
+		}
+	}
+
+	if *expected != current {
+		conflict := errors.Conflict("Key has changed since your last pull", "Pull the latest version, merge your changes and push again")
+		conflict.Fields = map[string]string{
+			"key_hmac":        base64.StdEncoding.EncodeToString(key),
+			"current_version": strconv.Itoa(int(current)),
+		}
+		return conflict
+	}
+	return nil
+}
+
+func toEnvEntry(row database.EnvEntry) config.EnvEntry {
+	return config.EnvEntry{
+		KeyHMAC:          row.KeyHmac,
+		NameCiphertext:   row.NameCiphertext,
+		NameNonce:        row.NameNonce,
+		ValueCiphertext:  row.ValueCiphertext,
+		ValueNonce:       row.ValueNonce,
+		ChangedInVersion: row.ChangedInVersion,
+	}
+}
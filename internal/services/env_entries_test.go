@@ -0,0 +1,102 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+func testEntryWrite(key string, expectedVersion *int32) config.EnvEntryWrite {
+	return config.EnvEntryWrite{
+		KeyHMAC:         []byte(key),
+		NameCiphertext:  []byte("name-" + key),
+		NameNonce:       []byte("name-nonce"),
+		ValueCiphertext: []byte("value-" + key),
+		ValueNonce:      []byte("value-nonce"),
+		ExpectedVersion: expectedVersion,
+	}
+}
+
+func TestPushEntriesPatchesLatestVersion(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+
+	if _, err := s.Env.PushEntries(ctx, config.PushEnvEntriesRequest{
+		ProjectID:  projectID,
+		UserID:     adminID,
+		EnvName:    "staging",
+		Replace:    true,
+		WrappedDEK: []byte("wrapped-dek"),
+		DekNonce:   []byte("dek-nonce"),
+		Set:        []config.EnvEntryWrite{testEntryWrite("PORT", nil), testEntryWrite("HOST", nil)},
+	}); err != nil {
+		t.Fatalf("push version 1: %v", err)
+	}
+
+	resp, err := s.Env.PushEntries(ctx, config.PushEnvEntriesRequest{
+		ProjectID: projectID,
+		UserID:    adminID,
+		EnvName:   "staging",
+		Set:       []config.EnvEntryWrite{testEntryWrite("PORT", helpers.Ptr(int32(1)))},
+		Delete:    []config.EnvEntryDelete{{KeyHMAC: []byte("HOST")}},
+	})
+	if err != nil {
+		t.Fatalf("push version 2: %v", err)
+	}
+
+	env, err := s.Env.q.GetEnv(ctx, database.GetEnvParams{ProjectID: projectID, EnvName: "staging", Version: resp.Version})
+	if err != nil {
+		t.Fatalf("load version 2: %v", err)
+	}
+	if !bytes.Equal(env.WrappedDek, []byte("wrapped-dek")) {
+		t.Errorf("version 2 has DEK %q, want the DEK of version 1", env.WrappedDek)
+	}
+	if !env.ParentVersion.Valid || env.ParentVersion.Int32 != 1 {
+		t.Errorf("version 2 has parent %v, want 1", env.ParentVersion)
+	}
+
+	entries, err := listEnvEntries(ctx, s.Env.q, env)
+	if err != nil {
+		t.Fatalf("list entries: %v", err)
+	}
+	if len(entries) != 1 || string(entries[0].KeyHMAC) != "PORT" || entries[0].ChangedInVersion != 2 {
+		t.Errorf("version 2 entries = %+v, want only PORT changed in version 2", entries)
+	}
+
+	// the stale per-key expectation on PORT now conflicts
+	_, err = s.Env.PushEntries(ctx, config.PushEnvEntriesRequest{
+		ProjectID: projectID,
+		UserID:    adminID,
+		EnvName:   "staging",
+		Set:       []config.EnvEntryWrite{testEntryWrite("PORT", helpers.Ptr(int32(1)))},
+	})
+	requireErrorCode(t, err, errors.CodeConflict)
+}
+
+func TestPushEntriesRequiresPerKeyVersion(t *testing.T) {
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+
+	if _, err := pushTestEnv(s, projectID, adminID, "staging", nil); err != nil {
+		t.Fatalf("push whole-file version: %v", err)
+	}
+
+	_, err := s.Env.PushEntries(context.Background(), config.PushEnvEntriesRequest{
+		ProjectID: projectID,
+		UserID:    adminID,
+		EnvName:   "staging",
+		Set:       []config.EnvEntryWrite{testEntryWrite("PORT", nil)},
+	})
+	requireErrorCode(t, err, errors.CodeBadRequest)
+}
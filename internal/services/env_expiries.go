@@ -0,0 +1,191 @@
+package services
+
+import (
+	"context"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+const (
+	// expiryReminderWindow is how long before a secret expires its reminder
+	// goes out, and the default window of Expiring.
+	expiryReminderWindow = 7 * 24 * time.Hour
+	expiryBatchSize      = 200
+)
+
+// Expiring lists the expiry dates of every environment's latest published
+// version that fall within the requested window, overdue ones included.
+func (s *EnvServices) Expiring(ctx context.Context, req config.EnvExpiringRequest) (*config.EnvExpiringResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	before := now.Add(expiryReminderWindow)
+	if req.WithinDays != nil {
+		before = now.AddDate(0, 0, int(*req.WithinDays))
+	}
+
+	rows, err := s.q.ListEnvExpiries(ctx, database.ListEnvExpiriesParams{
+		ProjectID: req.ProjectID,
+		Before:    before,
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.EnvExpiringResponse{
+		Expiries: make([]config.EnvExpiry, len(rows)),
+	}
+	for i, row := range rows {
+		resp.Expiries[i] = config.EnvExpiry{
+			EnvName:   row.EnvName,
+			Version:   row.Version,
+			KeyHMAC:   row.KeyHmac,
+			ExpiresAt: row.ExpiresAt,
+			Overdue:   !row.ExpiresAt.After(now),
+		}
+	}
+
+	return resp, nil
+}
+
+// NotifyExpiries announces secrets that just went overdue, then those expiring
+// within expiryReminderWindow. Each expiry is claimed before it is announced,
+// so replicas running the job at the same time never announce it twice.
+func (s *EnvServices) NotifyExpiries(ctx context.Context) error {
+	now := time.Now().UTC()
+
+	overdue, err := s.q.ListDueOverdueNotices(ctx, database.ListDueOverdueNoticesParams{
+		Now:      now,
+		LimitVal: expiryBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+	for _, row := range overdue {
+		claimed, err := s.q.MarkExpiryOverdueNotified(ctx, row.ID)
+		if err != nil {
+			log.Printf("expiry notice failed: project=%s env=%s err=%v", row.ProjectID, row.EnvName, err)
+			continue
+		}
+		if claimed == 0 {
+			continue
+		}
+		s.announceExpiry(ctx, config.EventEnvSecretOverdue, config.ActionEnvSecretOverdue, expiryNotice(row))
+	}
+
+	soon, err := s.q.ListDueExpiryReminders(ctx, database.ListDueExpiryRemindersParams{
+		Before:   now.Add(expiryReminderWindow),
+		LimitVal: expiryBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+	for _, row := range soon {
+		claimed, err := s.q.MarkExpiryReminded(ctx, row.ID)
+		if err != nil {
+			log.Printf("expiry reminder failed: project=%s env=%s err=%v", row.ProjectID, row.EnvName, err)
+			continue
+		}
+		if claimed == 0 {
+			continue
+		}
+		s.announceExpiry(ctx, config.EventEnvSecretExpiring, config.ActionEnvSecretExpiring, expiryNotice(row))
+	}
+
+	return nil
+}
+
+// expiryNotice is the part of an expiry row its announcements need.
+type expiryNotice struct {
+	ID        uuid.UUID
+	ProjectID uuid.UUID
+	EnvName   string
+	Version   int32
+	KeyHmac   []byte
+	ExpiresAt time.Time
+}
+
+// announceExpiry publishes an expiry to the event stream and, through the
+// audit log, to webhooks.
+func (s *EnvServices) announceExpiry(ctx context.Context, eventType, action string, notice expiryNotice) {
+	data := map[string]any{"version": notice.Version, "expires_at": notice.ExpiresAt}
+	if len(notice.KeyHmac) > 0 {
+		data["key_hmac"] = notice.KeyHmac
+	}
+
+	logEvent(ctx, s.db, s.q, eventRecord{
+		ProjectID: notice.ProjectID,
+		EnvName:   &notice.EnvName,
+		Type:      eventType,
+		Data:      data,
+	})
+
+	s.audit.Log(ctx, AuditEntry{Action: action, ActorType: config.ActorTypeSystem, ProjectID: &notice.ProjectID, Environment: &notice.EnvName, TargetID: helpers.Ptr(strconv.Itoa(int(notice.Version))), Status: config.StatusSuccess, Metadata: mustJSON(data), Severity: config.SeverityWarning})
+}
+
+// carryEnvExpiries copies the expiry dates of the parent of a partial update
+// for everything the update leaves untouched. Dates of keys it writes or
+// deletes are dropped, and dates its own metadata sets win.
+func carryEnvExpiries(ctx context.Context, txQ *database.Queries, parentID, envVersionID uuid.UUID, touched [][]byte) error {
+	own, err := txQ.ListEnvVersionExpiries(ctx, envVersionID)
+	if err != nil {
+		return errors.Internal(err)
+	}
+	inherited, err := txQ.ListEnvVersionExpiries(ctx, parentID)
+	if err != nil {
+		return errors.Internal(err)
+	}
+
+	// the whole-version date has a nil key and is tracked apart from the keys
+	skip := make(map[string]bool, len(own)+len(touched))
+	ownVersionDate := false
+	for _, expiry := range own {
+		if expiry.KeyHmac == nil {
+			ownVersionDate = true
+		}
+		skip[string(expiry.KeyHmac)] = true
+	}
+	for _, key := range touched {
+		skip[string(key)] = true
+	}
+
+	for _, expiry := range inherited {
+		if (expiry.KeyHmac == nil && ownVersionDate) || (expiry.KeyHmac != nil && skip[string(expiry.KeyHmac)]) {
+			continue
+		}
+		if err = txQ.CarryEnvExpiry(ctx, database.CarryEnvExpiryParams{
+			ID:                uuid.New(),
+			EnvVersionID:      envVersionID,
+			KeyHmac:           expiry.KeyHmac,
+			ExpiresAt:         expiry.ExpiresAt,
+			RemindedAt:        expiry.RemindedAt,
+			OverdueNotifiedAt: expiry.OverdueNotifiedAt,
+		}); err != nil {
+			return errors.Internal(err)
+		}
+	}
+	return nil
+}
+
+// addEnvExpiry stores one expiry date of a new version; keyHMAC is nil for
+// the whole version.
+func addEnvExpiry(ctx context.Context, txQ *database.Queries, envVersionID uuid.UUID, keyHMAC []byte, expiresAt time.Time) error {
+	if err := txQ.AddEnvExpiry(ctx, database.AddEnvExpiryParams{
+		ID:           uuid.New(),
+		EnvVersionID: envVersionID,
+		KeyHmac:      keyHMAC,
+		ExpiresAt:    expiresAt.UTC(),
+	}); err != nil {
+		return errors.Internal(err)
+	}
+	return nil
+}
@@ -0,0 +1,287 @@
+package services
+
+import (
+	"context"
+	"slices"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// maxInheritanceDepth caps how many levels an inheritance chain may have, so
+// a pull never loads more than this many parent versions.
+const maxInheritanceDepth = 8
+
+// SetParent makes an environment inherit from another environment of the same
+// project, or stop inheriting when no parent is given. Chains that would loop
+// back on themselves or grow past maxInheritanceDepth are refused.
+func (s *EnvironmentService) SetParent(ctx context.Context, req config.EnvironmentParentRequest) (*config.Environment, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	environment, err := s.get(ctx, req.ProjectID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	var parentID uuid.NullUUID
+	if req.Parent != nil {
+		parent, err := s.get(ctx, req.ProjectID, *req.Parent)
+		if err != nil {
+			return nil, err
+		}
+
+		ancestors, err := environmentAncestors(ctx, s.q, parent)
+		if err != nil {
+			return nil, err
+		}
+		inheritsFromChild := slices.ContainsFunc(ancestors, func(ancestor database.Environment) bool {
+			return ancestor.ID == environment.ID
+		})
+		if parent.ID == environment.ID || inheritsFromChild {
+			return nil, errors.Conflict("Environment "+parent.Name+" already inherits from "+environment.Name, "Inheritance cannot form a cycle")
+		}
+
+		_, height, err := descendants(ctx, s.q, environment.ID)
+		if err != nil {
+			return nil, errors.Internal(err)
+		}
+		if len(ancestors)+1+height > maxInheritanceDepth {
+			return nil, errors.Conflict("Inheritance chain would be too deep", "Chains may have at most 8 levels")
+		}
+
+		parentID = uuid.NullUUID{UUID: parent.ID, Valid: true}
+	}
+
+	updated, err := s.setParent(ctx, req, parentID)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentSettings, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, TargetID: helpers.Ptr(updated.ID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"parent": req.Parent})})
+
+	resp := toEnvironment(updated)
+	return &resp, nil
+}
+
+// setParent points the environment at parentID while holding the parent's row
+// lock, which deleting the parent also takes, so the parent cannot be deleted
+// between its checks and gaining a child.
+func (s *EnvironmentService) setParent(ctx context.Context, req config.EnvironmentParentRequest, parentID uuid.NullUUID) (database.Environment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Environment{}, errors.InternalMessage("Unable to begin inheritance transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	if parentID.Valid {
+		if _, err = txQ.GetEnvironmentForUpdate(ctx, parentID.UUID); err != nil {
+			if dberrors.IsNoRows(err) {
+				return database.Environment{}, errors.NotFound("Environment", "Check the parent environment name")
+			}
+			return database.Environment{}, errors.Internal(err)
+		}
+	}
+
+	updated, err := txQ.SetEnvironmentParent(ctx, database.SetEnvironmentParentParams{
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+		ParentID:  parentID,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.Environment{}, errors.NotFound("Environment", "Check the environment name")
+		}
+		return database.Environment{}, errors.Internal(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return database.Environment{}, errors.InternalMessage("Unable to commit inheritance transaction", err)
+	}
+	return updated, nil
+}
+
+// environmentAncestors walks an environment's parent chain, nearest parent first.
+func environmentAncestors(ctx context.Context, q *database.Queries, environment database.Environment) ([]database.Environment, error) {
+	var chain []database.Environment
+	for current := environment; current.ParentID.Valid; {
+		if len(chain) >= maxInheritanceDepth {
+			return nil, errors.InternalMessage("Environment inheritance chain is too deep", nil)
+		}
+
+		parent, err := q.GetEnvironmentByID(ctx, current.ParentID.UUID)
+		if err != nil {
+			return nil, errors.Internal(err)
+		}
+		chain = append(chain, parent)
+		current = parent
+	}
+	return chain, nil
+}
+
+// descendants returns every environment inheriting from id, directly or
+// through others, and how many levels below id the deepest one sits.
+func descendants(ctx context.Context, q *database.Queries, id uuid.UUID) ([]database.Environment, int, error) {
+	var (
+		all   []database.Environment
+		level = []uuid.UUID{id}
+		depth int
+	)
+	for len(level) > 0 && depth < maxInheritanceDepth {
+		var next []uuid.UUID
+		for _, parentID := range level {
+			children, err := q.ListChildEnvironments(ctx, uuid.NullUUID{UUID: parentID, Valid: true})
+			if err != nil {
+				return nil, 0, err
+			}
+			for _, child := range children {
+				all = append(all, child)
+				next = append(next, child.ID)
+			}
+		}
+		if len(next) > 0 {
+			depth++
+		}
+		level = next
+	}
+	return all, depth, nil
+}
+
+// parentChain returns the layers a read of env merges, root first. A pinned
+// read gets the parent versions env was pushed on top of; a read of the latest
+// version gets the latest published version of every ancestor.
+func (s *EnvServices) parentChain(ctx context.Context, environment database.Environment, env database.EnvVersion, pinned bool) ([]config.ParentEnv, error) {
+	var (
+		layers []database.EnvVersion
+		err    error
+	)
+	if pinned {
+		layers, err = s.recordedParentVersions(ctx, env)
+	} else {
+		layers, err = latestParentVersions(ctx, s.q, environment)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	chain := make([]config.ParentEnv, 0, len(layers))
+	for _, layer := range layers {
+		envResponse, err := s.envResponse(ctx, layer)
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, config.ParentEnv{EnvName: layer.EnvName, EnvResponse: envResponse, VersionID: layer.ID})
+	}
+	return chain, nil
+}
+
+// latestParentVersions loads the latest published version of every
+// environment the given one inherits from, root first. Ancestors with nothing
+// published yet add nothing and are left out.
+func latestParentVersions(ctx context.Context, q *database.Queries, environment database.Environment) ([]database.EnvVersion, error) {
+	ancestors, err := environmentAncestors(ctx, q, environment)
+	if err != nil {
+		return nil, err
+	}
+
+	layers := make([]database.EnvVersion, 0, len(ancestors))
+	for i := len(ancestors) - 1; i >= 0; i-- {
+		env, err := q.GetLatestEnv(ctx, database.GetLatestEnvParams{
+			ProjectID: ancestors[i].ProjectID,
+			EnvName:   ancestors[i].Name,
+		})
+		if err != nil {
+			if dberrors.IsNoRows(err) {
+				continue
+			}
+			return nil, errors.Internal(err)
+		}
+		layers = append(layers, env)
+	}
+	return layers, nil
+}
+
+// recordVersionParents stores the parent versions a new version is pushed on
+// top of. It runs in the push transaction.
+func recordVersionParents(ctx context.Context, txQ *database.Queries, environment database.Environment, env database.EnvVersion) error {
+	layers, err := latestParentVersions(ctx, txQ, environment)
+	if err != nil {
+		return err
+	}
+
+	for i, layer := range layers {
+		if err = txQ.AddEnvVersionParent(ctx, database.AddEnvVersionParentParams{
+			EnvVersionID:    env.ID,
+			Position:        int32(i),
+			ParentVersionID: layer.ID,
+		}); err != nil {
+			return errors.Internal(err)
+		}
+	}
+	return nil
+}
+
+// recordedParentVersions loads the parent versions recorded with env, root
+// first. A layer that was deleted or shredded since fails the read rather
+// than returning the version without it.
+func (s *EnvServices) recordedParentVersions(ctx context.Context, env database.EnvVersion) ([]database.EnvVersion, error) {
+	ids, err := s.q.ListEnvVersionParents(ctx, env.ID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	layers := make([]database.EnvVersion, 0, len(ids))
+	for _, id := range ids {
+		layer, err := s.q.GetEnvVersionByID(ctx, id)
+		if err != nil {
+			if dberrors.IsNoRows(err) {
+				return nil, errors.Gone("A parent layer of version "+strconv.Itoa(int(env.Version))+" no longer exists", "Pull the latest version instead")
+			}
+			return nil, errors.Internal(err)
+		}
+		if layer.ShreddedAt.Valid {
+			return nil, errors.Gone("A parent layer of version "+strconv.Itoa(int(env.Version))+" has been shredded", "Pull the latest version instead")
+		}
+		layers = append(layers, layer)
+	}
+	return layers, nil
+}
+
+// recordInheritedEvents tells every environment inheriting from the one a
+// version was published to that a layer it merges has changed.
+func recordInheritedEvents(ctx context.Context, q *database.Queries, env database.EnvVersion) error {
+	environment, err := q.GetEnvironment(ctx, database.GetEnvironmentParams{
+		ProjectID: env.ProjectID,
+		Name:      env.EnvName,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil
+		}
+		return errors.Internal(err)
+	}
+
+	children, _, err := descendants(ctx, q, environment.ID)
+	if err != nil {
+		return errors.Internal(err)
+	}
+	for _, child := range children {
+		if err = recordEvent(ctx, q, eventRecord{
+			ProjectID: env.ProjectID,
+			EnvName:   &child.Name,
+			Type:      config.EventEnvParentUpdated,
+			Data:      map[string]any{"parent": env.EnvName, "version": env.Version},
+		}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,356 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+func (s *EnvServices) ListLabels(ctx context.Context, req config.EnvLabelsRequest) (*config.EnvLabelsResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	environment, err := s.getEnvironment(ctx, req.ProjectID, req.EnvName)
+	if err != nil {
+		return nil, err
+	}
+
+	labels, err := s.q.ListEnvLabels(ctx, environment.ID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.EnvLabelsResponse{
+		Labels: make([]config.EnvLabel, len(labels)),
+	}
+	for i, label := range labels {
+		resp.Labels[i] = toEnvLabel(label)
+	}
+	return resp, nil
+}
+
+// SetLabel creates a label or moves it to another published version. On
+// protected environments only admins may move labels, since pinned
+// delegations deploy whatever the label points at.
+func (s *EnvServices) SetLabel(ctx context.Context, req config.EnvLabelSetRequest) (*config.EnvLabel, error) {
+	entry := AuditEntry{Action: config.ActionEnvLabelSet, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID, Environment: &req.EnvName, TargetID: &req.Label}
+
+	member, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID)
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	if err = validateLabelName(req.Label); err != nil {
+		return nil, err
+	}
+
+	environment, err := s.environments.ResolveForWrite(ctx, req.ProjectID, req.EnvName, req.UserID)
+	if err != nil {
+		return nil, err
+	}
+	if environment.Protected && member.Role != "admin" {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, errors.Forbidden("Only project admins can move labels on a protected environment", "")
+	}
+
+	label, previous, err := s.setLabel(ctx, environment, req)
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"label": req.Label, "from_version": previous, "version": req.Version})
+	s.audit.Log(ctx, entry)
+
+	resp := toEnvLabel(label)
+	return &resp, nil
+}
+
+// setLabel moves the label under the environment's row lock, which shredding
+// also takes, so a label cannot land on a version while it is being shredded.
+// It returns the version the label pointed at before, if any.
+func (s *EnvServices) setLabel(ctx context.Context, environment database.Environment, req config.EnvLabelSetRequest) (database.EnvLabel, *int32, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.EnvLabel{}, nil, errors.InternalMessage("Unable to begin label transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	if environment, err = txQ.GetEnvironmentForUpdate(ctx, environment.ID); err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.EnvLabel{}, nil, errors.NotFound("Environment", "Check the environment name")
+		}
+		return database.EnvLabel{}, nil, errors.Internal(err)
+	}
+
+	version, err := txQ.GetEnv(ctx, database.GetEnvParams{
+		ProjectID: environment.ProjectID,
+		EnvName:   environment.Name,
+		Version:   req.Version,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.EnvLabel{}, nil, errors.NotFound("Version", "Labels can only point at published versions")
+		}
+		return database.EnvLabel{}, nil, errors.Internal(err)
+	}
+	if version.ShreddedAt.Valid {
+		return database.EnvLabel{}, nil, errors.Gone("Version "+strconv.Itoa(int(req.Version))+" has been shredded", "Point the label at a version that is kept")
+	}
+
+	var previous *int32
+	if existing, err := txQ.GetEnvLabel(ctx, database.GetEnvLabelParams{
+		EnvironmentID: environment.ID,
+		Name:          req.Label,
+	}); err == nil {
+		previous = &existing.Version
+	} else if !dberrors.IsNoRows(err) {
+		return database.EnvLabel{}, nil, errors.Internal(err)
+	}
+
+	label, err := txQ.UpsertEnvLabel(ctx, database.UpsertEnvLabelParams{
+		ID:            uuid.New(),
+		EnvironmentID: environment.ID,
+		Name:          req.Label,
+		Version:       req.Version,
+		UpdatedBy:     req.UserID,
+	})
+	if err != nil {
+		return database.EnvLabel{}, nil, errors.Internal(err)
+	}
+
+	if err = recordEvent(ctx, txQ, eventRecord{
+		ProjectID: environment.ProjectID,
+		EnvName:   &environment.Name,
+		Type:      config.EventEnvLabelMoved,
+		Data:      map[string]any{"label": req.Label, "from_version": previous, "version": req.Version},
+	}); err != nil {
+		return database.EnvLabel{}, nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return database.EnvLabel{}, nil, errors.InternalMessage("Unable to commit label transaction", err)
+	}
+	return label, previous, nil
+}
+
+func (s *EnvServices) DeleteLabel(ctx context.Context, req config.EnvLabelDeleteRequest) error {
+	entry := AuditEntry{Action: config.ActionEnvLabelDelete, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID, Environment: &req.EnvName, TargetID: &req.Label}
+
+	member, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID)
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return err
+	}
+
+	environment, err := s.getEnvironment(ctx, req.ProjectID, req.EnvName)
+	if err != nil {
+		return err
+	}
+	if environment.Protected && member.Role != "admin" {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return errors.Forbidden("Only project admins can delete labels on a protected environment", "")
+	}
+	if err = requireUnlocked(ctx, s.q, environment); err != nil {
+		return err
+	}
+
+	pinned, err := s.q.CountDelegationsPinnedToLabel(ctx, database.CountDelegationsPinnedToLabelParams{
+		ProjectID:   req.ProjectID,
+		Env:         req.EnvName,
+		PinnedLabel: sql.NullString{String: req.Label, Valid: true},
+	})
+	if err != nil {
+		return errors.Internal(err)
+	}
+	if pinned > 0 {
+		return errors.Conflict("Label is pinned by a service delegation", "Unpin the delegation with /service_role/pin first")
+	}
+
+	deleted, err := s.q.DeleteEnvLabel(ctx, database.DeleteEnvLabelParams{
+		EnvironmentID: environment.ID,
+		Name:          req.Label,
+	})
+	if err != nil {
+		return errors.Internal(err)
+	}
+	if deleted == 0 {
+		return errors.NotFound("Label", "Check the label name")
+	}
+
+	entry.Status = config.StatusSuccess
+	s.audit.Log(ctx, entry)
+	return nil
+}
+
+// resolveEnvVersion loads the published version a read asks for: an explicit
+// version, the target of a label, or the latest one.
+func (s *EnvServices) resolveEnvVersion(ctx context.Context, projectID uuid.UUID, envName string, version *int32, label *string) (database.EnvVersion, error) {
+	if label != nil {
+		labelled, err := s.labelVersion(ctx, projectID, envName, *label)
+		if err != nil {
+			return database.EnvVersion{}, err
+		}
+		version = &labelled
+	}
+
+	var (
+		env database.EnvVersion
+		err error
+	)
+	if version != nil {
+		env, err = s.q.GetEnv(ctx, database.GetEnvParams{
+			ProjectID: projectID,
+			EnvName:   envName,
+			Version:   *version,
+		})
+	} else {
+		env, err = s.q.GetLatestEnv(ctx, database.GetLatestEnvParams{
+			ProjectID: projectID,
+			EnvName:   envName,
+		})
+	}
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.EnvVersion{}, errors.NotFound("Environment", "Check the environment name and version")
+		}
+		return database.EnvVersion{}, errors.Internal(err)
+	}
+	if env.ShreddedAt.Valid {
+		return database.EnvVersion{}, errors.Gone("Version "+strconv.Itoa(int(env.Version))+" has been shredded", "Its key and ciphertext were destroyed; pull a newer version")
+	}
+	return env, nil
+}
+
+// currentEnvVersion resolves a read like resolveEnvVersion but only returns the
+// version number, so conditional pulls never load ciphertext.
+func (s *EnvServices) currentEnvVersion(ctx context.Context, projectID uuid.UUID, envName string, version *int32, label *string) (int32, error) {
+	if label != nil {
+		labelled, err := s.labelVersion(ctx, projectID, envName, *label)
+		if err != nil {
+			return 0, err
+		}
+		version = &labelled
+	}
+
+	params := database.GetPublishedEnvVersionParams{
+		ProjectID: projectID,
+		EnvName:   envName,
+	}
+	if version != nil {
+		params.Version = sql.NullInt32{Int32: *version, Valid: true}
+	}
+
+	current, err := s.q.GetPublishedEnvVersion(ctx, params)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return 0, errors.NotFound("Environment", "Check the environment name and version")
+		}
+		return 0, errors.Internal(err)
+	}
+	return current, nil
+}
+
+func (s *EnvServices) labelVersion(ctx context.Context, projectID uuid.UUID, envName, label string) (int32, error) {
+	version, err := s.q.ResolveEnvLabel(ctx, database.ResolveEnvLabelParams{
+		ProjectID: projectID,
+		EnvName:   envName,
+		Label:     label,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return 0, errors.NotFound("Label "+label, "Check the environment name and label")
+		}
+		return 0, errors.Internal(err)
+	}
+	return version, nil
+}
+
+// pinnedLabel returns the label a service role's delegation is pinned to, nil
+// when it follows the latest version.
+func (s *EnvServices) pinnedLabel(ctx context.Context, serviceRoleID, projectID uuid.UUID, envName string) (*string, error) {
+	delegation, err := s.q.GetDelegatedKeys(ctx, database.GetDelegatedKeysParams{
+		ServiceRoleID: serviceRoleID,
+		ProjectID:     projectID,
+		Env:           envName,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.Forbidden("Service role is not delegated to this environment", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	if !delegation.PinnedLabel.Valid {
+		return nil, nil
+	}
+	return &delegation.PinnedLabel.String, nil
+}
+
+// requireEnvLabel checks that a label exists before a delegation is pinned to it.
+func requireEnvLabel(ctx context.Context, q *database.Queries, projectID uuid.UUID, envName, label string) error {
+	_, err := q.ResolveEnvLabel(ctx, database.ResolveEnvLabelParams{
+		ProjectID: projectID,
+		EnvName:   envName,
+		Label:     label,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return errors.NotFound("Label "+label, "Create it with /env/labels/set first")
+		}
+		return errors.Internal(err)
+	}
+	return nil
+}
+
+func (s *EnvServices) getEnvironment(ctx context.Context, projectID uuid.UUID, name string) (database.Environment, error) {
+	environment, err := s.q.GetEnvironment(ctx, database.GetEnvironmentParams{
+		ProjectID: projectID,
+		Name:      name,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.Environment{}, errors.NotFound("Environment", "Check the environment name")
+		}
+		return database.Environment{}, errors.Internal(err)
+	}
+	return environment, nil
+}
+
+// validateLabelName follows the environment naming rules; "latest" is
+// reserved because it is what an unpinned read already returns.
+func validateLabelName(name string) error {
+	if !environmentNamePattern.MatchString(name) {
+		return errors.Validation(map[string]string{"label": "must be 1-63 lowercase letters, digits, '.', '_' or '-'"})
+	}
+	if name == "latest" {
+		return errors.Validation(map[string]string{"label": "latest is reserved"})
+	}
+	return nil
+}
+
+func toEnvLabel(row database.EnvLabel) config.EnvLabel {
+	return config.EnvLabel{
+		Name:      row.Name,
+		Version:   row.Version,
+		UpdatedBy: row.UpdatedBy,
+		UpdatedAt: row.UpdatedAt,
+	}
+}
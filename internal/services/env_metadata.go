@@ -0,0 +1,137 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+)
+
+// likeEscaper escapes LIKE wildcards so a search query matches literally.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// SearchVersions finds published versions by tag or message without returning any ciphertext.
+func (s *EnvServices) SearchVersions(ctx context.Context, req config.SearchEnvVersionsRequest) (*config.SearchEnvVersionsResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 50
+	} else if limit > 200 {
+		limit = 200
+	}
+
+	var messagePattern sql.NullString
+	if req.Query != nil && *req.Query != "" {
+		messagePattern = sql.NullString{String: "%" + likeEscaper.Replace(strings.ToLower(*req.Query)) + "%", Valid: true}
+	}
+
+	rows, err := s.q.SearchEnvVersions(ctx, database.SearchEnvVersionsParams{
+		ProjectID:      req.ProjectID,
+		EnvName:        nullString(req.EnvName),
+		Tag:            nullString(req.Tag),
+		MessagePattern: messagePattern,
+		LimitVal:       limit,
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.SearchEnvVersionsResponse{
+		Versions: make([]config.EnvVersionSummary, len(rows)),
+	}
+	for i, row := range rows {
+		resp.Versions[i] = config.EnvVersionSummary{
+			EnvName:           row.EnvName,
+			Version:           row.Version,
+			Metadata:          decodeMetadata(row.Metadata, row.ParentVersion),
+			EncryptionVersion: row.EncryptionVersion,
+			Size:              row.Size,
+			CreatedBy:         row.CreatedBy,
+			CreatedByEmail:    row.CreatedByEmail,
+			CreatedAt:         row.CreatedAt,
+			ShreddedAt:        nullTimePtr(row.ShreddedAt),
+		}
+	}
+
+	return resp, nil
+}
+
+// encodeMetadata serializes version metadata for storage. The parent version is
+// tracked in its own column, so whatever the client sent is dropped.
+func encodeMetadata(metadata config.Metadata) (json.RawMessage, error) {
+	metadata.ParentVersion = nil
+
+	raw, err := json.Marshal(metadata)
+	if err != nil {
+		return nil, errors.InternalMessage("Failed to serialize env metadata", err)
+	}
+	return raw, nil
+}
+
+// decodeMetadata reads stored metadata for responses. Metadata that no longer
+// decodes is reported as unknown rather than hiding the version.
+func decodeMetadata(raw json.RawMessage, parentVersion sql.NullInt32) config.Metadata {
+	var metadata config.Metadata
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		metadata = config.Metadata{Type: config.MetadataTypeUnknown}
+	}
+	metadata.ParentVersion = nil
+	if parentVersion.Valid {
+		metadata.ParentVersion = &parentVersion.Int32
+	}
+	return metadata
+}
+
+// indexVersionMetadata copies the message, tags and expiry dates of a new
+// version into their own tables. It runs in the push transaction.
+func indexVersionMetadata(ctx context.Context, txQ *database.Queries, envVersionID uuid.UUID, raw json.RawMessage) error {
+	if len(raw) == 0 {
+		return nil
+	}
+
+	var metadata config.Metadata
+	if err := json.Unmarshal(raw, &metadata); err != nil {
+		return errors.BadRequest("Version metadata does not decode", err.Error())
+	}
+
+	if metadata.Message != "" {
+		if err := txQ.SetEnvVersionMessage(ctx, database.SetEnvVersionMessageParams{
+			ID:      envVersionID,
+			Message: sql.NullString{String: metadata.Message, Valid: true},
+		}); err != nil {
+			return errors.Internal(err)
+		}
+	}
+
+	for _, tag := range metadata.Tags {
+		if err := txQ.AddEnvVersionTag(ctx, database.AddEnvVersionTagParams{
+			EnvVersionID: envVersionID,
+			Tag:          tag,
+		}); err != nil {
+			return errors.Internal(err)
+		}
+	}
+
+	if metadata.ExpiresAt != nil {
+		if err := addEnvExpiry(ctx, txQ, envVersionID, nil, *metadata.ExpiresAt); err != nil {
+			return err
+		}
+	}
+	for _, summary := range metadata.Keys {
+		if summary.ExpiresAt != nil {
+			if err := addEnvExpiry(ctx, txQ, envVersionID, summary.KeyHMAC, *summary.ExpiresAt); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
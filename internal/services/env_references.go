@@ -0,0 +1,291 @@
+package services
+
+import (
+	"bytes"
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// maxEnvReferences caps how many references one environment may declare, so a
+// pull never fans out into more than this many other environments.
+const maxEnvReferences = 20
+
+func (s *EnvironmentService) References(ctx context.Context, req config.EnvironmentReferencesRequest) (*config.EnvironmentReferencesResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	environment, err := s.get(ctx, req.ProjectID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.q.ListEnvReferences(ctx, environment.ID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.EnvironmentReferencesResponse{
+		References: make([]config.EnvironmentReference, len(rows)),
+	}
+	for i, row := range rows {
+		resp.References[i] = config.EnvironmentReference{
+			ID:           row.ID,
+			RefProjectID: row.RefProjectID,
+			RefEnvName:   row.RefEnvName,
+			KeyHMAC:      row.KeyHmac,
+			CreatedBy:    row.CreatedBy,
+			CreatedAt:    row.CreatedAt,
+		}
+	}
+	return resp, nil
+}
+
+// AddReference makes pulls of an environment also return an environment, or
+// one entry of it, from another project. The admin declaring it must be able
+// to read the referenced project; pulls check the puller's own access again.
+func (s *EnvironmentService) AddReference(ctx context.Context, req config.EnvironmentReferenceAddRequest) (*config.EnvironmentReference, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	if req.RefProjectID == req.ProjectID {
+		return nil, errors.Validation(map[string]string{"ref_project_id": "references must point at another project; use a parent environment within a project"})
+	}
+
+	environment, err := s.get(ctx, req.ProjectID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err = requireUnlocked(ctx, s.q, environment); err != nil {
+		return nil, err
+	}
+
+	if _, err = requireProjectMember(ctx, s.q, req.RefProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+	target, err := s.get(ctx, req.RefProjectID, req.RefEnvName)
+	if err != nil {
+		return nil, err
+	}
+
+	reference, err := s.addReference(ctx, environment, target, req)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentSettings, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, TargetID: helpers.Ptr(reference.ID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"reference_added": map[string]any{"ref_project_id": req.RefProjectID, "ref_env_name": req.RefEnvName, "key_hmac": req.KeyHMAC}})})
+
+	return &config.EnvironmentReference{
+		ID:           reference.ID,
+		RefProjectID: req.RefProjectID,
+		RefEnvName:   target.Name,
+		KeyHMAC:      reference.KeyHmac,
+		CreatedBy:    reference.CreatedBy,
+		CreatedAt:    reference.CreatedAt,
+	}, nil
+}
+
+// addReference inserts the reference holding the row locks of both
+// environments: the referencing one so a lock or a concurrent reference is
+// seen, and the referenced one so it cannot be deleted while gaining a
+// referrer. Rows are locked in id order so opposite references do not deadlock.
+func (s *EnvironmentService) addReference(ctx context.Context, environment, target database.Environment, req config.EnvironmentReferenceAddRequest) (database.EnvReference, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.EnvReference{}, errors.InternalMessage("Unable to begin reference transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	locked := map[uuid.UUID]database.Environment{}
+	ids := []uuid.UUID{environment.ID, target.ID}
+	if ids[1].String() < ids[0].String() {
+		ids[0], ids[1] = ids[1], ids[0]
+	}
+	for _, id := range ids {
+		row, err := txQ.GetEnvironmentForUpdate(ctx, id)
+		if err != nil {
+			if dberrors.IsNoRows(err) {
+				return database.EnvReference{}, errors.NotFound("Environment", "Check the environment names")
+			}
+			return database.EnvReference{}, errors.Internal(err)
+		}
+		locked[id] = row
+	}
+	if err = requireUnlocked(ctx, txQ, locked[environment.ID]); err != nil {
+		return database.EnvReference{}, err
+	}
+
+	existing, err := txQ.ListEnvReferences(ctx, environment.ID)
+	if err != nil {
+		return database.EnvReference{}, errors.Internal(err)
+	}
+	if len(existing) >= maxEnvReferences {
+		return database.EnvReference{}, errors.Conflict("Environment "+req.Name+" already has 20 references", "Remove a reference first")
+	}
+
+	reference, err := txQ.AddEnvReference(ctx, database.AddEnvReferenceParams{
+		ID:               uuid.New(),
+		EnvironmentID:    environment.ID,
+		RefEnvironmentID: target.ID,
+		KeyHmac:          req.KeyHMAC,
+		CreatedBy:        req.AdminID,
+	})
+	if err != nil {
+		if dberrors.IsUniqueViolation(err) {
+			return database.EnvReference{}, errors.Conflict("Environment "+req.Name+" already has this reference", "")
+		}
+		return database.EnvReference{}, errors.Internal(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return database.EnvReference{}, errors.InternalMessage("Unable to commit reference transaction", err)
+	}
+	return reference, nil
+}
+
+func (s *EnvironmentService) RemoveReference(ctx context.Context, req config.EnvironmentReferenceRemoveRequest) error {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return err
+	}
+
+	environment, err := s.get(ctx, req.ProjectID, req.Name)
+	if err != nil {
+		return err
+	}
+	if err = requireUnlocked(ctx, s.q, environment); err != nil {
+		return err
+	}
+
+	deleted, err := s.q.DeleteEnvReference(ctx, database.DeleteEnvReferenceParams{
+		ID:            req.ID,
+		EnvironmentID: environment.ID,
+	})
+	if err != nil {
+		return errors.Internal(err)
+	}
+	if deleted == 0 {
+		return errors.NotFound("Reference", "List the environment's references with /environments/references")
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentSettings, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, TargetID: helpers.Ptr(req.ID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"reference_removed": req.ID})})
+	return nil
+}
+
+// resolveReferences loads what an environment references for a pull by
+// userID. Each reference is checked against the caller's own membership of
+// the referenced project and fails on its own; every referenced environment
+// read is audited as a pull in its project. Only the referenced environment's
+// own latest version is returned, its parents and references are not followed.
+func (s *EnvServices) resolveReferences(ctx context.Context, environment database.Environment, references []database.ListEnvReferencesRow, userID uuid.UUID) ([]config.ResolvedReference, []config.GetUserProjectResponse) {
+	if len(references) == 0 {
+		return nil, nil
+	}
+
+	var (
+		resolved    = make([]config.ResolvedReference, 0, len(references))
+		keys        []config.GetUserProjectResponse
+		projectErrs = make(map[uuid.UUID]error)
+		email       = actorEmail(ctx, s.q, userID)
+	)
+	for _, reference := range references {
+		result := config.ResolvedReference{
+			ID:           reference.ID,
+			RefProjectID: reference.RefProjectID,
+			RefEnvName:   reference.RefEnvName,
+			KeyHMAC:      reference.KeyHmac,
+		}
+
+		projectErr, seen := projectErrs[reference.RefProjectID]
+		if !seen {
+			var key *config.GetUserProjectResponse
+			key, projectErr = s.batchProjectKey(ctx, reference.RefProjectID, userID)
+			projectErrs[reference.RefProjectID] = projectErr
+			if projectErr == nil {
+				keys = append(keys, *key)
+			}
+		}
+
+		err := projectErr
+		if err == nil {
+			result.Env, err = s.referencedEnv(ctx, reference)
+		}
+
+		entry := AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: userID.String(), ActorEmail: email, ProjectID: &reference.RefProjectID, Environment: &reference.RefEnvName}
+		via := map[string]any{"via_project_id": environment.ProjectID, "via_env_name": environment.Name}
+		if err != nil {
+			result.Error = batchError(err)
+			entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(result.Error.Message)
+		} else {
+			via["version"] = result.Env.Version
+			entry.Status = config.StatusSuccess
+		}
+		entry.Metadata = mustJSON(via)
+		s.audit.Log(ctx, entry)
+
+		resolved = append(resolved, result)
+	}
+
+	return resolved, keys
+}
+
+// unresolvedReferences reports every reference of a CI pull as failed. A CI
+// session holds no keys for the referenced projects, so the pipeline learns
+// which values it is missing instead of getting an environment without them.
+func unresolvedReferences(references []database.ListEnvReferencesRow) []config.ResolvedReference {
+	if len(references) == 0 {
+		return nil
+	}
+
+	refErr := batchError(errors.Forbidden("CI sessions cannot read referenced environments", "Pull the referenced environment with a CI session of its own project"))
+	resolved := make([]config.ResolvedReference, len(references))
+	for i, reference := range references {
+		resolved[i] = config.ResolvedReference{
+			ID:           reference.ID,
+			RefProjectID: reference.RefProjectID,
+			RefEnvName:   reference.RefEnvName,
+			KeyHMAC:      reference.KeyHmac,
+			Error:        refErr,
+		}
+	}
+	return resolved
+}
+
+// referencedEnv reads the latest published version of a referenced
+// environment, cut down to the referenced entry for entry references.
+func (s *EnvServices) referencedEnv(ctx context.Context, reference database.ListEnvReferencesRow) (*config.EnvResponse, error) {
+	env, err := s.resolveEnvVersion(ctx, reference.RefProjectID, reference.RefEnvName, nil, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.envResponse(ctx, env)
+	if err != nil {
+		return nil, err
+	}
+	if reference.KeyHmac == nil {
+		return &resp, nil
+	}
+
+	if env.EncryptionVersion != config.EncryptionVersionEntries {
+		return nil, errors.Conflict("Environment "+reference.RefEnvName+" does not store per-key entries", "Reference the whole environment instead")
+	}
+
+	// the key summary would list every other key of the environment
+	resp.Metadata.Keys = nil
+	for _, entry := range resp.Entries {
+		if bytes.Equal(entry.KeyHMAC, reference.KeyHmac) {
+			resp.Entries = []config.EnvEntry{entry}
+			return &resp, nil
+		}
+	}
+	return nil, errors.NotFound("Referenced entry", "The key was removed from "+reference.RefEnvName)
+}
@@ -0,0 +1,54 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+)
+
+func TestReferencedEnvironmentCannotBeDeleted(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServices(t)
+	appAdminID := createTestUser(t, s, "app-admin@example.com")
+	sharedAdminID := createTestUser(t, s, "shared-admin@example.com")
+	appProjectID := createTestProject(t, s, appAdminID, map[uuid.UUID]string{})
+	sharedProjectID := createTestProject(t, s, sharedAdminID, map[uuid.UUID]string{appAdminID: "member"})
+	createTestEnvironment(t, s, appProjectID, appAdminID, "production", false)
+	createTestEnvironment(t, s, sharedProjectID, sharedAdminID, "common", false)
+
+	reference, err := s.Environments.AddReference(ctx, config.EnvironmentReferenceAddRequest{
+		ProjectID:    appProjectID,
+		AdminID:      appAdminID,
+		Name:         "production",
+		RefProjectID: sharedProjectID,
+		RefEnvName:   "common",
+	})
+	if err != nil {
+		t.Fatalf("add reference: %v", err)
+	}
+
+	deleteCommon := func() error {
+		_, err := s.Environments.Delete(ctx, config.EnvironmentDeleteRequest{ProjectID: sharedProjectID, AdminID: sharedAdminID, Name: "common", Confirm: "common"})
+		return err
+	}
+	requireErrorCode(t, deleteCommon(), errors.CodeConflict)
+
+	if err = s.Environments.RemoveReference(ctx, config.EnvironmentReferenceRemoveRequest{ProjectID: appProjectID, AdminID: appAdminID, Name: "production", ID: reference.ID}); err != nil {
+		t.Fatalf("remove reference: %v", err)
+	}
+	if err = deleteCommon(); err != nil {
+		t.Fatalf("delete once no longer referenced: %v", err)
+	}
+
+	_, err = s.Environments.AddReference(ctx, config.EnvironmentReferenceAddRequest{
+		ProjectID:    appProjectID,
+		AdminID:      appAdminID,
+		Name:         "production",
+		RefProjectID: sharedProjectID,
+		RefEnvName:   "common",
+	})
+	requireErrorCode(t, err, errors.Code("ENVIRONMENT_NOT_FOUND"))
+}
@@ -0,0 +1,260 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// Shred destroys the wrapped DEK, ciphertext and entries of chosen versions,
+// leaving only their metadata. The latest published version, versions a label
+// points at and pending candidates cannot be shredded, nor can a version whose
+// DEK a retained version still holds: partial pushes reuse the parent's DEK, so
+// shredding one of them alone would leave its ciphertext decryptable.
+func (s *EnvServices) Shred(ctx context.Context, req config.ShredEnvRequest) (*config.ShredEnvResponse, error) {
+	entry := AuditEntry{Action: config.ActionEnvShred, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.EnvName, Severity: config.SeverityWarning}
+
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	environment, err := s.getEnvironment(ctx, req.ProjectID, req.EnvName)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = s.shredVersions(ctx, environment.ID, uuid.NullUUID{UUID: req.AdminID, Valid: true}, func(versions []database.ListUnshreddedEnvVersionsRow, shreddable map[int32]uuid.UUID) ([]int32, error) {
+		requested := make(map[int32]uuid.UUID, len(req.Versions))
+		for _, version := range req.Versions {
+			id, ok := shreddable[version]
+			if !ok {
+				return nil, errors.Conflict("Version "+strconv.Itoa(int(version))+" cannot be shredded", "It may not exist, be shredded already, be the latest version, be labelled or be a pending candidate")
+			}
+			requested[version] = id
+		}
+		if blocked := sharedDEKVersions(versions, requested); len(blocked) > 0 {
+			return nil, errors.Conflict("Version "+strconv.Itoa(int(blocked[0]))+" shares its DEK with a version that is kept", "Shred every version pushed with that DEK together, or push all entries with replace and a new DEK first")
+		}
+		return req.Versions, nil
+	})
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"versions": req.Versions, "reason": req.Reason})
+	s.audit.Log(ctx, entry)
+
+	return &config.ShredEnvResponse{
+		Message:  "Versions shredded",
+		Shredded: req.Versions,
+	}, nil
+}
+
+// ApplyRetention shreds the versions that fall outside their environment's
+// retention policy. It runs as a background job; shredding an already shredded
+// version is a no-op, so replicas may overlap.
+func (s *EnvServices) ApplyRetention(ctx context.Context) error {
+	environments, err := s.q.ListEnvironmentsWithRetention(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, row := range environments {
+		keepVersions, keepDays := row.RetentionKeepVersions, row.RetentionKeepDays
+		if !keepVersions.Valid && !keepDays.Valid {
+			keepVersions, keepDays = row.ProjectKeepVersions, row.ProjectKeepDays
+		}
+
+		if err := s.applyRetention(ctx, row.ID, row.ProjectID, row.Name, keepVersions, keepDays); err != nil {
+			log.Printf("retention failed: project=%s env=%s err=%v", row.ProjectID, row.Name, err)
+		}
+	}
+	return nil
+}
+
+func (s *EnvServices) applyRetention(ctx context.Context, environmentID, projectID uuid.UUID, envName string, keepVersions, keepDays sql.NullInt32) error {
+	cutoff := time.Now().UTC().AddDate(0, 0, -int(keepDays.Int32))
+
+	shredded, err := s.shredVersions(ctx, environmentID, uuid.NullUUID{}, func(versions []database.ListUnshreddedEnvVersionsRow, shreddable map[int32]uuid.UUID) ([]int32, error) {
+		expired := make(map[int32]uuid.UUID)
+		rank := 0
+		for _, version := range versions {
+			if version.Status == config.EnvVersionPending {
+				continue
+			}
+			keptByCount := keepVersions.Valid && rank < int(keepVersions.Int32)
+			keptByAge := keepDays.Valid && version.CreatedAt.After(cutoff)
+			rank++
+			if keptByCount || keptByAge {
+				continue
+			}
+			if id, ok := shreddable[version.Version]; ok {
+				expired[version.Version] = id
+			}
+		}
+		// a version sharing its DEK with a kept one waits until they expire together
+		for _, version := range sharedDEKVersions(versions, expired) {
+			delete(expired, version)
+		}
+
+		var chosen []int32
+		for _, version := range versions {
+			if _, ok := expired[version.Version]; ok {
+				chosen = append(chosen, version.Version)
+			}
+		}
+		return chosen, nil
+	})
+	if err != nil {
+		return err
+	}
+	if len(shredded) == 0 {
+		return nil
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvShred, ActorType: config.ActorTypeSystem, ProjectID: &projectID, Environment: &envName, Status: config.StatusSuccess, Severity: config.SeverityWarning, Metadata: mustJSON(map[string]any{"versions": shredded, "reason": "retention", "keep_versions": nullInt32Ptr(keepVersions), "keep_days": nullInt32Ptr(keepDays)})})
+	return nil
+}
+
+// shreddableVersions maps version numbers to ids for the unshredded versions
+// that may be shredded: not the latest published one, not labelled and not
+// awaiting review. versions must be ordered newest first.
+func shreddableVersions(ctx context.Context, q *database.Queries, environmentID uuid.UUID, versions []database.ListUnshreddedEnvVersionsRow) (map[int32]uuid.UUID, error) {
+	labels, err := q.ListEnvLabels(ctx, environmentID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+	labelled := make(map[int32]bool, len(labels))
+	for _, label := range labels {
+		labelled[label.Version] = true
+	}
+
+	shreddable := make(map[int32]uuid.UUID)
+	latestSeen := false
+	for _, version := range versions {
+		switch {
+		case version.Status == config.EnvVersionPending:
+			continue
+		case version.Status == config.EnvVersionPublished && !latestSeen:
+			latestSeen = true
+			continue
+		case labelled[version.Version]:
+			continue
+		}
+		shreddable[version.Version] = version.ID
+	}
+	return shreddable, nil
+}
+
+// sharedDEKVersions lists the chosen versions whose wrapped DEK is also held
+// by an unshredded version that is not chosen. Partial pushes copy the DEK of
+// their parent, so such versions can only be shredded together.
+func sharedDEKVersions(versions []database.ListUnshreddedEnvVersionsRow, chosen map[int32]uuid.UUID) []int32 {
+	kept := make(map[string]bool)
+	for _, version := range versions {
+		if _, ok := chosen[version.Version]; !ok && len(version.WrappedDek) > 0 {
+			kept[string(version.WrappedDek)] = true
+		}
+	}
+
+	var blocked []int32
+	for _, version := range versions {
+		if _, ok := chosen[version.Version]; ok && kept[string(version.WrappedDek)] {
+			blocked = append(blocked, version.Version)
+		}
+	}
+	return blocked
+}
+
+// shredVersions shreds the versions pick chooses out of the environment's
+// unshredded ones. The choice is made under the environment's row lock, which
+// pushes and label moves also take, so a version cannot gain a label or a
+// partial push reusing its DEK between being chosen and being shredded.
+func (s *EnvServices) shredVersions(ctx context.Context, environmentID uuid.UUID, shreddedBy uuid.NullUUID, pick func(versions []database.ListUnshreddedEnvVersionsRow, shreddable map[int32]uuid.UUID) ([]int32, error)) ([]int32, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.InternalMessage("Unable to begin shred transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	environment, err := txQ.GetEnvironmentForUpdate(ctx, environmentID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Environment", "Check the environment name")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	versions, err := txQ.ListUnshreddedEnvVersions(ctx, database.ListUnshreddedEnvVersionsParams{
+		ProjectID: environment.ProjectID,
+		EnvName:   environment.Name,
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	shreddable, err := shreddableVersions(ctx, txQ, environment.ID, versions)
+	if err != nil {
+		return nil, err
+	}
+
+	chosen, err := pick(versions, shreddable)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, version := range chosen {
+		id := shreddable[version]
+		if err = txQ.DeleteEnvVersionEntries(ctx, id); err != nil {
+			return nil, errors.Internal(err)
+		}
+		if _, err = txQ.ShredEnvVersion(ctx, database.ShredEnvVersionParams{
+			ID:         id,
+			ShreddedBy: shreddedBy,
+		}); err != nil {
+			return nil, errors.Internal(err)
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.InternalMessage("Unable to commit shred transaction", err)
+	}
+	return chosen, nil
+}
+
+func nullInt32(v *int32) sql.NullInt32 {
+	if v == nil {
+		return sql.NullInt32{}
+	}
+	return sql.NullInt32{Int32: *v, Valid: true}
+}
+
+func nullInt32Ptr(v sql.NullInt32) *int32 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Int32
+}
+
+func nullTimePtr(v sql.NullTime) *time.Time {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Time
+}
@@ -0,0 +1,76 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+)
+
+func TestShredKeepsLatestAndLabelledVersions(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+
+	for range 3 {
+		if _, err := pushTestEnv(s, projectID, adminID, "staging", nil); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+	if _, err := s.Env.SetLabel(ctx, config.EnvLabelSetRequest{ProjectID: projectID, UserID: adminID, EnvName: "staging", Label: "stable", Version: 2}); err != nil {
+		t.Fatalf("set label: %v", err)
+	}
+
+	shred := func(version int32) error {
+		_, err := s.Env.Shred(ctx, config.ShredEnvRequest{ProjectID: projectID, AdminID: adminID, EnvName: "staging", Versions: []int32{version}})
+		return err
+	}
+
+	requireErrorCode(t, shred(3), errors.CodeConflict)
+	requireErrorCode(t, shred(2), errors.CodeConflict)
+	if err := shred(1); err != nil {
+		t.Fatalf("shred version 1: %v", err)
+	}
+
+	// a label cannot be moved onto the shredded version
+	_, err := s.Env.SetLabel(ctx, config.EnvLabelSetRequest{ProjectID: projectID, UserID: adminID, EnvName: "staging", Label: "stable", Version: 1})
+	requireErrorCode(t, err, errors.CodeGone)
+}
+
+func TestShredRefusesSharedDEK(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+
+	if _, err := s.Env.PushEntries(ctx, config.PushEnvEntriesRequest{
+		ProjectID:  projectID,
+		UserID:     adminID,
+		EnvName:    "staging",
+		Replace:    true,
+		WrappedDEK: []byte("wrapped-dek"),
+		DekNonce:   []byte("dek-nonce"),
+		Set:        []config.EnvEntryWrite{testEntryWrite("PORT", nil)},
+	}); err != nil {
+		t.Fatalf("push version 1: %v", err)
+	}
+	for range 2 {
+		if _, err := s.Env.PushEntries(ctx, config.PushEnvEntriesRequest{
+			ProjectID: projectID,
+			UserID:    adminID,
+			EnvName:   "staging",
+			Set:       []config.EnvEntryWrite{testEntryWrite("PORT", nil)},
+		}); err != nil {
+			t.Fatalf("partial push: %v", err)
+		}
+	}
+
+	// version 3 is kept as the latest and holds the DEK of versions 1 and 2
+	_, err := s.Env.Shred(ctx, config.ShredEnvRequest{ProjectID: projectID, AdminID: adminID, EnvName: "staging", Versions: []int32{1, 2}})
+	requireErrorCode(t, err, errors.CodeConflict)
+}
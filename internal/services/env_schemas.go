@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"encoding/json"
+	"strconv"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// Schema returns the project's current environment schema, or an older
+// version of it.
+func (s *EnvironmentService) Schema(ctx context.Context, req config.EnvSchemaRequest) (*config.EnvSchema, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	row, err := s.q.GetEnvSchema(ctx, database.GetEnvSchemaParams{
+		ProjectID: req.ProjectID,
+		Version:   nullInt32(req.Version),
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			if req.Version != nil {
+				return nil, errors.NotFound("Schema version", "List the versions with /environments/schema/history")
+			}
+			return nil, errors.NotFound("Schema", "The project has no environment schema")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	return toEnvSchema(row)
+}
+
+// SetSchema stores a new version of the project's environment schema. The
+// schema must pass envschema's own checks; when ExpectedVersion is set the
+// change is refused unless it was based on the current version.
+func (s *EnvironmentService) SetSchema(ctx context.Context, req config.EnvSchemaSetRequest) (*config.EnvSchema, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	// keyed by position, names may be missing or repeated; a variable with
+	// several problems gets them all
+	if violations := req.Schema.Check(); len(violations) > 0 {
+		validationErrors := make(map[string]string, len(violations))
+		for _, violation := range violations {
+			key := "schema.variables[" + strconv.Itoa(violation.Index) + "]"
+			if message, ok := validationErrors[key]; ok {
+				validationErrors[key] = message + "; " + violation.Message
+			} else {
+				validationErrors[key] = violation.Message
+			}
+		}
+		return nil, errors.Validation(validationErrors)
+	}
+
+	var current int32
+	latest, err := s.q.GetEnvSchema(ctx, database.GetEnvSchemaParams{ProjectID: req.ProjectID})
+	switch {
+	case err == nil:
+		current = latest.Version
+	case !dberrors.IsNoRows(err):
+		return nil, errors.Internal(err)
+	}
+	if req.ExpectedVersion != nil && *req.ExpectedVersion != current {
+		return nil, errors.Conflict("Schema has changed since version "+strconv.Itoa(int(*req.ExpectedVersion)), "Fetch the current schema and apply your change again")
+	}
+
+	raw, err := json.Marshal(req.Schema)
+	if err != nil {
+		return nil, errors.InternalMessage("Failed to serialize schema", err)
+	}
+
+	row, err := s.q.AddEnvSchema(ctx, database.AddEnvSchemaParams{
+		ID:        uuid.New(),
+		ProjectID: req.ProjectID,
+		Version:   current + 1,
+		Schema:    raw,
+		CreatedBy: req.AdminID,
+	})
+	if err != nil {
+		if dberrors.IsUniqueViolation(err) {
+			return nil, errors.Conflict("Schema was changed concurrently", "Fetch the current schema and apply your change again")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvSchemaSet, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, TargetID: helpers.Ptr(strconv.Itoa(int(row.Version))), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"version": row.Version, "variables": len(req.Schema.Variables)})})
+
+	return toEnvSchema(row)
+}
+
+// SchemaHistory lists every version of the project's environment schema,
+// newest first.
+func (s *EnvironmentService) SchemaHistory(ctx context.Context, req config.EnvSchemaHistoryRequest) (*config.EnvSchemaHistoryResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	rows, err := s.q.ListEnvSchemas(ctx, req.ProjectID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.EnvSchemaHistoryResponse{
+		Schemas: make([]config.EnvSchema, 0, len(rows)),
+	}
+	for _, row := range rows {
+		schema, err := toEnvSchema(row)
+		if err != nil {
+			return nil, err
+		}
+		resp.Schemas = append(resp.Schemas, *schema)
+	}
+	return resp, nil
+}
+
+func toEnvSchema(row database.EnvSchema) (*config.EnvSchema, error) {
+	schema := &config.EnvSchema{
+		ProjectID: row.ProjectID,
+		Version:   row.Version,
+		CreatedBy: row.CreatedBy,
+		CreatedAt: row.CreatedAt,
+	}
+	if err := json.Unmarshal(row.Schema, &schema.Schema); err != nil {
+		return nil, errors.InternalMessage("Stored schema does not decode", err)
+	}
+	return schema, nil
+}
@@ -2,7 +2,8 @@ package services
 
 import (
 	"context"
-	"encoding/json"
+	"database/sql"
+	"strconv"
 
 	"github.com/google/uuid"
 	"github.com/vijayvenkatj/envcrypt/database"
@@ -13,8 +14,10 @@ import (
 )
 
 type EnvServices struct {
-	q     *database.Queries
-	audit *AuditService
+	q            *database.Queries
+	db           *sql.DB
+	audit        *AuditService
+	environments *EnvironmentService
 }
 
 func NewEnvService(q *database.Queries) *EnvServices {
@@ -47,34 +50,49 @@ func (s *EnvServices) GetEnv(ctx context.Context, requestBody config.GetEnvReque
 		return nil, errors.Internal(err)
 	}
 
-	var env database.EnvVersion
-	if requestBody.Version != nil {
-		env, err = s.q.GetEnv(ctx, database.GetEnvParams{
-			ProjectID: requestBody.ProjectId,
-			EnvName:   requestBody.EnvName,
-			Version:   *requestBody.Version,
-		})
+	environment, err := s.getEnvironment(ctx, requestBody.ProjectId, requestBody.EnvName)
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("env not found")})
+		return nil, err
+	}
+
+	references, err := s.q.ListEnvReferences(ctx, environment.ID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	// an unchanged conditional pull discloses nothing, so it is not audited.
+	// A known version only covers the environment's own layer, so inheriting
+	// and referencing environments always return everything.
+	if (requestBody.KnownVersion != nil || len(requestBody.KnownTags) > 0) && !environment.ParentID.Valid && len(references) == 0 {
+		current, err := s.currentEnvVersion(ctx, requestBody.ProjectId, requestBody.EnvName, requestBody.Version, requestBody.Label)
 		if err != nil {
-			if dberrors.IsNoRows(err) {
-				return nil, errors.NotFound("Environment", "Check the environment name and version")
-			}
-			return nil, errors.Internal(err)
+			return nil, err
 		}
-	} else {
-		env, err = s.q.GetLatestEnv(ctx, database.GetLatestEnvParams{
-			ProjectID: requestBody.ProjectId,
-			EnvName:   requestBody.EnvName,
-		})
-		if err != nil {
-			s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("env not found")})
-			if dberrors.IsNoRows(err) {
-				return nil, errors.NotFound("Environment", "Check the environment name")
-			}
-			return nil, errors.Internal(err)
+		if isKnownVersion(environment, current, requestBody.KnownVersion, requestBody.KnownTags) {
+			return &config.GetEnvResponse{EnvironmentID: environment.ID, Version: current, NotModified: true}, nil
 		}
 	}
 
-	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusSuccess})
+	env, err := s.resolveEnvVersion(ctx, requestBody.ProjectId, requestBody.EnvName, requestBody.Version, requestBody.Label)
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("env not found")})
+		return nil, err
+	}
+
+	entries, err := listEnvEntries(ctx, s.q, env)
+	if err != nil {
+		return nil, err
+	}
+
+	parents, err := s.parentChain(ctx, environment, env, requestBody.Version != nil || requestBody.Label != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusSuccess, Metadata: pullMetadata(env.Version, requestBody.Label, nil)})
+
+	resolved, referenceKeys := s.resolveReferences(ctx, environment, references, user.ID)
 
 	return &config.GetEnvResponse{
 		CipherText:        env.Ciphertext,
@@ -82,6 +100,13 @@ func (s *EnvServices) GetEnv(ctx context.Context, requestBody config.GetEnvReque
 		WrappedDEK:        env.WrappedDek,
 		DekNonce:          env.DekNonce,
 		EncryptionVersion: env.EncryptionVersion,
+		Version:           env.Version,
+		EnvironmentID:     environment.ID,
+		Entries:           entries,
+		Parents:           parents,
+		References:        resolved,
+		ReferenceKeys:     referenceKeys,
+		Composite:         environment.ParentID.Valid || len(references) > 0,
 	}, nil
 }
 
@@ -119,21 +144,11 @@ func (s *EnvServices) GetEnvVersions(ctx context.Context, requestBody config.Get
 	var envResponses []config.EnvResponse
 
 	for _, envVersion := range envVersions {
-		var metadata config.Metadata
-		err = json.Unmarshal(envVersion.Metadata, &metadata)
+		envResponse, err := s.envResponse(ctx, envVersion)
 		if err != nil {
-			continue
+			return nil, err
 		}
-
-		envResponses = append(envResponses, config.EnvResponse{
-			CipherText:        envVersion.Ciphertext,
-			Nonce:             envVersion.Nonce,
-			WrappedDEK:        envVersion.WrappedDek,
-			DekNonce:          envVersion.DekNonce,
-			EncryptionVersion: envVersion.EncryptionVersion,
-			Version:           envVersion.Version,
-			Metadata:          metadata,
-		})
+		envResponses = append(envResponses, envResponse)
 	}
 
 	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusSuccess})
@@ -141,15 +156,15 @@ func (s *EnvServices) GetEnvVersions(ctx context.Context, requestBody config.Get
 	return &config.GetEnvVersionsResponse{EnvVersions: envResponses}, nil
 }
 
-func (s *EnvServices) AddEnv(ctx context.Context, requestBody config.AddEnvRequest) error {
+func (s *EnvServices) AddEnv(ctx context.Context, requestBody config.AddEnvRequest) (*config.AddEnvResponse, error) {
 
 	user, err := s.q.GetUserByID(ctx, requestBody.UserId)
 	if err != nil {
 		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: requestBody.UserId.String(), ActorEmail: "unknown", ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("user not found")})
 		if dberrors.IsNoRows(err) {
-			return errors.NotFound("User", "")
+			return nil, errors.NotFound("User", "")
 		}
-		return errors.Internal(err)
+		return nil, errors.Internal(err)
 	}
 
 	_, err = s.q.GetUserProjectRole(ctx, database.GetUserProjectRoleParams{
@@ -160,48 +175,61 @@ func (s *EnvServices) AddEnv(ctx context.Context, requestBody config.AddEnvReque
 	if err != nil {
 		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: requestBody.UserId.String(), ActorEmail: user.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("user doesn't have permission to store env")})
 		if dberrors.IsNoRows(err) {
-			return errors.Forbidden("You don't have permission to push to this environment", "")
+			return nil, errors.Forbidden("You don't have permission to push to this environment", "")
 		}
-		return errors.Internal(err)
+		return nil, errors.Internal(err)
 	}
 
-	metadata, err := json.Marshal(requestBody.Metadata)
+	metadata, err := encodeMetadata(requestBody.Metadata)
 	if err != nil {
-		return errors.InternalMessage("Failed to serialize env metadata", err)
+		return nil, err
 	}
 
-	_, err = s.q.AddEnv(ctx, database.AddEnvParams{
-		ID:                uuid.New(),
-		ProjectID:         requestBody.ProjectId,
-		EnvName:           requestBody.EnvName,
-		Ciphertext:        requestBody.CipherText,
-		Nonce:             requestBody.Nonce,
-		WrappedDek:        requestBody.WrappedDEK,
-		DekNonce:          requestBody.DekNonce,
-		EncryptionVersion: requestBody.EncryptionVersion,
-		CreatedBy:         requestBody.UserId,
-		Metadata:          metadata,
-	})
+	environment, err := s.environments.ResolveForWrite(ctx, requestBody.ProjectId, requestBody.EnvName, requestBody.UserId)
 	if err != nil {
 		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: requestBody.UserId.String(), ActorEmail: user.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
-		if dberrors.IsUniqueViolation(err) {
-			return errors.Conflict("Environment with this version already exists", "")
-		}
-		return errors.Internal(err)
+		return nil, err
+	}
+	if err = requireExpectedEnvironment(environment, requestBody.ExpectedEnvironmentID); err != nil {
+		return nil, err
+	}
+	status, candidateExpiresAt := pushStatus(environment)
+
+	env, err := s.insertEnvVersion(ctx, environment, requestBody.ExpectedVersion, database.AddEnvParams{
+		ID:                 uuid.New(),
+		ProjectID:          requestBody.ProjectId,
+		EnvName:            requestBody.EnvName,
+		Ciphertext:         requestBody.CipherText,
+		Nonce:              requestBody.Nonce,
+		WrappedDek:         requestBody.WrappedDEK,
+		DekNonce:           requestBody.DekNonce,
+		EncryptionVersion:  requestBody.EncryptionVersion,
+		CreatedBy:          requestBody.UserId,
+		Metadata:           metadata,
+		Status:             status,
+		CandidateExpiresAt: candidateExpiresAt,
+	}, nil)
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: requestBody.UserId.String(), ActorEmail: user.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
+		return nil, err
 	}
 
-	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: requestBody.UserId.String(), ActorEmail: user.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusSuccess, Metadata: metadata})
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: requestBody.UserId.String(), ActorEmail: user.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusSuccess, Metadata: pushAuditMetadata(env, metadata)})
 
-	return nil
+	return &config.AddEnvResponse{
+		Message: pushMessage(env.Status, "env added successfully"),
+		Version: env.Version,
+		Status:  env.Status,
+	}, nil
 }
 
-func (s *EnvServices) UpdateEnv(ctx context.Context, requestBody config.UpdateEnvRequest) error {
+func (s *EnvServices) UpdateEnv(ctx context.Context, requestBody config.UpdateEnvRequest) (*config.UpdateEnvResponse, error) {
 	user, err := s.q.GetUserByEmail(ctx, requestBody.Email)
 	if err != nil {
 		if dberrors.IsNoRows(err) {
-			return errors.NotFound("User", "Check the email address")
+			return nil, errors.NotFound("User", "Check the email address")
 		}
-		return errors.Internal(err)
+		return nil, errors.Internal(err)
 	}
 
 	_, err = s.q.GetUserProjectRole(ctx, database.GetUserProjectRoleParams{
@@ -212,52 +240,115 @@ func (s *EnvServices) UpdateEnv(ctx context.Context, requestBody config.UpdateEn
 	if err != nil {
 		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("permission denied")})
 		if dberrors.IsNoRows(err) {
-			return errors.Forbidden("You don't have permission to update this environment", "")
+			return nil, errors.Forbidden("You don't have permission to update this environment", "")
 		}
-		return errors.Internal(err)
+		return nil, errors.Internal(err)
 	}
 
-	metadata, err := json.Marshal(requestBody.Metadata)
+	metadata, err := encodeMetadata(requestBody.Metadata)
 	if err != nil {
-		return errors.InternalMessage("Failed to serialize env metadata", err)
+		return nil, err
 	}
 
-	_, err = s.q.AddEnv(ctx, database.AddEnvParams{
-		ID:                uuid.New(),
-		ProjectID:         requestBody.ProjectId,
-		EnvName:           requestBody.EnvName,
-		Ciphertext:        requestBody.CipherText,
-		Nonce:             requestBody.Nonce,
-		WrappedDek:        requestBody.WrappedDEK,
-		DekNonce:          requestBody.DekNonce,
-		EncryptionVersion: requestBody.EncryptionVersion,
-		CreatedBy:         user.ID,
-		Metadata:          metadata,
-	})
+	environment, err := s.environments.ResolveForWrite(ctx, requestBody.ProjectId, requestBody.EnvName, user.ID)
 	if err != nil {
 		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
-		return errors.Internal(err)
+		return nil, err
+	}
+	if err = requireExpectedEnvironment(environment, requestBody.ExpectedEnvironmentID); err != nil {
+		return nil, err
+	}
+	status, candidateExpiresAt := pushStatus(environment)
+
+	env, err := s.insertEnvVersion(ctx, environment, requestBody.ExpectedVersion, database.AddEnvParams{
+		ID:                 uuid.New(),
+		ProjectID:          requestBody.ProjectId,
+		EnvName:            requestBody.EnvName,
+		Ciphertext:         requestBody.CipherText,
+		Nonce:              requestBody.Nonce,
+		WrappedDek:         requestBody.WrappedDEK,
+		DekNonce:           requestBody.DekNonce,
+		EncryptionVersion:  requestBody.EncryptionVersion,
+		CreatedBy:          user.ID,
+		Metadata:           metadata,
+		Status:             status,
+		CandidateExpiresAt: candidateExpiresAt,
+	}, nil)
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
+		return nil, err
 	}
 
-	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusSuccess, Metadata: metadata})
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPush, ActorType: config.ActorTypeUser, ActorID: user.ID.String(), ActorEmail: requestBody.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusSuccess, Metadata: pushAuditMetadata(env, metadata)})
 
-	return nil
+	return &config.UpdateEnvResponse{
+		Message: pushMessage(env.Status, "env updated successfully"),
+		Version: env.Version,
+		Status:  env.Status,
+	}, nil
 }
 
 func (s *EnvServices) GetEnvForCI(ctx context.Context, requestBody config.GetEnvForCIRequest) (*config.GetEnvForCIResponse, error) {
-	env, err := s.q.GetLatestEnv(ctx, database.GetLatestEnvParams{
-		ProjectID: requestBody.ProjectId,
-		EnvName:   requestBody.EnvName,
-	})
+	session, err := s.ciSession(ctx, requestBody.SessionID, requestBody.ProjectId, requestBody.EnvName)
 	if err != nil {
-		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeService, ActorID: "ci_session", ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("env not found")})
-		if dberrors.IsNoRows(err) {
-			return nil, errors.NotFound("Environment", "Check the environment name")
+		return nil, err
+	}
+	serviceRoleID, pulledBy := session.ServiceRoleID.UUID, session.GithubRepo.String
+
+	label := requestBody.Label
+	pinned, err := s.pinnedLabel(ctx, serviceRoleID, requestBody.ProjectId, requestBody.EnvName)
+	if err != nil {
+		return nil, err
+	}
+	if pinned != nil {
+		if label != nil && *label != *pinned {
+			s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeService, ActorID: serviceRoleID.String(), ActorEmail: pulledBy, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("label does not match pin")})
+			return nil, errors.Forbidden("Service role is pinned to label "+*pinned, "Omit the label or request "+*pinned)
 		}
+		label = pinned
+	}
+
+	environment, err := s.getEnvironment(ctx, requestBody.ProjectId, requestBody.EnvName)
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeService, ActorID: serviceRoleID.String(), ActorEmail: pulledBy, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("env not found")})
+		return nil, err
+	}
+
+	references, err := s.q.ListEnvReferences(ctx, environment.ID)
+	if err != nil {
 		return nil, errors.Internal(err)
 	}
 
-	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeService, ActorID: "ci_session", ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusSuccess})
+	if (requestBody.KnownVersion != nil || len(requestBody.KnownTags) > 0) && !environment.ParentID.Valid && len(references) == 0 {
+		current, err := s.currentEnvVersion(ctx, requestBody.ProjectId, requestBody.EnvName, nil, label)
+		if err != nil {
+			return nil, err
+		}
+		if isKnownVersion(environment, current, requestBody.KnownVersion, requestBody.KnownTags) {
+			s.recordConsumer(ctx, environment, requestBody.Consumer, current, label, nil, serviceRoleID)
+			return &config.GetEnvForCIResponse{EnvironmentID: environment.ID, Version: current, NotModified: true}, nil
+		}
+	}
+
+	env, err := s.resolveEnvVersion(ctx, requestBody.ProjectId, requestBody.EnvName, nil, label)
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeService, ActorID: serviceRoleID.String(), ActorEmail: pulledBy, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusFailure, ErrMsg: helpers.Ptr("env not found")})
+		return nil, err
+	}
+
+	entries, err := listEnvEntries(ctx, s.q, env)
+	if err != nil {
+		return nil, err
+	}
+
+	parents, err := s.parentChain(ctx, environment, env, label != nil)
+	if err != nil {
+		return nil, err
+	}
+
+	s.recordConsumer(ctx, environment, requestBody.Consumer, env.Version, label, parents, serviceRoleID)
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeService, ActorID: serviceRoleID.String(), ActorEmail: pulledBy, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, Status: config.StatusSuccess, Metadata: pullMetadata(env.Version, label, requestBody.Consumer)})
 
 	return &config.GetEnvForCIResponse{
 		CipherText:        env.Ciphertext,
@@ -265,5 +356,175 @@ func (s *EnvServices) GetEnvForCI(ctx context.Context, requestBody config.GetEnv
 		WrappedDEK:        env.WrappedDek,
 		DekNonce:          env.DekNonce,
 		EncryptionVersion: env.EncryptionVersion,
+		Version:           env.Version,
+		EnvironmentID:     environment.ID,
+		Entries:           entries,
+		Parents:           parents,
+		References:        unresolvedReferences(references),
+		Composite:         environment.ParentID.Valid || len(references) > 0,
 	}, nil
 }
+
+// ciSession loads the CI session a pull is made with. Like the project keys,
+// a session only reads the project and environment it was delegated.
+func (s *EnvServices) ciSession(ctx context.Context, sessionID, projectID uuid.UUID, envName string) (database.Session, error) {
+	session, err := s.q.GetSession(ctx, sessionID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.Session{}, errors.Unauthorized("SESSION_EXPIRED", "Session "+sessionID.String()+" is invalid or expired", "Please log in again")
+		}
+		return database.Session{}, errors.Internal(err)
+	}
+
+	if session.IdentityType != "ci" || !session.ServiceRoleID.Valid || !session.ProjectID.Valid || !session.Env.Valid {
+		return database.Session{}, errors.Unauthorized("SESSION_INVALID", "Session "+sessionID.String()+" is not a CI session", "Log in through OIDC")
+	}
+	if session.ProjectID.UUID != projectID || session.Env.String != envName {
+		return database.Session{}, errors.Forbidden("Project ID and environment do not match session", "")
+	}
+
+	return session, nil
+}
+
+// insertEnvVersion allocates the next version number by bumping the
+// environment's head under its row lock, so concurrent pushes serialize instead
+// of racing on MAX(version)+1. When expectedVersion is set, the push is refused
+// unless it was based on the currently published version. afterInsert, when
+// set, runs inside the same transaction once the version row exists and is
+// given the published version it was based on.
+func (s *EnvServices) insertEnvVersion(ctx context.Context, environment database.Environment, expectedVersion *int32, params database.AddEnvParams, afterInsert func(txQ *database.Queries, env database.EnvVersion, parent *database.EnvVersion) error) (database.EnvVersion, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.EnvVersion{}, errors.InternalMessage("Unable to begin push transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	version, latest, err := allocateEnvVersion(ctx, txQ, environment, expectedVersion)
+	if err != nil {
+		return database.EnvVersion{}, err
+	}
+
+	params.Version = version
+	if latest != nil {
+		params.ParentVersion = sql.NullInt32{Int32: latest.Version, Valid: true}
+	}
+	// a per-key push without a DEK patches the latest version under its DEK
+	if params.EncryptionVersion == config.EncryptionVersionEntries && params.WrappedDek == nil {
+		if latest == nil || latest.EncryptionVersion != config.EncryptionVersionEntries {
+			return database.EnvVersion{}, errors.BadRequest("Environment has no per-key version to update", "Push all entries with replace and a new DEK first")
+		}
+		params.WrappedDek, params.DekNonce = latest.WrappedDek, latest.DekNonce
+	}
+	env, err := txQ.AddEnv(ctx, params)
+	if err != nil {
+		if dberrors.IsUniqueViolation(err) {
+			return database.EnvVersion{}, staleVersionError(params.ParentVersion.Int32)
+		}
+		return database.EnvVersion{}, errors.Internal(err)
+	}
+
+	if err = indexVersionMetadata(ctx, txQ, env.ID, env.Metadata); err != nil {
+		return database.EnvVersion{}, err
+	}
+
+	if err = recordVersionParents(ctx, txQ, environment, env); err != nil {
+		return database.EnvVersion{}, err
+	}
+
+	if err = recordVersionEvent(ctx, txQ, env); err != nil {
+		return database.EnvVersion{}, err
+	}
+
+	if afterInsert != nil {
+		if err = afterInsert(txQ, env, latest); err != nil {
+			return database.EnvVersion{}, err
+		}
+	}
+
+	if err = tx.Commit(); err != nil {
+		return database.EnvVersion{}, errors.InternalMessage("Unable to commit push transaction", err)
+	}
+
+	return env, nil
+}
+
+// allocateEnvVersion hands out the next version number and returns the latest
+// published version the push is based on, nil for the first one.
+func allocateEnvVersion(ctx context.Context, txQ *database.Queries, environment database.Environment, expectedVersion *int32) (int32, *database.EnvVersion, error) {
+	version, err := txQ.AllocateEnvVersion(ctx, environment.ID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return 0, nil, lockedEnvironmentError(ctx, txQ, environment.ID)
+		}
+		return 0, nil, errors.Internal(err)
+	}
+
+	var (
+		current int32
+		parent  *database.EnvVersion
+	)
+	latest, err := txQ.GetLatestEnv(ctx, database.GetLatestEnvParams{
+		ProjectID: environment.ProjectID,
+		EnvName:   environment.Name,
+	})
+	switch {
+	case err == nil:
+		current = latest.Version
+		parent = &latest
+	case !dberrors.IsNoRows(err):
+		return 0, nil, errors.Internal(err)
+	}
+
+	if expectedVersion != nil && *expectedVersion != current {
+		return 0, nil, staleVersionError(current)
+	}
+
+	return version, parent, nil
+}
+
+// lockedEnvironmentError explains why no version could be allocated: the
+// environment was locked, or deleted, after the push checked it.
+func lockedEnvironmentError(ctx context.Context, txQ *database.Queries, environmentID uuid.UUID) error {
+	environment, err := txQ.GetEnvironmentByID(ctx, environmentID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return errors.NotFound("Environment", "Check the environment name")
+		}
+		return errors.Internal(err)
+	}
+	if err = requireUnlocked(ctx, txQ, environment); err != nil {
+		return err
+	}
+	return errors.Locked("Environment "+environment.Name+" is locked", "Ask a project admin to unlock it with /environments/unlock")
+}
+
+// isKnownVersion reports whether the client already holds version current of
+// environment, by known_version or by one of its If-None-Match tags.
+func isKnownVersion(environment database.Environment, current int32, knownVersion *int32, knownTags []config.EnvTag) bool {
+	if knownVersion != nil && *knownVersion == current {
+		return true
+	}
+	for _, tag := range knownTags {
+		if tag.EnvironmentID == environment.ID && tag.Version == current {
+			return true
+		}
+	}
+	return false
+}
+
+// requireExpectedEnvironment refuses a push based on a pull of an environment
+// that has since been deleted and created again under the same name.
+func requireExpectedEnvironment(environment database.Environment, expected *uuid.UUID) error {
+	if expected != nil && *expected != environment.ID {
+		return errors.Conflict("Environment was replaced since your last pull", "Pull the latest version, merge your changes and push again")
+	}
+	return nil
+}
+
+func staleVersionError(current int32) error {
+	conflict := errors.Conflict("Environment has changed since your last pull", "Pull the latest version, merge your changes and push again")
+	conflict.Fields = map[string]string{"current_version": strconv.Itoa(int(current))}
+	return conflict
+}
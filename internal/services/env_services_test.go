@@ -0,0 +1,99 @@
+package services
+
+import (
+	"slices"
+	"sync"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+func TestAddEnvConcurrentPushesGetDistinctVersions(t *testing.T) {
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+
+	const pushes = 8
+	var wg sync.WaitGroup
+	versions := make([]int32, pushes)
+	errs := make([]error, pushes)
+	for i := range pushes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := pushTestEnv(s, projectID, adminID, "staging", nil)
+			if err == nil {
+				versions[i] = resp.Version
+			}
+			errs[i] = err
+		}()
+	}
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			t.Fatalf("push %d: %v", i, err)
+		}
+	}
+	slices.Sort(versions)
+	for i, version := range versions {
+		if version != int32(i+1) {
+			t.Fatalf("versions = %v, want 1..%d", versions, pushes)
+		}
+	}
+}
+
+func TestAddEnvRejectsStaleParent(t *testing.T) {
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+
+	if _, err := pushTestEnv(s, projectID, adminID, "staging", nil); err != nil {
+		t.Fatalf("push version 1: %v", err)
+	}
+	if _, err := pushTestEnv(s, projectID, adminID, "staging", helpers.Ptr(int32(1))); err != nil {
+		t.Fatalf("push version 2: %v", err)
+	}
+
+	_, err := pushTestEnv(s, projectID, adminID, "staging", helpers.Ptr(int32(1)))
+	requireErrorCode(t, err, errors.CodeConflict)
+}
+
+func TestAddEnvConcurrentPushesOnSameParent(t *testing.T) {
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+
+	if _, err := pushTestEnv(s, projectID, adminID, "staging", nil); err != nil {
+		t.Fatalf("push version 1: %v", err)
+	}
+
+	const pushes = 4
+	var wg sync.WaitGroup
+	errs := make([]error, pushes)
+	for i := range pushes {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			_, errs[i] = pushTestEnv(s, projectID, adminID, "staging", helpers.Ptr(int32(1)))
+		}()
+	}
+	wg.Wait()
+
+	published := 0
+	for _, err := range errs {
+		if err == nil {
+			published++
+			continue
+		}
+		requireErrorCode(t, err, errors.CodeConflict)
+	}
+	if published != 1 {
+		t.Fatalf("%d pushes on version 1 succeeded, want exactly 1", published)
+	}
+}
@@ -0,0 +1,150 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"strconv"
+
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+// ListVersions pages through an environment's history without ciphertext,
+// newest first. The cursor is opaque to clients.
+func (s *EnvServices) ListVersions(ctx context.Context, req config.ListEnvVersionsRequest) (*config.ListEnvVersionsResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 50
+	} else if limit > 200 {
+		limit = 200
+	}
+
+	beforeVersion, err := decodeVersionCursor(req.Cursor)
+	if err != nil {
+		return nil, err
+	}
+
+	params := database.ListEnvVersionSummariesParams{
+		ProjectID:     req.ProjectID,
+		EnvName:       req.EnvName,
+		BeforeVersion: beforeVersion,
+		LimitVal:      limit + 1,
+	}
+	if req.From != nil {
+		params.FromTime = sql.NullTime{Time: req.From.UTC(), Valid: true}
+	}
+	if req.To != nil {
+		params.ToTime = sql.NullTime{Time: req.To.UTC(), Valid: true}
+	}
+
+	rows, err := s.q.ListEnvVersionSummaries(ctx, params)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.ListEnvVersionsResponse{
+		Versions: make([]config.EnvVersionSummary, 0, len(rows)),
+	}
+	if len(rows) > int(limit) {
+		rows = rows[:limit]
+		resp.NextCursor = encodeVersionCursor(rows[len(rows)-1].Version)
+	}
+	for _, row := range rows {
+		resp.Versions = append(resp.Versions, config.EnvVersionSummary{
+			EnvName:           row.EnvName,
+			Version:           row.Version,
+			Metadata:          decodeMetadata(row.Metadata, row.ParentVersion),
+			EncryptionVersion: row.EncryptionVersion,
+			Size:              row.Size,
+			CreatedBy:         row.CreatedBy,
+			CreatedByEmail:    row.CreatedByEmail,
+			CreatedAt:         row.CreatedAt,
+			ShreddedAt:        nullTimePtr(row.ShreddedAt),
+		})
+	}
+
+	return resp, nil
+}
+
+// FetchVersions returns the ciphertext of specific published versions.
+func (s *EnvServices) FetchVersions(ctx context.Context, req config.FetchEnvVersionsRequest) (*config.FetchEnvVersionsResponse, error) {
+	entry := AuditEntry{Action: config.ActionEnvPull, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID, Environment: &req.EnvName}
+
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	resp := &config.FetchEnvVersionsResponse{
+		EnvVersions: make([]config.EnvResponse, 0, len(req.Versions)),
+	}
+	for _, version := range req.Versions {
+		env, err := s.resolveEnvVersion(ctx, req.ProjectID, req.EnvName, &version, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		envResponse, err := s.envResponse(ctx, env)
+		if err != nil {
+			return nil, err
+		}
+		resp.EnvVersions = append(resp.EnvVersions, envResponse)
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"versions": req.Versions})
+	s.audit.Log(ctx, entry)
+
+	return resp, nil
+}
+
+// envResponse builds the full response for a version, entries included.
+func (s *EnvServices) envResponse(ctx context.Context, env database.EnvVersion) (config.EnvResponse, error) {
+	entries, err := listEnvEntries(ctx, s.q, env)
+	if err != nil {
+		return config.EnvResponse{}, err
+	}
+
+	return config.EnvResponse{
+		CipherText:        env.Ciphertext,
+		Nonce:             env.Nonce,
+		WrappedDEK:        env.WrappedDek,
+		DekNonce:          env.DekNonce,
+		EncryptionVersion: env.EncryptionVersion,
+		Version:           env.Version,
+		Metadata:          decodeMetadata(env.Metadata, env.ParentVersion),
+		CreatedBy:         env.CreatedBy,
+		CreatedAt:         env.CreatedAt,
+		ShreddedAt:        nullTimePtr(env.ShreddedAt),
+		Entries:           entries,
+	}, nil
+}
+
+func encodeVersionCursor(version int32) string {
+	return base64.RawURLEncoding.EncodeToString([]byte("v" + strconv.Itoa(int(version))))
+}
+
+func decodeVersionCursor(cursor string) (sql.NullInt32, error) {
+	if cursor == "" {
+		return sql.NullInt32{}, nil
+	}
+
+	raw, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil || len(raw) < 2 || raw[0] != 'v' {
+		return sql.NullInt32{}, errors.BadRequest("Invalid cursor", "Pass next_cursor from the previous page unchanged")
+	}
+	version, err := strconv.ParseInt(string(raw[1:]), 10, 32)
+	if err != nil {
+		return sql.NullInt32{}, errors.BadRequest("Invalid cursor", "Pass next_cursor from the previous page unchanged")
+	}
+
+	return sql.NullInt32{Int32: int32(version), Valid: true}, nil
+}
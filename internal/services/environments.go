@@ -0,0 +1,657 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+type EnvironmentService struct {
+	q     *database.Queries
+	db    *sql.DB
+	audit *AuditService
+}
+
+func NewEnvironmentService(q *database.Queries, db *sql.DB) *EnvironmentService {
+	return &EnvironmentService{q: q, db: db}
+}
+
+func (s *EnvironmentService) List(ctx context.Context, req config.EnvironmentListRequest) (*config.EnvironmentListResponse, error) {
+	if _, err := requireProjectMember(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	project, err := s.q.GetProjectById(ctx, req.ProjectID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Project", "Check the project ID")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	environments, err := s.q.ListEnvironments(ctx, req.ProjectID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	overdueNames, err := s.q.ListOverdueEnvironmentNames(ctx, database.ListOverdueEnvironmentNamesParams{
+		ProjectID: req.ProjectID,
+		Now:       time.Now().UTC(),
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.EnvironmentListResponse{
+		Environments: make([]config.Environment, 0, len(environments)),
+		AutoCreate:   project.AutoCreateEnvironments,
+		Retention:    retentionPolicy(project.RetentionKeepVersions, project.RetentionKeepDays),
+	}
+	for _, environment := range environments {
+		if environment.ArchivedAt.Valid && !req.IncludeArchived {
+			continue
+		}
+		env := toEnvironment(environment)
+		env.Overdue = slices.Contains(overdueNames, environment.Name)
+		resp.Environments = append(resp.Environments, env)
+	}
+
+	return resp, nil
+}
+
+func (s *EnvironmentService) Create(ctx context.Context, req config.EnvironmentCreateRequest) (*config.Environment, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	if err := validateEnvironmentName("name", req.Name); err != nil {
+		return nil, err
+	}
+
+	environment, err := s.q.CreateEnvironment(ctx, database.CreateEnvironmentParams{
+		ID:          uuid.New(),
+		ProjectID:   req.ProjectID,
+		Name:        req.Name,
+		Description: req.Description,
+		Protected:   req.Protected,
+		CreatedBy:   req.AdminID,
+	})
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentCreate, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
+		if dberrors.IsUniqueViolation(err) {
+			return nil, errors.Conflict("An environment with this name already exists", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentCreate, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, TargetID: helpers.Ptr(environment.ID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"protected": req.Protected})})
+
+	resp := toEnvironment(environment)
+	return &resp, nil
+}
+
+// Rename moves an environment and every version, delegation, access request,
+// grant and event recorded under its old name. Audit entries keep the name
+// they were written under and are matched to the environment by id when read.
+func (s *EnvironmentService) Rename(ctx context.Context, req config.EnvironmentRenameRequest) (*config.Environment, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	if err := validateEnvironmentName("new_name", req.NewName); err != nil {
+		return nil, err
+	}
+
+	current, err := s.get(ctx, req.ProjectID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	if err = requireUnlocked(ctx, s.q, current); err != nil {
+		return nil, err
+	}
+
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return nil, errors.InternalMessage("Unable to begin rename transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	environment, err := txQ.RenameEnvironment(ctx, database.RenameEnvironmentParams{
+		NewName:   req.NewName,
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Environment", "Check the environment name")
+		}
+		if dberrors.IsUniqueViolation(err) {
+			return nil, errors.Conflict("An environment with this name already exists", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	if err = txQ.RenameEnvVersions(ctx, database.RenameEnvVersionsParams{
+		NewName:   req.NewName,
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+	}); err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	if err = txQ.RenameDelegationEnv(ctx, database.RenameDelegationEnvParams{
+		NewName:   req.NewName,
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+	}); err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	if err = txQ.RenameAccessRequestEnv(ctx, database.RenameAccessRequestEnvParams{
+		NewName:   req.NewName,
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+	}); err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	if err = txQ.RenameBreakGlassEnv(ctx, database.RenameBreakGlassEnvParams{
+		NewName:   req.NewName,
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+	}); err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	if err = txQ.RenameEventEnv(ctx, database.RenameEventEnvParams{
+		NewName:   sql.NullString{String: req.NewName, Valid: true},
+		ProjectID: req.ProjectID,
+		Name:      sql.NullString{String: req.Name, Valid: true},
+	}); err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	if err = recordEvent(ctx, txQ, eventRecord{
+		ProjectID: req.ProjectID,
+		EnvName:   &req.NewName,
+		Type:      config.EventEnvRenamed,
+		Data:      map[string]any{"environment_id": environment.ID, "from": req.Name, "to": req.NewName},
+	}); err != nil {
+		return nil, err
+	}
+
+	if err = tx.Commit(); err != nil {
+		return nil, errors.InternalMessage("Unable to commit rename transaction", err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentRename, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.NewName, TargetID: helpers.Ptr(environment.ID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"from": req.Name, "to": req.NewName})})
+
+	resp := toEnvironment(environment)
+	return &resp, nil
+}
+
+// Archive keeps an environment's history readable but refuses further pushes and delegations.
+func (s *EnvironmentService) Archive(ctx context.Context, req config.EnvironmentArchiveRequest) (*config.Environment, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	environment, err := s.q.ArchiveEnvironment(ctx, database.ArchiveEnvironmentParams{
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Environment", "Check the environment name, it may already be archived")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentArchive, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, TargetID: helpers.Ptr(environment.ID.String()), Status: config.StatusSuccess})
+
+	resp := toEnvironment(environment)
+	return &resp, nil
+}
+
+func (s *EnvironmentService) SetAutoCreate(ctx context.Context, req config.EnvironmentSettingsRequest) (*config.EnvironmentSettingsResponse, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	err := s.q.SetProjectAutoCreateEnvironments(ctx, database.SetProjectAutoCreateEnvironmentsParams{
+		ID:                     req.ProjectID,
+		AutoCreateEnvironments: req.AutoCreate,
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentSettings, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"auto_create": req.AutoCreate})})
+
+	return &config.EnvironmentSettingsResponse{
+		Message:    "Environment settings updated",
+		AutoCreate: req.AutoCreate,
+	}, nil
+}
+
+// Restore brings an archived environment back into listings and accepts writes again.
+func (s *EnvironmentService) Restore(ctx context.Context, req config.EnvironmentRestoreRequest) (*config.Environment, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	environment, err := s.q.RestoreEnvironment(ctx, database.RestoreEnvironmentParams{
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Environment", "Check the environment name, it may not be archived")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentRestore, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, TargetID: helpers.Ptr(environment.ID.String()), Status: config.StatusSuccess})
+
+	resp := toEnvironment(environment)
+	return &resp, nil
+}
+
+// Delete permanently removes an environment with its versions, labels,
+// delegations, access requests, grants and events, freeing the name. Audit
+// entries are kept.
+func (s *EnvironmentService) Delete(ctx context.Context, req config.EnvironmentDeleteRequest) (*config.EnvironmentDeleteResponse, error) {
+	entry := AuditEntry{Action: config.ActionEnvironmentDelete, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, Severity: config.SeverityCritical}
+
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	if req.Confirm != req.Name {
+		return nil, errors.Validation(map[string]string{"confirm": "must repeat the environment name"})
+	}
+
+	environment, err := s.get(ctx, req.ProjectID, req.Name)
+	if err != nil {
+		return nil, err
+	}
+	entry.TargetID = helpers.Ptr(environment.ID.String())
+
+	if environment, err = s.delete(ctx, environment); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"head_version": environment.HeadVersion})
+	s.audit.Log(ctx, entry)
+
+	return &config.EnvironmentDeleteResponse{
+		Message: "Environment deleted",
+		Name:    req.Name,
+	}, nil
+}
+
+// delete removes the environment under its row lock, which pushes and
+// inheritance changes also take, so a lock or a new child or reference cannot
+// slip in between the checks and the delete. It returns the row as deleted.
+func (s *EnvironmentService) delete(ctx context.Context, environment database.Environment) (database.Environment, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Environment{}, errors.InternalMessage("Unable to begin delete transaction", err)
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	if environment, err = txQ.GetEnvironmentForUpdate(ctx, environment.ID); err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.Environment{}, errors.NotFound("Environment", "Check the environment name")
+		}
+		return database.Environment{}, errors.Internal(err)
+	}
+	if err = requireUnlocked(ctx, txQ, environment); err != nil {
+		return database.Environment{}, err
+	}
+
+	children, err := txQ.ListChildEnvironments(ctx, uuid.NullUUID{UUID: environment.ID, Valid: true})
+	if err != nil {
+		return database.Environment{}, errors.Internal(err)
+	}
+	if len(children) > 0 {
+		return database.Environment{}, errors.Conflict("Environment "+environment.Name+" is inherited by "+children[0].Name, "Point the environments inheriting from it at another parent first")
+	}
+
+	referrers, err := txQ.ListEnvReferencesTo(ctx, environment.ID)
+	if err != nil {
+		return database.Environment{}, errors.Internal(err)
+	}
+	if len(referrers) > 0 {
+		// referrers may sit in projects the caller is not a member of
+		return database.Environment{}, errors.Conflict("Environment "+environment.Name+" is still referenced", "Remove the references to it first ("+strconv.Itoa(len(referrers))+" remaining)")
+	}
+
+	if err = txQ.DeleteEnvVersionsForEnv(ctx, database.DeleteEnvVersionsForEnvParams{
+		ProjectID: environment.ProjectID,
+		EnvName:   environment.Name,
+	}); err != nil {
+		return database.Environment{}, errors.Internal(err)
+	}
+
+	if err = txQ.DeleteDelegationsForEnv(ctx, database.DeleteDelegationsForEnvParams{
+		ProjectID: environment.ProjectID,
+		Env:       environment.Name,
+	}); err != nil {
+		return database.Environment{}, errors.Internal(err)
+	}
+
+	if err = txQ.DeleteAccessRequestsForEnv(ctx, database.DeleteAccessRequestsForEnvParams{
+		ProjectID: environment.ProjectID,
+		EnvName:   environment.Name,
+	}); err != nil {
+		return database.Environment{}, errors.Internal(err)
+	}
+
+	if err = txQ.DeleteBreakGlassGrantsForEnv(ctx, database.DeleteBreakGlassGrantsForEnvParams{
+		ProjectID: environment.ProjectID,
+		EnvName:   environment.Name,
+	}); err != nil {
+		return database.Environment{}, errors.Internal(err)
+	}
+
+	if err = txQ.DeleteEventsForEnv(ctx, database.DeleteEventsForEnvParams{
+		ProjectID: environment.ProjectID,
+		EnvName:   sql.NullString{String: environment.Name, Valid: true},
+	}); err != nil {
+		return database.Environment{}, errors.Internal(err)
+	}
+
+	// labels go with the environment row
+	if _, err = txQ.DeleteEnvironment(ctx, environment.ID); err != nil {
+		return database.Environment{}, errors.Internal(err)
+	}
+
+	if err = tx.Commit(); err != nil {
+		return database.Environment{}, errors.InternalMessage("Unable to commit delete transaction", err)
+	}
+	return environment, nil
+}
+
+// Lock freezes every write to an environment, optionally until a given time.
+// Locking a locked environment replaces its reason and expiry.
+func (s *EnvironmentService) Lock(ctx context.Context, req config.EnvironmentLockRequest) (*config.Environment, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	params := database.LockEnvironmentParams{
+		ProjectID:  req.ProjectID,
+		Name:       req.Name,
+		LockedBy:   uuid.NullUUID{UUID: req.AdminID, Valid: true},
+		LockReason: sql.NullString{String: req.Reason, Valid: true},
+	}
+	if req.Until != nil {
+		params.LockedUntil = sql.NullTime{Time: req.Until.UTC(), Valid: true}
+	}
+
+	environment, err := s.q.LockEnvironment(ctx, params)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Environment", "Check the environment name")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentLock, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, TargetID: helpers.Ptr(environment.ID.String()), Status: config.StatusSuccess, Severity: config.SeverityWarning, Metadata: mustJSON(map[string]any{"reason": req.Reason, "until": req.Until})})
+
+	resp := toEnvironment(environment)
+	return &resp, nil
+}
+
+func (s *EnvironmentService) Unlock(ctx context.Context, req config.EnvironmentUnlockRequest) (*config.Environment, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	environment, err := s.q.UnlockEnvironment(ctx, database.UnlockEnvironmentParams{
+		ProjectID: req.ProjectID,
+		Name:      req.Name,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Environment", "Check the environment name")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentUnlock, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: &req.Name, TargetID: helpers.Ptr(environment.ID.String()), Status: config.StatusSuccess})
+
+	resp := toEnvironment(environment)
+	return &resp, nil
+}
+
+// SetRetention sets the retention policy of one environment, or the project
+// default when no environment is named. Versions outside the policy are
+// shredded by the env-retention job.
+func (s *EnvironmentService) SetRetention(ctx context.Context, req config.EnvironmentRetentionRequest) (*config.EnvironmentRetentionResponse, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.AdminID); err != nil {
+		return nil, err
+	}
+
+	keepVersions, keepDays := nullInt32(req.KeepVersions), nullInt32(req.KeepDays)
+
+	if req.Name == nil {
+		err := s.q.SetProjectRetention(ctx, database.SetProjectRetentionParams{
+			ID:                    req.ProjectID,
+			RetentionKeepVersions: keepVersions,
+			RetentionKeepDays:     keepDays,
+		})
+		if err != nil {
+			return nil, errors.Internal(err)
+		}
+	} else {
+		_, err := s.q.SetEnvironmentRetention(ctx, database.SetEnvironmentRetentionParams{
+			ProjectID:             req.ProjectID,
+			Name:                  *req.Name,
+			RetentionKeepVersions: keepVersions,
+			RetentionKeepDays:     keepDays,
+		})
+		if err != nil {
+			if dberrors.IsNoRows(err) {
+				return nil, errors.NotFound("Environment", "Check the environment name")
+			}
+			return nil, errors.Internal(err)
+		}
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentSettings, ActorType: config.ActorTypeUser, ActorID: req.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, req.AdminID), ProjectID: &req.ProjectID, Environment: req.Name, Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"retention_keep_versions": req.KeepVersions, "retention_keep_days": req.KeepDays})})
+
+	return &config.EnvironmentRetentionResponse{
+		Message:   "Retention policy updated",
+		Name:      req.Name,
+		Retention: retentionPolicy(keepVersions, keepDays),
+	}, nil
+}
+
+// Resolve looks up the environment a push or delegation targets. Unknown names
+// are rejected unless the project has auto-create enabled, and archived
+// environments never accept writes.
+func (s *EnvironmentService) Resolve(ctx context.Context, projectID uuid.UUID, name string, actorID uuid.UUID) (database.Environment, error) {
+	environment, err := s.q.GetEnvironment(ctx, database.GetEnvironmentParams{
+		ProjectID: projectID,
+		Name:      name,
+	})
+	if err == nil {
+		if environment.ArchivedAt.Valid {
+			return database.Environment{}, errors.Conflict("Environment "+name+" is archived", "")
+		}
+		return environment, nil
+	}
+	if !dberrors.IsNoRows(err) {
+		return database.Environment{}, errors.Internal(err)
+	}
+
+	project, err := s.q.GetProjectById(ctx, projectID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.Environment{}, errors.NotFound("Project", "Check the project ID")
+		}
+		return database.Environment{}, errors.Internal(err)
+	}
+	if !project.AutoCreateEnvironments {
+		return database.Environment{}, errors.NotFound("Environment "+name, "Create it first with /environments/create")
+	}
+
+	if err = validateEnvironmentName("env_name", name); err != nil {
+		return database.Environment{}, err
+	}
+
+	environment, err = s.q.CreateEnvironment(ctx, database.CreateEnvironmentParams{
+		ID:        uuid.New(),
+		ProjectID: projectID,
+		Name:      name,
+		CreatedBy: actorID,
+	})
+	if err != nil {
+		if dberrors.IsUniqueViolation(err) {
+			// created concurrently by another push
+			return s.Resolve(ctx, projectID, name, actorID)
+		}
+		return database.Environment{}, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionEnvironmentCreate, ActorType: config.ActorTypeUser, ActorID: actorID.String(), ActorEmail: actorEmail(ctx, s.q, actorID), ProjectID: &projectID, Environment: &name, TargetID: helpers.Ptr(environment.ID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"auto_created": true})})
+
+	return environment, nil
+}
+
+func (s *EnvironmentService) get(ctx context.Context, projectID uuid.UUID, name string) (database.Environment, error) {
+	environment, err := s.q.GetEnvironment(ctx, database.GetEnvironmentParams{
+		ProjectID: projectID,
+		Name:      name,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.Environment{}, errors.NotFound("Environment", "Check the environment name")
+		}
+		return database.Environment{}, errors.Internal(err)
+	}
+	return environment, nil
+}
+
+// ResolveForWrite is Resolve for changes to an environment's contents, which
+// are also refused while the environment is locked.
+func (s *EnvironmentService) ResolveForWrite(ctx context.Context, projectID uuid.UUID, name string, actorID uuid.UUID) (database.Environment, error) {
+	environment, err := s.Resolve(ctx, projectID, name, actorID)
+	if err != nil {
+		return database.Environment{}, err
+	}
+	if err = requireUnlocked(ctx, s.q, environment); err != nil {
+		return database.Environment{}, err
+	}
+	return environment, nil
+}
+
+// requireUnlocked fails with a LOCKED error naming who locked the environment
+// and why.
+func requireUnlocked(ctx context.Context, q *database.Queries, environment database.Environment) error {
+	lock := activeLock(environment)
+	if lock == nil {
+		return nil
+	}
+
+	lockedBy := lock.LockedBy.String()
+	if user, err := q.GetUserByID(ctx, lock.LockedBy); err == nil {
+		lockedBy = user.Email
+	}
+
+	hint := "Ask a project admin to unlock it with /environments/unlock"
+	fields := map[string]string{
+		"locked_by": lock.LockedBy.String(),
+		"reason":    lock.Reason,
+		"locked_at": lock.LockedAt.Format(time.RFC3339),
+	}
+	if lock.Until != nil {
+		hint = "The lock lifts at " + lock.Until.Format(time.RFC3339) + ", or ask a project admin to unlock it"
+		fields["until"] = lock.Until.Format(time.RFC3339)
+	}
+
+	lockedErr := errors.Locked("Environment "+environment.Name+" is locked by "+lockedBy+": "+lock.Reason, hint)
+	lockedErr.Fields = fields
+	return lockedErr
+}
+
+// activeLock returns the environment's lock, or nil once it was lifted or expired.
+func activeLock(row database.Environment) *config.EnvironmentLock {
+	if !row.LockedAt.Valid {
+		return nil
+	}
+	if row.LockedUntil.Valid && !row.LockedUntil.Time.After(time.Now().UTC()) {
+		return nil
+	}
+
+	lock := &config.EnvironmentLock{
+		LockedBy: row.LockedBy.UUID,
+		Reason:   row.LockReason.String,
+		LockedAt: row.LockedAt.Time,
+	}
+	if row.LockedUntil.Valid {
+		lock.Until = &row.LockedUntil.Time
+	}
+	return lock
+}
+
+func validateEnvironmentName(field, name string) error {
+	if !environmentNamePattern.MatchString(name) {
+		return errors.Validation(map[string]string{field: "must be 1-63 lowercase letters, digits, '.', '_' or '-'"})
+	}
+	return nil
+}
+
+func toEnvironment(row database.Environment) config.Environment {
+	environment := config.Environment{
+		ID:          row.ID,
+		ProjectID:   row.ProjectID,
+		Name:        row.Name,
+		Description: row.Description,
+		Protected:   row.Protected,
+		CreatedBy:   row.CreatedBy,
+		CreatedAt:   row.CreatedAt,
+	}
+	if row.ArchivedAt.Valid {
+		environment.ArchivedAt = &row.ArchivedAt.Time
+	}
+	environment.Retention = retentionPolicy(row.RetentionKeepVersions, row.RetentionKeepDays)
+	environment.Lock = activeLock(row)
+	if row.ParentID.Valid {
+		environment.ParentID = &row.ParentID.UUID
+	}
+	return environment
+}
+
+func retentionPolicy(keepVersions, keepDays sql.NullInt32) *config.RetentionPolicy {
+	if !keepVersions.Valid && !keepDays.Valid {
+		return nil
+	}
+	return &config.RetentionPolicy{
+		KeepVersions: nullInt32Ptr(keepVersions),
+		KeepDays:     nullInt32Ptr(keepDays),
+	}
+}
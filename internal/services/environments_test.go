@@ -0,0 +1,128 @@
+package services
+
+import (
+	"context"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+)
+
+func TestLockFreezesPushes(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "production", false)
+
+	// resolved before the lock, the way a push that is already under way holds it
+	unlocked, err := s.Environments.ResolveForWrite(ctx, projectID, "production", adminID)
+	if err != nil {
+		t.Fatalf("resolve environment: %v", err)
+	}
+
+	if _, err = s.Environments.Lock(ctx, config.EnvironmentLockRequest{ProjectID: projectID, AdminID: adminID, Name: "production", Reason: "release freeze"}); err != nil {
+		t.Fatalf("lock environment: %v", err)
+	}
+
+	_, err = pushTestEnv(s, projectID, adminID, "production", nil)
+	requireErrorCode(t, err, errors.CodeLocked)
+
+	_, err = s.Env.insertEnvVersion(ctx, unlocked, nil, database.AddEnvParams{
+		ID:                uuid.New(),
+		ProjectID:         projectID,
+		EnvName:           "production",
+		Ciphertext:        []byte("ciphertext"),
+		Nonce:             []byte("nonce"),
+		WrappedDek:        []byte("wrapped-dek"),
+		DekNonce:          []byte("dek-nonce"),
+		EncryptionVersion: 1,
+		CreatedBy:         adminID,
+		Metadata:          []byte("{}"),
+		Status:            config.EnvVersionPublished,
+	}, nil)
+	requireErrorCode(t, err, errors.CodeLocked)
+
+	if _, err = s.Environments.Unlock(ctx, config.EnvironmentUnlockRequest{ProjectID: projectID, AdminID: adminID, Name: "production"}); err != nil {
+		t.Fatalf("unlock environment: %v", err)
+	}
+	if _, err = pushTestEnv(s, projectID, adminID, "production", nil); err != nil {
+		t.Fatalf("push after unlock: %v", err)
+	}
+}
+
+func TestDeleteRefusesLockedAndInheritedEnvironments(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "base", false)
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+
+	deleteEnv := func(name string) error {
+		_, err := s.Environments.Delete(ctx, config.EnvironmentDeleteRequest{ProjectID: projectID, AdminID: adminID, Name: name, Confirm: name})
+		return err
+	}
+
+	if _, err := s.Environments.SetParent(ctx, config.EnvironmentParentRequest{ProjectID: projectID, AdminID: adminID, Name: "staging", Parent: helpers.Ptr("base")}); err != nil {
+		t.Fatalf("set parent: %v", err)
+	}
+	requireErrorCode(t, deleteEnv("base"), errors.CodeConflict)
+
+	if _, err := s.Environments.Lock(ctx, config.EnvironmentLockRequest{ProjectID: projectID, AdminID: adminID, Name: "staging", Reason: "incident"}); err != nil {
+		t.Fatalf("lock environment: %v", err)
+	}
+	requireErrorCode(t, deleteEnv("staging"), errors.CodeLocked)
+
+	if _, err := s.Environments.Unlock(ctx, config.EnvironmentUnlockRequest{ProjectID: projectID, AdminID: adminID, Name: "staging"}); err != nil {
+		t.Fatalf("unlock environment: %v", err)
+	}
+	if err := deleteEnv("staging"); err != nil {
+		t.Fatalf("delete child: %v", err)
+	}
+	if err := deleteEnv("base"); err != nil {
+		t.Fatalf("delete parent once no longer inherited: %v", err)
+	}
+}
+
+func TestDeleteFreesNameAndRestoreAcceptsWrites(t *testing.T) {
+	ctx := context.Background()
+	s := newTestServices(t)
+	adminID := createTestUser(t, s, "admin@example.com")
+	projectID := createTestProject(t, s, adminID, map[uuid.UUID]string{})
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+
+	for range 2 {
+		if _, err := pushTestEnv(s, projectID, adminID, "staging", nil); err != nil {
+			t.Fatalf("push: %v", err)
+		}
+	}
+
+	if _, err := s.Environments.Delete(ctx, config.EnvironmentDeleteRequest{ProjectID: projectID, AdminID: adminID, Name: "staging", Confirm: "staging"}); err != nil {
+		t.Fatalf("delete environment: %v", err)
+	}
+	createTestEnvironment(t, s, projectID, adminID, "staging", false)
+	resp, err := pushTestEnv(s, projectID, adminID, "staging", nil)
+	if err != nil {
+		t.Fatalf("push to recreated environment: %v", err)
+	}
+	if resp.Version != 1 {
+		t.Fatalf("recreated environment starts at version %d, want 1", resp.Version)
+	}
+
+	if _, err = s.Environments.Archive(ctx, config.EnvironmentArchiveRequest{ProjectID: projectID, AdminID: adminID, Name: "staging"}); err != nil {
+		t.Fatalf("archive environment: %v", err)
+	}
+	if _, err = pushTestEnv(s, projectID, adminID, "staging", nil); err == nil {
+		t.Fatal("push to an archived environment succeeded")
+	}
+	if _, err = s.Environments.Restore(ctx, config.EnvironmentRestoreRequest{ProjectID: projectID, AdminID: adminID, Name: "staging"}); err != nil {
+		t.Fatalf("restore environment: %v", err)
+	}
+	if _, err = pushTestEnv(s, projectID, adminID, "staging", helpers.Ptr(int32(1))); err != nil {
+		t.Fatalf("push after restore: %v", err)
+	}
+}
@@ -0,0 +1,210 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// eventRetention is how long events stay available for clients to resume from.
+const eventRetention = 7 * 24 * time.Hour
+
+// eventPageSize caps how many events one poll of the event log returns.
+const eventPageSize = 100
+
+type EventService struct {
+	q *database.Queries
+}
+
+func NewEventService(q *database.Queries) *EventService {
+	return &EventService{q: q}
+}
+
+// Tail returns the id of the newest event, where a stream without a resume
+// point starts.
+func (s *EventService) Tail(ctx context.Context) (int64, error) {
+	id, err := s.q.GetLatestEventID(ctx)
+	if err != nil {
+		return 0, errors.Internal(err)
+	}
+	return id, nil
+}
+
+// RequireSessionUser checks that the request's session belongs to userID, so
+// a stream cannot be opened in another user's name.
+func (s *EventService) RequireSessionUser(ctx context.Context, userID uuid.UUID) error {
+	sessionID, ok := ctx.Value("session_id").(uuid.UUID)
+	if !ok {
+		return errors.Unauthorized("SESSION_MISSING", "User not authenticated", "")
+	}
+
+	session, err := s.q.GetSession(ctx, sessionID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return errors.Unauthorized("SESSION_EXPIRED", "Session is invalid or expired", "Please log in again")
+		}
+		return errors.Internal(err)
+	}
+	if !session.UserID.Valid || session.UserID.UUID != userID {
+		return errors.Forbidden("Session does not belong to this user", "")
+	}
+	return nil
+}
+
+// List returns the events after afterID that the user may see, and whether
+// more are waiting. Membership is checked on every call, so a revoked user
+// stops receiving project events.
+func (s *EventService) List(ctx context.Context, req config.EventStreamRequest, afterID int64) ([]config.Event, bool, error) {
+	params := database.ListEventsForUserParams{
+		AfterID:  afterID,
+		EnvName:  nullString(req.EnvName),
+		UserID:   req.UserID,
+		LimitVal: eventPageSize + 1,
+	}
+	if req.ProjectID != nil {
+		params.ProjectID = uuid.NullUUID{UUID: *req.ProjectID, Valid: true}
+	}
+
+	rows, err := s.q.ListEventsForUser(ctx, params)
+	if err != nil {
+		return nil, false, errors.Internal(err)
+	}
+
+	more := len(rows) > eventPageSize
+	if more {
+		rows = rows[:eventPageSize]
+	}
+
+	events := make([]config.Event, len(rows))
+	for i, row := range rows {
+		events[i] = config.Event{
+			ID:        row.ID,
+			Type:      row.Type,
+			ProjectID: row.ProjectID,
+			Data:      row.Payload,
+			CreatedAt: row.CreatedAt,
+		}
+		if row.EnvName.Valid {
+			events[i].EnvName = &row.EnvName.String
+		}
+	}
+	return events, more, nil
+}
+
+// PruneEvents drops events past the retention window.
+func (s *EventService) PruneEvents(ctx context.Context) error {
+	_, err := s.q.DeleteEventsBefore(ctx, time.Now().UTC().Add(-eventRetention))
+	return err
+}
+
+type eventRecord struct {
+	ProjectID     uuid.UUID
+	EnvName       *string
+	Type          string
+	SubjectUserID *uuid.UUID
+	Data          map[string]any
+}
+
+// recordEvent appends to the event log inside the transaction of txQ, so the
+// event is only visible once the change commits. Taking the id locks the
+// event sequence until then: ids become visible in order, which is what lets
+// a stream resume after the last id it saw.
+func recordEvent(ctx context.Context, txQ *database.Queries, event eventRecord) error {
+	id, err := txQ.NextEventID(ctx)
+	if err != nil {
+		return errors.Internal(err)
+	}
+
+	params := database.AddEventParams{
+		ID:        id,
+		ProjectID: event.ProjectID,
+		EnvName:   nullString(event.EnvName),
+		Type:      event.Type,
+		Payload:   mustJSON(event.Data),
+	}
+	if event.SubjectUserID != nil {
+		params.SubjectUserID = uuid.NullUUID{UUID: *event.SubjectUserID, Valid: true}
+	}
+
+	if err := txQ.AddEvent(ctx, params); err != nil {
+		return errors.Internal(err)
+	}
+	return nil
+}
+
+// logEvent records an event for a change that has already been committed. A
+// lost event only delays clients until their next pull, so it does not fail
+// the request.
+func logEvent(ctx context.Context, db *sql.DB, q *database.Queries, event eventRecord) {
+	err := inEventTx(ctx, db, q, func(txQ *database.Queries) error {
+		return recordEvent(ctx, txQ, event)
+	})
+	if err != nil {
+		log.Printf("failed to record event: type=%s project=%s err=%v", event.Type, event.ProjectID, err)
+	}
+}
+
+// inEventTx runs record in a transaction of its own, so the event sequence is
+// locked only for as long as the event takes to write.
+func inEventTx(ctx context.Context, db *sql.DB, q *database.Queries, record func(txQ *database.Queries) error) error {
+	tx, err := db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if err = record(q.WithTx(tx)); err != nil {
+		return err
+	}
+	return tx.Commit()
+}
+
+// recordVersionEvent announces a version once it is published; candidates
+// are announced when they are approved.
+func recordVersionEvent(ctx context.Context, txQ *database.Queries, env database.EnvVersion) error {
+	if env.Status != config.EnvVersionPublished {
+		return nil
+	}
+	if err := recordEvent(ctx, txQ, versionEvent(env)); err != nil {
+		return err
+	}
+	return recordInheritedEvents(ctx, txQ, env)
+}
+
+// logVersionEvent is recordVersionEvent for versions published outside a push
+// transaction.
+func logVersionEvent(ctx context.Context, db *sql.DB, q *database.Queries, env database.EnvVersion) {
+	err := inEventTx(ctx, db, q, func(txQ *database.Queries) error {
+		return recordVersionEvent(ctx, txQ, env)
+	})
+	if err != nil {
+		log.Printf("failed to record event: type=%s project=%s err=%v", config.EventEnvVersionPublished, env.ProjectID, err)
+	}
+}
+
+// accessRevokedEvent is delivered to the remaining members and to the revoked
+// user, whose stream would otherwise just go quiet.
+func accessRevokedEvent(projectID, userID uuid.UUID, reason string) eventRecord {
+	return eventRecord{
+		ProjectID:     projectID,
+		Type:          config.EventAccessRevoked,
+		SubjectUserID: &userID,
+		Data:          map[string]any{"user_id": userID, "reason": reason},
+	}
+}
+
+func versionEvent(env database.EnvVersion) eventRecord {
+	return eventRecord{
+		ProjectID: env.ProjectID,
+		EnvName:   &env.EnvName,
+		Type:      config.EventEnvVersionPublished,
+		Data:      map[string]any{"version": env.Version, "created_by": env.CreatedBy},
+	}
+}
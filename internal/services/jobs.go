@@ -0,0 +1,51 @@
+package services
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// Job is a periodic background task. Jobs run on every replica, so Run must be
+// safe to execute concurrently and idempotent.
+type Job struct {
+	Name     string
+	Interval time.Duration
+	Run      func(ctx context.Context) error
+}
+
+type Scheduler struct {
+	jobs []Job
+}
+
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+func (s *Scheduler) Register(job Job) {
+	s.jobs = append(s.jobs, job)
+}
+
+// Start runs every registered job on its interval until ctx is cancelled.
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, job := range s.jobs {
+		go s.loop(ctx, job)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, job Job) {
+	ticker := time.NewTicker(job.Interval)
+	defer ticker.Stop()
+
+	for {
+		if err := job.Run(ctx); err != nil {
+			log.Printf("background job failed: job=%s err=%v", job.Name, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
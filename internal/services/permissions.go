@@ -0,0 +1,38 @@
+package services
+
+import (
+	"context"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// requireProjectMember returns the caller's active membership of the project.
+func requireProjectMember(ctx context.Context, q *database.Queries, projectID, userID uuid.UUID) (database.ProjectMember, error) {
+	member, err := q.GetUserProjectRole(ctx, database.GetUserProjectRoleParams{
+		ProjectID: projectID,
+		UserID:    userID,
+		IsRevoked: false,
+	})
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return database.ProjectMember{}, errors.Forbidden("You don't have permission to access this project", "")
+		}
+		return database.ProjectMember{}, errors.Internal(err)
+	}
+	return member, nil
+}
+
+// requireProjectAdmin is requireProjectMember restricted to the admin role.
+func requireProjectAdmin(ctx context.Context, q *database.Queries, projectID, userID uuid.UUID) (database.ProjectMember, error) {
+	member, err := requireProjectMember(ctx, q, projectID, userID)
+	if err != nil {
+		return database.ProjectMember{}, err
+	}
+	if member.Role != "admin" {
+		return database.ProjectMember{}, errors.Forbidden("Only project admins can perform this action", "")
+	}
+	return member, nil
+}
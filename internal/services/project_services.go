@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"time"
 
 	"github.com/google/uuid"
 	"github.com/vijayvenkatj/envcrypt/database"
@@ -13,6 +14,9 @@ import (
 	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
 )
 
+// membershipExpiryWarning is how far ahead of expiry a membership is reported as expiring soon.
+const membershipExpiryWarning = 7 * 24 * time.Hour
+
 type ProjectService struct {
 	q     *database.Queries
 	db    *sql.DB
@@ -95,12 +99,18 @@ func (s *ProjectService) ListProjects(ctx context.Context, requestBody config.Li
 		Projects: make([]config.Project, len(projects)),
 	}
 
+	now := time.Now().UTC()
 	for i, project := range projects {
 		resp.Projects[i] = config.Project{
-			Id:        project.ID,
-			Name:      project.Name,
-			Role:      project.Role,
-			IsRevoked: project.IsRevoked,
+			Id:               project.ID,
+			Name:             project.Name,
+			Role:             project.Role,
+			IsRevoked:        project.IsRevoked,
+			RotationRequired: project.RotationRequired,
+		}
+		if project.ExpiresAt.Valid {
+			resp.Projects[i].ExpiresAt = &project.ExpiresAt.Time
+			resp.Projects[i].ExpiringSoon = project.ExpiresAt.Time.Sub(now) <= membershipExpiryWarning
 		}
 	}
 
@@ -189,6 +199,14 @@ func (s *ProjectService) AddUserToProject(ctx context.Context, requestBody confi
 		return errors.Forbidden("Your access to this project has been revoked", "Contact the project admin")
 	}
 
+	var expiresAt sql.NullTime
+	if requestBody.ExpiresAt != nil {
+		if !requestBody.ExpiresAt.After(time.Now()) {
+			return errors.Validation(map[string]string{"expires_at": "expires_at must be in the future"})
+		}
+		expiresAt = sql.NullTime{Time: requestBody.ExpiresAt.UTC(), Valid: true}
+	}
+
 	var role = "member"
 	tx, err := s.db.BeginTx(ctx, nil)
 	if err != nil {
@@ -202,6 +220,7 @@ func (s *ProjectService) AddUserToProject(ctx context.Context, requestBody confi
 		ProjectID: project.ID,
 		UserID:    requestBody.UserId,
 		Role:      role,
+		ExpiresAt: expiresAt,
 	})
 	if err != nil {
 		s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeUser, ActorID: requestBody.AdminId.String(), ActorEmail: adminUser.Email, ProjectID: &project.ID, TargetID: helpers.Ptr(requestBody.UserId.String()), Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
@@ -227,7 +246,7 @@ func (s *ProjectService) AddUserToProject(ctx context.Context, requestBody confi
 		return errors.InternalMessage("Unable to commit membership transaction", err)
 	}
 
-	s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeUser, ActorID: requestBody.AdminId.String(), ActorEmail: adminUser.Email, ProjectID: &project.ID, TargetID: helpers.Ptr(requestBody.UserId.String()), Status: config.StatusSuccess})
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeUser, ActorID: requestBody.AdminId.String(), ActorEmail: adminUser.Email, ProjectID: &project.ID, TargetID: helpers.Ptr(requestBody.UserId.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"expires_at": requestBody.ExpiresAt})})
 
 	return nil
 }
@@ -288,9 +307,115 @@ func (s *ProjectService) SetUserAccess(ctx context.Context, requestBody config.S
 
 	s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeUser, ActorID: requestBody.AdminId.String(), ActorEmail: adminUser.Email, ProjectID: &project.ID, TargetID: helpers.Ptr(user.ID.String()), Status: config.StatusSuccess})
 
+	if requestBody.IsRevoked {
+		logEvent(ctx, s.db, s.q, accessRevokedEvent(project.ID, user.ID, "revoked"))
+	}
+
 	return nil
 }
 
+func (s *ProjectService) RenewMembership(ctx context.Context, requestBody config.RenewMembershipRequest) (*time.Time, error) {
+	adminUser, err := s.q.GetUserByID(ctx, requestBody.AdminID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("User", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	if _, err = requireProjectAdmin(ctx, s.q, requestBody.ProjectID, requestBody.AdminID); err != nil {
+		return nil, err
+	}
+
+	var expiresAt sql.NullTime
+	if requestBody.ExpiresAt != nil {
+		if !requestBody.ExpiresAt.After(time.Now()) {
+			return nil, errors.Validation(map[string]string{"expires_at": "expires_at must be in the future"})
+		}
+		expiresAt = sql.NullTime{Time: requestBody.ExpiresAt.UTC(), Valid: true}
+	}
+
+	member, err := s.q.RenewProjectMember(ctx, database.RenewProjectMemberParams{
+		ProjectID: requestBody.ProjectID,
+		UserID:    requestBody.UserID,
+		ExpiresAt: expiresAt,
+	})
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeUser, ActorID: requestBody.AdminID.String(), ActorEmail: adminUser.Email, ProjectID: &requestBody.ProjectID, TargetID: helpers.Ptr(requestBody.UserID.String()), Status: config.StatusFailure, ErrMsg: helpers.Ptr("unable to renew membership")})
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Membership", "Expired or revoked members must be added to the project again")
+		}
+		return nil, errors.InternalMessage("Unable to renew membership", err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeUser, ActorID: requestBody.AdminID.String(), ActorEmail: adminUser.Email, ProjectID: &requestBody.ProjectID, TargetID: helpers.Ptr(requestBody.UserID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"renewed_until": requestBody.ExpiresAt})})
+
+	if !member.ExpiresAt.Valid {
+		return nil, nil
+	}
+	return &member.ExpiresAt.Time, nil
+}
+
+// RevokeExpiredMembers revokes memberships past their expiry, drops the members'
+// wrapped PRKs and flags the affected projects for PRK rotation. Each member is
+// revoked in its own transaction, so a failure never leaves a revoked member
+// without the rotation flag; the member is retried on the next run.
+func (s *ProjectService) RevokeExpiredMembers(ctx context.Context) error {
+	expired, err := s.q.ListExpiredMembers(ctx)
+	if err != nil {
+		return err
+	}
+
+	for _, member := range expired {
+		revoked, err := s.revokeExpiredMember(ctx, member)
+		if err != nil {
+			s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeSystem, ProjectID: &member.ProjectID, TargetID: helpers.Ptr(member.UserID.String()), Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error()), Metadata: mustJSON(map[string]any{"reason": "membership_expired"})})
+			continue
+		}
+		if !revoked {
+			continue
+		}
+
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionMembershipChange, ActorType: config.ActorTypeSystem, ProjectID: &member.ProjectID, TargetID: helpers.Ptr(member.UserID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"reason": "membership_expired", "expired_at": member.ExpiresAt.Time})})
+		logEvent(ctx, s.db, s.q, accessRevokedEvent(member.ProjectID, member.UserID, "membership_expired"))
+	}
+
+	return nil
+}
+
+// revokeExpiredMember reports false when the membership was renewed or revoked
+// since it was listed.
+func (s *ProjectService) revokeExpiredMember(ctx context.Context, member database.ProjectMember) (bool, error) {
+	tx, err := s.db.BeginTx(ctx, nil)
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	txQ := s.q.WithTx(tx)
+
+	if _, err = txQ.RevokeExpiredMember(ctx, database.RevokeExpiredMemberParams{
+		ProjectID: member.ProjectID,
+		UserID:    member.UserID,
+	}); err != nil {
+		if dberrors.IsNoRows(err) {
+			return false, nil
+		}
+		return false, err
+	}
+	if err = txQ.DeleteWrappedPRK(ctx, database.DeleteWrappedPRKParams{
+		ProjectID: member.ProjectID,
+		UserID:    member.UserID,
+	}); err != nil {
+		return false, err
+	}
+	if err = txQ.SetRotationRequired(ctx, member.ProjectID); err != nil {
+		return false, err
+	}
+
+	return true, tx.Commit()
+}
+
 func (s *ProjectService) GetUserProject(ctx context.Context, requestBody config.GetUserProjectRequest) (*config.GetUserProjectResponse, error) {
 
 	project, err := s.q.GetProject(ctx, database.GetProjectParams{
@@ -402,6 +527,7 @@ func (s *ProjectService) RotateInit(ctx context.Context, req config.RotateInitRe
 		MemberPublicKeys: make([]config.MemberPublicKey, len(rotationData)),
 		WrappedDEKs:      make([]config.WrappedDEK, len(wrappedDEKs)),
 		PRKVersion:       project.PrkVersion,
+		RotationRequired: project.RotationRequired,
 	}
 
 	for i, row := range rotationData {
@@ -502,6 +628,14 @@ func (s *ProjectService) RotateCommit(ctx context.Context, req config.RotateComm
 		}
 	}
 
+	if err = recordEvent(ctx, txQ, eventRecord{
+		ProjectID: req.ProjectID,
+		Type:      config.EventPRKRotated,
+		Data:      map[string]any{"prk_version": newVersion},
+	}); err != nil {
+		return nil, err
+	}
+
 	if err = tx.Commit(); err != nil {
 		return nil, errors.InternalMessage("Failed to commit rotation transaction", err)
 	}
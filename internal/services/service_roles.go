@@ -13,8 +13,9 @@ import (
 )
 
 type ServiceRoleServices struct {
-	q     *database.Queries
-	audit *AuditService
+	q            *database.Queries
+	audit        *AuditService
+	environments *EnvironmentService
 }
 
 func NewServiceRoleService(q *database.Queries) *ServiceRoleServices {
@@ -179,6 +180,17 @@ func (s *ServiceRoleServices) DelegateAccess(ctx context.Context, requestBody co
 		return errors.Internal(err)
 	}
 
+	if _, err = s.environments.Resolve(ctx, requestBody.ProjectId, requestBody.EnvName, requestBody.DelegatedBy); err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionServiceRoleDelegate, ActorType: config.ActorTypeUser, ActorID: requestBody.DelegatedBy.String(), ActorEmail: actor.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, TargetID: helpers.Ptr(serviceRole.ID.String()), Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
+		return err
+	}
+
+	if requestBody.PinnedLabel != nil {
+		if err = requireEnvLabel(ctx, s.q, requestBody.ProjectId, requestBody.EnvName, *requestBody.PinnedLabel); err != nil {
+			return err
+		}
+	}
+
 	_, err = s.q.DelegateAccess(ctx, database.DelegateAccessParams{
 		ServiceRoleID:    serviceRole.ID,
 		ProjectID:        requestBody.ProjectId,
@@ -187,6 +199,7 @@ func (s *ServiceRoleServices) DelegateAccess(ctx context.Context, requestBody co
 		WrapNonce:        requestBody.WrapNonce,
 		WrapEphemeralPub: requestBody.EphemeralPublicKey,
 		DelegatedBy:      requestBody.DelegatedBy,
+		PinnedLabel:      nullString(requestBody.PinnedLabel),
 	})
 	if err != nil {
 		s.audit.Log(ctx, AuditEntry{Action: config.ActionServiceRoleDelegate, ActorType: config.ActorTypeUser, ActorID: requestBody.DelegatedBy.String(), ActorEmail: actor.Email, ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName, TargetID: helpers.Ptr(serviceRole.ID.String()), Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
@@ -218,9 +231,61 @@ func (s *ServiceRoleServices) GetPerms(ctx context.Context, requestBody config.S
 		return nil, errors.Internal(err)
 	}
 
-	return &config.ServiceRolePermsResponse{
+	resp := &config.ServiceRolePermsResponse{
 		ProjectID:   projectDelegated.ProjectID,
 		ProjectName: projectDelegated.ProjectName,
 		Env:         projectDelegated.Env,
-	}, nil
+	}
+	if projectDelegated.PinnedLabel.Valid {
+		resp.PinnedLabel = &projectDelegated.PinnedLabel.String
+	}
+	return resp, nil
+}
+
+// Pin points a delegation at a label so the service role only sees a new
+// version when someone moves the label. A nil label follows the latest
+// version again.
+func (s *ServiceRoleServices) Pin(ctx context.Context, requestBody config.ServiceRolePinRequest) error {
+	entry := AuditEntry{Action: config.ActionServiceRolePin, ActorType: config.ActorTypeUser, ActorID: requestBody.AdminID.String(), ActorEmail: actorEmail(ctx, s.q, requestBody.AdminID), ProjectID: &requestBody.ProjectId, Environment: &requestBody.EnvName}
+
+	if _, err := requireProjectAdmin(ctx, s.q, requestBody.ProjectId, requestBody.AdminID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return err
+	}
+
+	serviceRole, err := s.q.GetServiceRoleByPrincipal(ctx, requestBody.RepoPrincipal)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return errors.NotFound("Service role", "")
+		}
+		return errors.Internal(err)
+	}
+	entry.TargetID = helpers.Ptr(serviceRole.ID.String())
+
+	if requestBody.Label != nil {
+		if err = requireEnvLabel(ctx, s.q, requestBody.ProjectId, requestBody.EnvName, *requestBody.Label); err != nil {
+			return err
+		}
+	}
+
+	updated, err := s.q.SetDelegationPinnedLabel(ctx, database.SetDelegationPinnedLabelParams{
+		ServiceRoleID: serviceRole.ID,
+		ProjectID:     requestBody.ProjectId,
+		Env:           requestBody.EnvName,
+		PinnedLabel:   nullString(requestBody.Label),
+	})
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return errors.Internal(err)
+	}
+	if updated == 0 {
+		return errors.NotFound("Delegation", "Delegate the service role to this environment first")
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"label": requestBody.Label})
+	s.audit.Log(ctx, entry)
+	return nil
 }
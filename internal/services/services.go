@@ -2,6 +2,7 @@ package services
 
 import (
 	"database/sql"
+	"time"
 
 	"github.com/vijayvenkatj/envcrypt/database"
 )
@@ -10,13 +11,20 @@ type Services struct {
 	Users          *UserService
 	Projects       *ProjectService
 	Env            *EnvServices
+	Environments   *EnvironmentService
 	ServiceRoles   *ServiceRoleServices
 	SessionService *SessionService
 	Audit          *AuditService
 	Snapshot       *SnapshotService
+	AccessRequests *AccessRequestService
+	BreakGlass     *BreakGlassService
+	Events         *EventService
+	Webhooks       *WebhookService
+	Shares         *ShareService
+	Jobs           *Scheduler
 }
 
-func NewServices(queries *database.Queries, auditService *AuditService, db *sql.DB) *Services {
+func NewServices(queries *database.Queries, auditService *AuditService, db *sql.DB, webhookKey []byte) *Services {
 	users := NewUserService(queries, db)
 	users.audit = auditService
 
@@ -24,11 +32,17 @@ func NewServices(queries *database.Queries, auditService *AuditService, db *sql.
 	projects.audit = auditService
 	projects.db = db
 
+	environments := NewEnvironmentService(queries, db)
+	environments.audit = auditService
+
 	env := NewEnvService(queries)
 	env.audit = auditService
+	env.db = db
+	env.environments = environments
 
 	serviceRoles := NewServiceRoleService(queries)
 	serviceRoles.audit = auditService
+	serviceRoles.environments = environments
 
 	sessionService := NewSessionService(queries, db)
 	sessionService.audit = auditService
@@ -36,13 +50,45 @@ func NewServices(queries *database.Queries, auditService *AuditService, db *sql.
 	snapshot := NewSnapshotService(queries, db)
 	snapshot.SetAuditService(auditService)
 
+	accessRequests := NewAccessRequestService(queries, db)
+	accessRequests.audit = auditService
+
+	breakGlass := NewBreakGlassService(queries, db)
+	breakGlass.audit = auditService
+
+	events := NewEventService(queries)
+
+	webhooks := NewWebhookService(queries, webhookKey)
+	webhooks.audit = auditService
+	auditService.webhooks = webhooks
+
+	shares := NewShareService(queries, db)
+	shares.audit = auditService
+
+	jobs := NewScheduler()
+	jobs.Register(Job{Name: "membership-expiry", Interval: time.Minute, Run: projects.RevokeExpiredMembers})
+	jobs.Register(Job{Name: "break-glass-expiry", Interval: time.Minute, Run: breakGlass.ExpireGrants})
+	jobs.Register(Job{Name: "env-candidate-expiry", Interval: 5 * time.Minute, Run: env.ExpireCandidates})
+	jobs.Register(Job{Name: "env-retention", Interval: time.Hour, Run: env.ApplyRetention})
+	jobs.Register(Job{Name: "secret-expiry", Interval: time.Hour, Run: env.NotifyExpiries})
+	jobs.Register(Job{Name: "event-prune", Interval: time.Hour, Run: events.PruneEvents})
+	jobs.Register(Job{Name: "share-cleanup", Interval: 5 * time.Minute, Run: shares.DeleteSpentShares})
+	jobs.Register(Job{Name: "webhook-delivery", Interval: 15 * time.Second, Run: webhooks.DeliverPending})
+
 	return &Services{
 		Users:          users,
 		Projects:       projects,
 		Env:            env,
+		Environments:   environments,
 		ServiceRoles:   serviceRoles,
 		SessionService: sessionService,
 		Audit:          auditService,
 		Snapshot:       snapshot,
+		AccessRequests: accessRequests,
+		BreakGlass:     breakGlass,
+		Events:         events,
+		Webhooks:       webhooks,
+		Shares:         shares,
+		Jobs:           jobs,
 	}
 }
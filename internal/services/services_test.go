@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	_ "modernc.org/sqlite"
+)
+
+// newTestServices wires every service to a fresh SQLite database with the
+// migrations applied, set up the way the server opens SQLite.
+func newTestServices(t *testing.T) *Services {
+	t.Helper()
+
+	conn, err := sql.Open("sqlite", filepath.Join(t.TempDir(), "envcrypt.db"))
+	if err != nil {
+		t.Fatalf("open sqlite: %v", err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	conn.SetMaxOpenConns(1)
+	if _, err = conn.Exec("PRAGMA foreign_keys = ON"); err != nil {
+		t.Fatalf("enable foreign keys: %v", err)
+	}
+
+	paths, err := filepath.Glob(filepath.Join("..", "db", "sqlite_migrations", "*.sql"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("find sqlite migrations: %v", err)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		migration, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		up, _, _ := strings.Cut(string(migration), "-- +goose Down")
+		if _, err = conn.Exec(up); err != nil {
+			t.Fatalf("apply %s: %v", filepath.Base(path), err)
+		}
+	}
+
+	q := database.New(conn)
+	return NewServices(q, NewAuditService(q), conn, nil)
+}
+
+// createTestUser adds a user with placeholder key material.
+func createTestUser(t *testing.T, s *Services, email string) uuid.UUID {
+	t.Helper()
+
+	user, err := s.Users.q.CreateUser(context.Background(), database.CreateUserParams{
+		ID:                      uuid.New(),
+		Email:                   email,
+		PasswordHash:            "hash",
+		PasswordSalt:            []byte("salt"),
+		UserPublicKey:           []byte("public-key"),
+		EncryptedUserPrivateKey: []byte("private-key"),
+		PrivateKeyNonce:         []byte("nonce"),
+		PrivateKeySalt:          []byte("salt"),
+		ArgonParams:             []byte(`{"time":3,"memory":65536,"parallelism":1}`),
+	})
+	if err != nil {
+		t.Fatalf("create user %s: %v", email, err)
+	}
+	return user.ID
+}
+
+// createTestProject creates a project administered by adminID, with the other
+// users added under the given roles.
+func createTestProject(t *testing.T, s *Services, adminID uuid.UUID, members map[uuid.UUID]string) uuid.UUID {
+	t.Helper()
+	ctx := context.Background()
+
+	project, err := s.Projects.q.CreateProject(ctx, database.CreateProjectParams{
+		ID:        uuid.New(),
+		Name:      "project-" + adminID.String()[:8],
+		CreatedBy: adminID,
+	})
+	if err != nil {
+		t.Fatalf("create project: %v", err)
+	}
+
+	members[adminID] = "admin"
+	for userID, role := range members {
+		if _, err = s.Projects.q.AddUserToProject(ctx, database.AddUserToProjectParams{
+			ProjectID: project.ID,
+			UserID:    userID,
+			Role:      role,
+		}); err != nil {
+			t.Fatalf("add member: %v", err)
+		}
+	}
+	return project.ID
+}
+
+// createTestEnvironment creates an environment as the project admin.
+func createTestEnvironment(t *testing.T, s *Services, projectID, adminID uuid.UUID, name string, protected bool) {
+	t.Helper()
+
+	if _, err := s.Environments.Create(context.Background(), config.EnvironmentCreateRequest{
+		ProjectID: projectID,
+		AdminID:   adminID,
+		Name:      name,
+		Protected: protected,
+	}); err != nil {
+		t.Fatalf("create environment %s: %v", name, err)
+	}
+}
+
+// pushTestEnv pushes a whole-file version based on expectedVersion, under a
+// DEK of its own.
+func pushTestEnv(s *Services, projectID, userID uuid.UUID, envName string, expectedVersion *int32) (*config.AddEnvResponse, error) {
+	return s.Env.AddEnv(context.Background(), config.AddEnvRequest{
+		ProjectId:         projectID,
+		UserId:            userID,
+		EnvName:           envName,
+		CipherText:        []byte("ciphertext"),
+		Nonce:             []byte("nonce"),
+		WrappedDEK:        []byte("wrapped-dek-" + uuid.NewString()),
+		DekNonce:          []byte("dek-nonce"),
+		EncryptionVersion: 1,
+		ExpectedVersion:   expectedVersion,
+	})
+}
+
+// requireErrorCode fails unless err is an application error with code.
+func requireErrorCode(t *testing.T, err error, code errors.Code) {
+	t.Helper()
+
+	if !errors.IsCode(err, code) {
+		t.Fatalf("expected a %s error, got %v", code, err)
+	}
+}
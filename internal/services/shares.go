@@ -0,0 +1,141 @@
+package services
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// shareTTL is how long a share lives when the creator does not say.
+const shareTTL = 24 * time.Hour
+
+type ShareService struct {
+	q     *database.Queries
+	db    *sql.DB
+	audit *AuditService
+}
+
+func NewShareService(q *database.Queries, db *sql.DB) *ShareService {
+	return &ShareService{q: q, db: db}
+}
+
+// Create stores a client-encrypted payload behind a one-time link.
+func (s *ShareService) Create(ctx context.Context, req config.ShareCreateRequest) (*config.Share, error) {
+	creator, err := s.q.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("User", "")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	maxViews := int32(1)
+	if req.MaxViews != nil {
+		maxViews = *req.MaxViews
+	}
+	expiresAt := time.Now().UTC().Add(shareTTL)
+	if req.ExpiresInMinutes != nil {
+		expiresAt = time.Now().UTC().Add(time.Duration(*req.ExpiresInMinutes) * time.Minute)
+	}
+
+	share, err := s.q.CreateShare(ctx, database.CreateShareParams{
+		ID:         uuid.New(),
+		CreatedBy:  req.UserID,
+		Ciphertext: req.Ciphertext,
+		Nonce:      req.Nonce,
+		MaxViews:   maxViews,
+		ExpiresAt:  expiresAt,
+	})
+	if err != nil {
+		s.audit.Log(ctx, AuditEntry{Action: config.ActionShareCreate, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: creator.Email, Status: config.StatusFailure, ErrMsg: helpers.Ptr(err.Error())})
+		return nil, errors.Internal(err)
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionShareCreate, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: creator.Email, TargetID: helpers.Ptr(share.ID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"max_views": share.MaxViews, "expires_at": share.ExpiresAt})})
+
+	resp := toShare(share)
+	return &resp, nil
+}
+
+// View opens a share and counts the view. The share is deleted with its last
+// view. Viewers are anonymous, so views are audited against the creator.
+func (s *ShareService) View(ctx context.Context, req config.ShareViewRequest) (*config.ShareViewResponse, error) {
+	share, err := s.q.ViewShare(ctx, req.ID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil, errors.NotFound("Share", "The link has expired or was already used")
+		}
+		return nil, errors.Internal(err)
+	}
+
+	if share.Views >= share.MaxViews {
+		// a failed delete leaves a spent share nobody can view; the
+		// cleanup job removes it
+		if err := s.q.DeleteShare(ctx, share.ID); err != nil {
+			log.Printf("failed to delete spent share: id=%s err=%v", share.ID, err)
+		}
+	}
+
+	var creatorEmail string
+	if creator, err := s.q.GetUserByID(ctx, share.CreatedBy); err == nil {
+		creatorEmail = creator.Email
+	}
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionShareView, ActorType: config.ActorTypeUser, ActorID: share.CreatedBy.String(), ActorEmail: creatorEmail, TargetID: helpers.Ptr(share.ID.String()), Status: config.StatusSuccess, Metadata: mustJSON(map[string]any{"views": share.Views, "max_views": share.MaxViews})})
+
+	return &config.ShareViewResponse{
+		Ciphertext: share.Ciphertext,
+		Nonce:      share.Nonce,
+		Remaining:  share.MaxViews - share.Views,
+		ExpiresAt:  share.ExpiresAt,
+	}, nil
+}
+
+// Revoke deletes a share before it is used up. Only its creator may revoke it.
+func (s *ShareService) Revoke(ctx context.Context, req config.ShareRevokeRequest) error {
+	creator, err := s.q.GetUserByID(ctx, req.UserID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return errors.NotFound("User", "")
+		}
+		return errors.Internal(err)
+	}
+
+	deleted, err := s.q.RevokeShare(ctx, database.RevokeShareParams{
+		ID:        req.ID,
+		CreatedBy: req.UserID,
+	})
+	if err != nil {
+		return errors.Internal(err)
+	}
+	if deleted == 0 {
+		return errors.NotFound("Share", "It may have expired, been used up or belong to someone else")
+	}
+
+	s.audit.Log(ctx, AuditEntry{Action: config.ActionShareRevoke, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: creator.Email, TargetID: helpers.Ptr(req.ID.String()), Status: config.StatusSuccess})
+	return nil
+}
+
+// DeleteSpentShares removes expired and used up shares. It runs as a
+// background job.
+func (s *ShareService) DeleteSpentShares(ctx context.Context) error {
+	_, err := s.q.DeleteSpentShares(ctx)
+	return err
+}
+
+func toShare(share database.Share) config.Share {
+	return config.Share{
+		ID:        share.ID,
+		MaxViews:  share.MaxViews,
+		Views:     share.Views,
+		ExpiresAt: share.ExpiresAt,
+		CreatedAt: share.CreatedAt,
+	}
+}
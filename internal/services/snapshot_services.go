@@ -101,6 +101,11 @@ func (s *SnapshotService) ExportSnapshot(ctx context.Context, req config.Snapsho
 
 	var snapshotEnvs []config.SnapshotEnvVersion
 	for _, env := range envVersions {
+		entries, err := listEnvEntries(ctx, s.q, env)
+		if err != nil {
+			return nil, err
+		}
+
 		snapshotEnvs = append(snapshotEnvs, config.SnapshotEnvVersion{
 			EnvVersionID:      env.ID,
 			EnvName:           env.EnvName,
@@ -113,6 +118,7 @@ func (s *SnapshotService) ExportSnapshot(ctx context.Context, req config.Snapsho
 			CreatedAt:         env.CreatedAt,
 			CreatedBy:         env.CreatedBy,
 			Metadata:          env.Metadata,
+			Entries:           entries,
 		})
 	}
 
@@ -226,9 +232,24 @@ func (s *SnapshotService) ImportSnapshot(ctx context.Context, req config.Snapsho
 		}
 	}
 
+	registered := make(map[string]bool)
 	for _, env := range req.Snapshot.EnvVersions {
+		if !registered[env.EnvName] {
+			_, err = txQ.CreateEnvironment(ctx, database.CreateEnvironmentParams{
+				ID:        uuid.New(),
+				ProjectID: newProjectID,
+				Name:      env.EnvName,
+				CreatedBy: req.UserID,
+			})
+			if err != nil {
+				return nil, errors.Internal(err)
+			}
+			registered[env.EnvName] = true
+		}
+
+		envVersionID := uuid.New()
 		err = txQ.InsertEnvVersionRaw(ctx, database.InsertEnvVersionRawParams{
-			ID:                uuid.New(),
+			ID:                envVersionID,
 			ProjectID:         newProjectID,
 			EnvName:           env.EnvName,
 			Version:           env.Version,
@@ -244,6 +265,29 @@ func (s *SnapshotService) ImportSnapshot(ctx context.Context, req config.Snapsho
 		if err != nil {
 			return nil, errors.Internal(err)
 		}
+
+		if err = indexVersionMetadata(ctx, txQ, envVersionID, env.Metadata); err != nil {
+			return nil, err
+		}
+
+		for _, entry := range env.Entries {
+			err = txQ.UpsertEnvEntry(ctx, database.UpsertEnvEntryParams{
+				EnvVersionID:     envVersionID,
+				KeyHmac:          entry.KeyHMAC,
+				NameCiphertext:   entry.NameCiphertext,
+				NameNonce:        entry.NameNonce,
+				ValueCiphertext:  entry.ValueCiphertext,
+				ValueNonce:       entry.ValueNonce,
+				ChangedInVersion: entry.ChangedInVersion,
+			})
+			if err != nil {
+				return nil, errors.Internal(err)
+			}
+		}
+	}
+
+	if err = txQ.SyncEnvironmentHeads(ctx, newProjectID); err != nil {
+		return nil, errors.Internal(err)
 	}
 
 	if err = tx.Commit(); err != nil {
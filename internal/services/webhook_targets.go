@@ -0,0 +1,135 @@
+package services
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"syscall"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+)
+
+// blockedWebhookPrefixes are ranges a webhook may not reach on top of the
+// loopback, private, link-local and multicast ones netip already classifies.
+// Cloud metadata endpoints sit in the link-local range.
+var blockedWebhookPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+}
+
+// allowedWebhookIP reports whether a webhook may be delivered to the address.
+func allowedWebhookIP(addr netip.Addr) bool {
+	addr = addr.Unmap()
+	if !addr.IsValid() || addr.IsUnspecified() || addr.IsLoopback() || addr.IsPrivate() ||
+		addr.IsLinkLocalUnicast() || addr.IsLinkLocalMulticast() || addr.IsInterfaceLocalMulticast() || addr.IsMulticast() {
+		return false
+	}
+	for _, prefix := range blockedWebhookPrefixes {
+		if prefix.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// checkWebhookTarget resolves the host of a new webhook and refuses it if any
+// address is internal. The dialer checks again on every delivery, since DNS
+// can change after creation.
+func checkWebhookTarget(ctx context.Context, rawURL string) error {
+	endpoint, err := url.Parse(rawURL)
+	if err != nil {
+		return errors.Validation(map[string]string{"url": "url must be an absolute https URL"})
+	}
+
+	addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", endpoint.Hostname())
+	if err != nil || len(addrs) == 0 {
+		return errors.Validation(map[string]string{"url": "url host does not resolve"})
+	}
+	for _, addr := range addrs {
+		if !allowedWebhookIP(addr) {
+			return errors.Validation(map[string]string{"url": "url must not point at a private or internal address"})
+		}
+	}
+	return nil
+}
+
+// newWebhookTransport dials only public addresses. The check runs on the
+// resolved address of every connection, so rebinding a name to an internal
+// address after creation does not get through. Proxies are not used, as they
+// would dial on our behalf.
+func newWebhookTransport() *http.Transport {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			addrPort, err := netip.ParseAddrPort(address)
+			if err != nil {
+				return err
+			}
+			if !allowedWebhookIP(addrPort.Addr()) {
+				return fmt.Errorf("webhook address %s is not allowed", addrPort.Addr())
+			}
+			return nil
+		},
+	}
+
+	return &http.Transport{
+		Proxy:                 nil,
+		DialContext:           dialer.DialContext,
+		TLSHandshakeTimeout:   webhookTimeout,
+		ResponseHeaderTimeout: webhookTimeout,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          10,
+	}
+}
+
+// sealWebhookSecret encrypts a signing secret with AES-256-GCM under the
+// server's webhook key. The webhook id is bound as additional data so a
+// secret cannot be moved to another webhook's row.
+func sealWebhookSecret(key []byte, webhookID uuid.UUID, secret string) (ciphertext, nonce []byte, err error) {
+	aead, err := webhookAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce = make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+	return aead.Seal(nil, nonce, []byte(secret), webhookID[:]), nonce, nil
+}
+
+func openWebhookSecret(key []byte, webhookID uuid.UUID, ciphertext, nonce []byte) (string, error) {
+	aead, err := webhookAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	secret, err := aead.Open(nil, nonce, ciphertext, webhookID[:])
+	if err != nil {
+		return "", fmt.Errorf("webhook secret does not decrypt: %w", err)
+	}
+	return string(secret), nil
+}
+
+func webhookAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("webhook key is not configured")
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
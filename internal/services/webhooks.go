@@ -0,0 +1,448 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/vijayvenkatj/envcrypt/database"
+	"github.com/vijayvenkatj/envcrypt/internal/config"
+	"github.com/vijayvenkatj/envcrypt/internal/errors"
+	"github.com/vijayvenkatj/envcrypt/internal/helpers"
+	dberrors "github.com/vijayvenkatj/envcrypt/internal/helpers/db"
+)
+
+// Delivery tuning. Retries back off exponentially from webhookBaseDelay up to
+// webhookMaxDelay, and a delivery that failed webhookMaxAttempts times is
+// dead-lettered until someone redelivers it. The lease covers a whole batch of
+// timed-out requests, so another replica never picks up a delivery in flight.
+const (
+	webhookMaxAttempts = 8
+	webhookBaseDelay   = 30 * time.Second
+	webhookMaxDelay    = 6 * time.Hour
+	webhookTimeout     = 10 * time.Second
+	webhookBatchSize   = 10
+	webhookLease       = 5 * time.Minute
+	webhookMaxErrorLen = 500
+)
+
+type WebhookService struct {
+	q      *database.Queries
+	audit  *AuditService
+	client *http.Client
+	// key encrypts the signing secrets at rest; webhooks cannot be created
+	// without it
+	key []byte
+}
+
+func NewWebhookService(q *database.Queries, key []byte) *WebhookService {
+	return &WebhookService{
+		q:   q,
+		key: key,
+		client: &http.Client{
+			Timeout:   webhookTimeout,
+			Transport: newWebhookTransport(),
+			// a redirect is reported as a failed delivery instead of being followed
+			CheckRedirect: func(*http.Request, []*http.Request) error {
+				return http.ErrUseLastResponse
+			},
+		},
+	}
+}
+
+func (s *WebhookService) Create(ctx context.Context, req config.WebhookCreateRequest) (*config.WebhookCreateResponse, error) {
+	entry := AuditEntry{Action: config.ActionWebhookCreate, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID}
+
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	if len(s.key) == 0 {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("webhook key not configured")
+		s.audit.Log(ctx, entry)
+		return nil, errors.BadRequest("Webhooks are not enabled on this server", "Set WEBHOOK_KEY to enable webhooks")
+	}
+
+	if err := checkWebhookTarget(ctx, req.URL); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("webhook target not allowed")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	secretBytes := make([]byte, 32)
+	if _, err := rand.Read(secretBytes); err != nil {
+		return nil, errors.InternalMessage("Failed to generate webhook secret", err)
+	}
+	secret := hex.EncodeToString(secretBytes)
+
+	webhookID := uuid.New()
+	ciphertext, nonce, err := sealWebhookSecret(s.key, webhookID, secret)
+	if err != nil {
+		return nil, errors.InternalMessage("Failed to encrypt webhook secret", err)
+	}
+
+	events, err := json.Marshal(req.Events)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	webhook, err := s.q.CreateWebhook(ctx, database.CreateWebhookParams{
+		ID:               webhookID,
+		ProjectID:        req.ProjectID,
+		Url:              req.URL,
+		SecretCiphertext: ciphertext,
+		SecretNonce:      nonce,
+		Events:           events,
+		CreatedBy:        req.UserID,
+	})
+	if err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr(err.Error())
+		s.audit.Log(ctx, entry)
+		return nil, errors.Internal(err)
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.TargetID = helpers.Ptr(webhook.ID.String())
+	entry.Metadata = mustJSON(map[string]any{"url": webhook.Url, "events": req.Events})
+	s.audit.Log(ctx, entry)
+
+	return &config.WebhookCreateResponse{
+		Webhook: toWebhook(webhook),
+		Secret:  secret,
+	}, nil
+}
+
+func (s *WebhookService) List(ctx context.Context, req config.WebhookListRequest) (*config.WebhookListResponse, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	webhooks, err := s.q.ListWebhooks(ctx, req.ProjectID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.WebhookListResponse{
+		Webhooks: make([]config.Webhook, len(webhooks)),
+	}
+	for i, webhook := range webhooks {
+		resp.Webhooks[i] = toWebhook(webhook)
+	}
+	return resp, nil
+}
+
+func (s *WebhookService) Delete(ctx context.Context, req config.WebhookDeleteRequest) error {
+	entry := AuditEntry{Action: config.ActionWebhookDelete, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID, TargetID: helpers.Ptr(req.WebhookID.String())}
+
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return err
+	}
+
+	deleted, err := s.q.DeleteWebhook(ctx, database.DeleteWebhookParams{
+		ID:        req.WebhookID,
+		ProjectID: req.ProjectID,
+	})
+	if err != nil {
+		return errors.Internal(err)
+	}
+	if deleted == 0 {
+		return errors.NotFound("Webhook", "Check the webhook id")
+	}
+
+	entry.Status = config.StatusSuccess
+	s.audit.Log(ctx, entry)
+	return nil
+}
+
+// Deliveries is the delivery log of a webhook, newest first.
+func (s *WebhookService) Deliveries(ctx context.Context, req config.WebhookDeliveriesRequest) (*config.WebhookDeliveriesResponse, error) {
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		return nil, err
+	}
+
+	webhook, err := s.q.GetWebhook(ctx, req.WebhookID)
+	if err != nil && !dberrors.IsNoRows(err) {
+		return nil, errors.Internal(err)
+	}
+	if err != nil || webhook.ProjectID != req.ProjectID {
+		return nil, errors.NotFound("Webhook", "Check the webhook id")
+	}
+
+	limit := req.Limit
+	if limit <= 0 {
+		limit = 50
+	} else if limit > 200 {
+		limit = 200
+	}
+
+	rows, err := s.q.ListWebhookDeliveries(ctx, database.ListWebhookDeliveriesParams{
+		WebhookID: req.WebhookID,
+		Status:    nullString(req.Status),
+		LimitVal:  limit,
+	})
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	resp := &config.WebhookDeliveriesResponse{
+		Deliveries: make([]config.WebhookDelivery, len(rows)),
+	}
+	for i, row := range rows {
+		resp.Deliveries[i] = toWebhookDelivery(row)
+	}
+	return resp, nil
+}
+
+// Redeliver queues a delivery again with a fresh retry budget, whatever state
+// it is in. Subscribers dedupe on the payload id.
+func (s *WebhookService) Redeliver(ctx context.Context, req config.WebhookRedeliverRequest) (*config.WebhookDelivery, error) {
+	entry := AuditEntry{Action: config.ActionWebhookRedeliver, ActorType: config.ActorTypeUser, ActorID: req.UserID.String(), ActorEmail: actorEmail(ctx, s.q, req.UserID), ProjectID: &req.ProjectID, TargetID: helpers.Ptr(req.DeliveryID.String())}
+
+	if _, err := requireProjectAdmin(ctx, s.q, req.ProjectID, req.UserID); err != nil {
+		entry.Status, entry.ErrMsg = config.StatusFailure, helpers.Ptr("permission denied")
+		s.audit.Log(ctx, entry)
+		return nil, err
+	}
+
+	delivery, err := s.q.GetWebhookDelivery(ctx, req.DeliveryID)
+	if err != nil && !dberrors.IsNoRows(err) {
+		return nil, errors.Internal(err)
+	}
+	if err != nil || delivery.ProjectID != req.ProjectID {
+		return nil, errors.NotFound("Delivery", "Check the delivery id")
+	}
+
+	requeued, err := s.q.RequeueWebhookDelivery(ctx, req.DeliveryID)
+	if err != nil {
+		return nil, errors.Internal(err)
+	}
+
+	entry.Status = config.StatusSuccess
+	entry.Metadata = mustJSON(map[string]any{"webhook_id": delivery.WebhookID, "previous_status": delivery.Status, "attempts": delivery.Attempts})
+	s.audit.Log(ctx, entry)
+
+	resp := toWebhookDelivery(requeued)
+	return &resp, nil
+}
+
+// enqueue queues a delivery for every webhook of the project subscribed to
+// the audited action. The payload is built from the audit entry alone, which
+// never holds secret material.
+func (s *WebhookService) enqueue(ctx context.Context, auditLog *config.AuditLog) {
+	webhooks, err := s.q.ListWebhooks(ctx, *auditLog.ProjectID)
+	if err != nil {
+		log.Printf("failed to load webhooks: project=%s err=%v", *auditLog.ProjectID, err)
+		return
+	}
+
+	var payload json.RawMessage
+	for _, webhook := range webhooks {
+		var events []string
+		if err := json.Unmarshal(webhook.Events, &events); err != nil || !slices.Contains(events, auditLog.Action) {
+			continue
+		}
+
+		if payload == nil {
+			payload = mustJSON(config.WebhookPayload{
+				ID:          auditLog.ID,
+				Action:      auditLog.Action,
+				Timestamp:   auditLog.Timestamp,
+				ProjectID:   *auditLog.ProjectID,
+				Environment: auditLog.Environment,
+				ActorType:   auditLog.ActorType,
+				ActorID:     auditLog.ActorID,
+				TargetID:    auditLog.TargetID,
+				Metadata:    auditLog.Metadata,
+			})
+		}
+
+		if err := s.q.EnqueueWebhookDelivery(ctx, database.EnqueueWebhookDeliveryParams{
+			ID:        uuid.New(),
+			WebhookID: webhook.ID,
+			Action:    auditLog.Action,
+			Payload:   payload,
+		}); err != nil {
+			log.Printf("failed to queue webhook delivery: webhook=%s action=%s err=%v", webhook.ID, auditLog.Action, err)
+		}
+	}
+}
+
+// DeliverPending sends the deliveries that are due. It runs as a background
+// job on every replica; claiming leases the rows so each is sent once. A
+// delivery whose outcome cannot be recorded keeps its lease and is retried
+// once it runs out; the rest of the batch still goes out.
+func (s *WebhookService) DeliverPending(ctx context.Context) error {
+	now := time.Now().UTC()
+	deliveries, err := s.q.ClaimWebhookDeliveries(ctx, database.ClaimWebhookDeliveriesParams{
+		LeaseUntil: now.Add(webhookLease),
+		Now:        now,
+		LimitVal:   webhookBatchSize,
+	})
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, delivery := range deliveries {
+		if err := s.deliver(ctx, delivery); err != nil {
+			log.Printf("failed to record webhook delivery: delivery=%s webhook=%s err=%v", delivery.ID, delivery.WebhookID, err)
+			failed++
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %d webhook deliveries could not be recorded", failed, len(deliveries))
+	}
+	return nil
+}
+
+func (s *WebhookService) deliver(ctx context.Context, delivery database.WebhookDelivery) error {
+	webhook, err := s.q.GetWebhook(ctx, delivery.WebhookID)
+	if err != nil {
+		if dberrors.IsNoRows(err) {
+			return nil
+		}
+		return err
+	}
+
+	statusCode, sendErr := s.send(ctx, webhook, delivery)
+
+	var lastStatusCode sql.NullInt32
+	if statusCode != 0 {
+		lastStatusCode = sql.NullInt32{Int32: int32(statusCode), Valid: true}
+	}
+
+	if sendErr == nil {
+		return s.q.MarkWebhookDelivered(ctx, database.MarkWebhookDeliveredParams{
+			ID:             delivery.ID,
+			LastStatusCode: lastStatusCode,
+		})
+	}
+
+	attempts := delivery.Attempts + 1
+	status := config.WebhookDeliveryPending
+	if attempts >= webhookMaxAttempts {
+		status = config.WebhookDeliveryDead
+	}
+
+	message := sendErr.Error()
+	if len(message) > webhookMaxErrorLen {
+		message = message[:webhookMaxErrorLen]
+	}
+
+	return s.q.MarkWebhookAttemptFailed(ctx, database.MarkWebhookAttemptFailedParams{
+		ID:             delivery.ID,
+		Status:         status,
+		NextAttemptAt:  time.Now().UTC().Add(webhookBackoff(attempts)),
+		LastStatusCode: lastStatusCode,
+		LastError:      sql.NullString{String: message, Valid: true},
+	})
+}
+
+// send POSTs the payload. Receivers verify X-Envcrypt-Signature, an
+// HMAC-SHA256 over "<timestamp>.<body>" with the webhook secret, and reject
+// stale timestamps to stop replays.
+func (s *WebhookService) send(ctx context.Context, webhook database.Webhook, delivery database.WebhookDelivery) (int, error) {
+	secret, err := openWebhookSecret(s.key, webhook.ID, webhook.SecretCiphertext, webhook.SecretNonce)
+	if err != nil {
+		return 0, err
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, webhook.Url, bytes.NewReader(delivery.Payload))
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "envcrypt-webhooks")
+	req.Header.Set("X-Envcrypt-Event", delivery.Action)
+	req.Header.Set("X-Envcrypt-Delivery", delivery.ID.String())
+	req.Header.Set("X-Envcrypt-Timestamp", timestamp)
+	req.Header.Set("X-Envcrypt-Signature", "sha256="+signWebhook(secret, timestamp, delivery.Payload))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	_, _ = io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver responded with %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func signWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// webhookBackoff is the wait before the next attempt after the given number
+// of failed ones.
+func webhookBackoff(attempts int32) time.Duration {
+	delay := webhookBaseDelay
+	for i := int32(1); i < attempts && delay < webhookMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxDelay {
+		delay = webhookMaxDelay
+	}
+	return delay
+}
+
+func toWebhook(row database.Webhook) config.Webhook {
+	var events []string
+	_ = json.Unmarshal(row.Events, &events)
+
+	return config.Webhook{
+		ID:        row.ID,
+		URL:       row.Url,
+		Events:    events,
+		CreatedBy: row.CreatedBy,
+		CreatedAt: row.CreatedAt,
+	}
+}
+
+func toWebhookDelivery(row database.WebhookDelivery) config.WebhookDelivery {
+	delivery := config.WebhookDelivery{
+		ID:        row.ID,
+		WebhookID: row.WebhookID,
+		Action:    row.Action,
+		Status:    row.Status,
+		Attempts:  row.Attempts,
+		CreatedAt: row.CreatedAt,
+	}
+	if row.Status == config.WebhookDeliveryPending {
+		delivery.NextAttemptAt = &row.NextAttemptAt
+	}
+	if row.LastStatusCode.Valid {
+		delivery.LastStatusCode = &row.LastStatusCode.Int32
+	}
+	if row.LastError.Valid {
+		delivery.LastError = &row.LastError.String
+	}
+	if row.DeliveredAt.Valid {
+		delivery.DeliveredAt = &row.DeliveredAt.Time
+	}
+	return delivery
+}
@@ -0,0 +1,185 @@
+// Package envschema describes the variables a project's environments are
+// expected to hold and validates decrypted environments against them.
+//
+// Schemas are plaintext metadata stored by the server. Values never leave the
+// client: Validate runs on the decrypted environment before it is pushed.
+package envschema
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strconv"
+)
+
+// Variable types. A variable without a type accepts any value.
+const (
+	TypeString = "string"
+	TypeInt    = "int"
+	TypeFloat  = "float"
+	TypeBool   = "bool"
+	TypeURL    = "url"
+)
+
+var namePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// environmentNamePattern is the server's rule for environment names.
+var environmentNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_.-]{0,62}$`)
+
+type Schema struct {
+	Variables []Variable `json:"variables"`
+}
+
+type Variable struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Type        string `json:"type,omitempty"`
+	Required    bool   `json:"required,omitempty"`
+
+	// Pattern is a regular expression the whole value must match.
+	Pattern string `json:"pattern,omitempty"`
+	// Enum lists the only values allowed.
+	Enum []string `json:"enum,omitempty"`
+
+	// Environments limits the variable to the named environments; without
+	// it the variable applies to every environment of the project.
+	Environments []string `json:"environments,omitempty"`
+}
+
+// Violation is one problem found by Check or Validate. Index is the position
+// of the variable in the schema, which tells apart variables whose names are
+// missing or repeated.
+type Violation struct {
+	Index    int    `json:"index"`
+	Variable string `json:"variable"`
+	Message  string `json:"message"`
+}
+
+func (v Violation) Error() string {
+	return v.Variable + ": " + v.Message
+}
+
+// Check reports problems with the schema itself: invalid or repeated names,
+// unknown types, patterns that do not compile, enum values the type or
+// pattern would reject and invalid environment names.
+func (s Schema) Check() []Violation {
+	var violations []Violation
+	seen := make(map[string]bool, len(s.Variables))
+	for i, variable := range s.Variables {
+		report := func(message string) {
+			violations = append(violations, Violation{Index: i, Variable: variable.Name, Message: message})
+		}
+
+		if !namePattern.MatchString(variable.Name) {
+			report("name must start with a letter or '_' and hold only letters, digits and '_'")
+			continue
+		}
+		if seen[variable.Name] {
+			report("variable is defined more than once")
+		}
+		seen[variable.Name] = true
+
+		switch variable.Type {
+		case "", TypeString, TypeInt, TypeFloat, TypeBool, TypeURL:
+		default:
+			report("type must be one of string, int, float, bool or url")
+		}
+
+		var pattern *regexp.Regexp
+		if variable.Pattern != "" {
+			var err error
+			if pattern, err = compilePattern(variable.Pattern); err != nil {
+				report("pattern does not compile: " + err.Error())
+			}
+		}
+		for _, value := range variable.Enum {
+			if err := checkType(variable.Type, value); err != nil {
+				report(fmt.Sprintf("enum value %q %s", value, err))
+			} else if pattern != nil && !pattern.MatchString(value) {
+				report(fmt.Sprintf("enum value %q does not match the pattern", value))
+			}
+		}
+
+		for _, envName := range variable.Environments {
+			if !environmentNamePattern.MatchString(envName) {
+				report(fmt.Sprintf("environment %q is not a valid environment name", envName))
+			}
+		}
+	}
+	return violations
+}
+
+// Validate checks the decrypted variables of environment envName against the
+// schema. Variables the schema does not mention are allowed. The schema is
+// expected to have passed Check; variables with broken patterns are reported
+// rather than skipped.
+func (s Schema) Validate(envName string, vars map[string]string) []Violation {
+	var violations []Violation
+	for i, variable := range s.Variables {
+		if len(variable.Environments) > 0 && !slices.Contains(variable.Environments, envName) {
+			continue
+		}
+		report := func(message string) {
+			violations = append(violations, Violation{Index: i, Variable: variable.Name, Message: message})
+		}
+
+		value, ok := vars[variable.Name]
+		if !ok {
+			if variable.Required {
+				report("required variable is missing")
+			}
+			continue
+		}
+
+		if err := checkType(variable.Type, value); err != nil {
+			report("value " + err.Error())
+			continue
+		}
+		if len(variable.Enum) > 0 && !slices.Contains(variable.Enum, value) {
+			report("value is not one of the allowed values")
+			continue
+		}
+		if variable.Pattern != "" {
+			pattern, err := compilePattern(variable.Pattern)
+			if err != nil {
+				report("pattern does not compile: " + err.Error())
+				continue
+			}
+			if !pattern.MatchString(value) {
+				report("value does not match the required pattern")
+			}
+		}
+	}
+	return violations
+}
+
+// compilePattern compiles a variable pattern so it must match the whole value.
+func compilePattern(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+// checkType reports why value is not of type t. Messages never include the
+// value, so violations are safe to log.
+func checkType(t, value string) error {
+	switch t {
+	case TypeInt:
+		if _, err := strconv.ParseInt(value, 10, 64); err != nil {
+			return errors.New("is not an integer")
+		}
+	case TypeFloat:
+		if _, err := strconv.ParseFloat(value, 64); err != nil {
+			return errors.New("is not a number")
+		}
+	case TypeBool:
+		if _, err := strconv.ParseBool(value); err != nil {
+			return errors.New("is not a boolean")
+		}
+	case TypeURL:
+		if u, err := url.Parse(value); err != nil || u.Scheme == "" || u.Host == "" {
+			return errors.New("is not an absolute URL")
+		}
+	}
+	return nil
+}
@@ -0,0 +1,183 @@
+package envschema_test
+
+import (
+	"slices"
+	"testing"
+
+	"github.com/vijayvenkatj/envcrypt/pkg/envschema"
+)
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		schema   envschema.Schema
+		expected []envschema.Violation
+	}{
+		{
+			name: "valid schema",
+			schema: envschema.Schema{Variables: []envschema.Variable{
+				{Name: "PORT", Type: envschema.TypeInt, Required: true},
+				{Name: "LOG_LEVEL", Enum: []string{"debug", "info"}, Pattern: "[a-z]+"},
+				{Name: "API_URL", Type: envschema.TypeURL, Environments: []string{"staging", "prod-eu"}},
+			}},
+		},
+		{
+			name: "invalid and repeated names are told apart by index",
+			schema: envschema.Schema{Variables: []envschema.Variable{
+				{Name: ""},
+				{Name: "1PORT"},
+				{Name: "PORT"},
+				{Name: "PORT"},
+			}},
+			expected: []envschema.Violation{
+				{Index: 0, Variable: "", Message: "name must start with a letter or '_' and hold only letters, digits and '_'"},
+				{Index: 1, Variable: "1PORT", Message: "name must start with a letter or '_' and hold only letters, digits and '_'"},
+				{Index: 3, Variable: "PORT", Message: "variable is defined more than once"},
+			},
+		},
+		{
+			name: "unknown type",
+			schema: envschema.Schema{Variables: []envschema.Variable{
+				{Name: "PORT", Type: "number"},
+			}},
+			expected: []envschema.Violation{
+				{Index: 0, Variable: "PORT", Message: "type must be one of string, int, float, bool or url"},
+			},
+		},
+		{
+			name: "pattern that does not compile",
+			schema: envschema.Schema{Variables: []envschema.Variable{
+				{Name: "REGION", Pattern: "eu-("},
+			}},
+			expected: []envschema.Violation{
+				{Index: 0, Variable: "REGION", Message: "pattern does not compile: error parsing regexp: missing closing ): `^(?:eu-()$`"},
+			},
+		},
+		{
+			name: "enum values must have the variable's type",
+			schema: envschema.Schema{Variables: []envschema.Variable{
+				{Name: "WORKERS", Type: envschema.TypeInt, Enum: []string{"1", "many"}},
+			}},
+			expected: []envschema.Violation{
+				{Index: 0, Variable: "WORKERS", Message: `enum value "many" is not an integer`},
+			},
+		},
+		{
+			name: "enum values must match the pattern",
+			schema: envschema.Schema{Variables: []envschema.Variable{
+				{Name: "REGION", Pattern: "eu-[a-z]+", Enum: []string{"eu-west", "us-east", "eu-west-1"}},
+			}},
+			expected: []envschema.Violation{
+				{Index: 0, Variable: "REGION", Message: `enum value "us-east" does not match the pattern`},
+				{Index: 0, Variable: "REGION", Message: `enum value "eu-west-1" does not match the pattern`},
+			},
+		},
+		{
+			name: "environment names must be valid",
+			schema: envschema.Schema{Variables: []envschema.Variable{
+				{Name: "API_URL", Environments: []string{"prod", "Prod", ""}},
+			}},
+			expected: []envschema.Violation{
+				{Index: 0, Variable: "API_URL", Message: `environment "Prod" is not a valid environment name`},
+				{Index: 0, Variable: "API_URL", Message: `environment "" is not a valid environment name`},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := tt.schema.Check()
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Check() = %+v, want %+v", got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestValidate(t *testing.T) {
+	schema := envschema.Schema{Variables: []envschema.Variable{
+		{Name: "PORT", Type: envschema.TypeInt, Required: true},
+		{Name: "RATIO", Type: envschema.TypeFloat},
+		{Name: "DEBUG", Type: envschema.TypeBool},
+		{Name: "API_URL", Type: envschema.TypeURL},
+		{Name: "LOG_LEVEL", Enum: []string{"debug", "info", "warn"}},
+		{Name: "REGION", Pattern: "eu-[a-z]+"},
+		{Name: "SENTRY_DSN", Required: true, Environments: []string{"production"}},
+	}}
+
+	tests := []struct {
+		name     string
+		envName  string
+		vars     map[string]string
+		expected []envschema.Violation
+	}{
+		{
+			name:    "valid environment",
+			envName: "staging",
+			vars: map[string]string{
+				"PORT":      "8080",
+				"RATIO":     "0.5",
+				"DEBUG":     "true",
+				"API_URL":   "https://api.example.com",
+				"LOG_LEVEL": "info",
+				"REGION":    "eu-west",
+				"EXTRA":     "not in the schema",
+			},
+		},
+		{
+			name:     "required variable missing",
+			envName:  "staging",
+			vars:     map[string]string{},
+			expected: []envschema.Violation{{Index: 0, Variable: "PORT", Message: "required variable is missing"}},
+		},
+		{
+			name:    "values of the wrong type",
+			envName: "staging",
+			vars: map[string]string{
+				"PORT":    "eighty",
+				"RATIO":   "half",
+				"DEBUG":   "maybe",
+				"API_URL": "api.example.com",
+			},
+			expected: []envschema.Violation{
+				{Index: 0, Variable: "PORT", Message: "value is not an integer"},
+				{Index: 1, Variable: "RATIO", Message: "value is not a number"},
+				{Index: 2, Variable: "DEBUG", Message: "value is not a boolean"},
+				{Index: 3, Variable: "API_URL", Message: "value is not an absolute URL"},
+			},
+		},
+		{
+			name:     "value outside the enum",
+			envName:  "staging",
+			vars:     map[string]string{"PORT": "80", "LOG_LEVEL": "trace"},
+			expected: []envschema.Violation{{Index: 4, Variable: "LOG_LEVEL", Message: "value is not one of the allowed values"}},
+		},
+		{
+			name:     "pattern must match the whole value",
+			envName:  "staging",
+			vars:     map[string]string{"PORT": "80", "REGION": "eu-west-1"},
+			expected: []envschema.Violation{{Index: 5, Variable: "REGION", Message: "value does not match the required pattern"}},
+		},
+		{
+			name:     "variable limited to another environment is skipped",
+			envName:  "staging",
+			vars:     map[string]string{"PORT": "80"},
+			expected: nil,
+		},
+		{
+			name:     "variable limited to this environment applies",
+			envName:  "production",
+			vars:     map[string]string{"PORT": "80"},
+			expected: []envschema.Violation{{Index: 6, Variable: "SENTRY_DSN", Message: "required variable is missing"}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := schema.Validate(tt.envName, tt.vars)
+			if !slices.Equal(got, tt.expected) {
+				t.Errorf("Validate(%q) = %+v, want %+v", tt.envName, got, tt.expected)
+			}
+		})
+	}
+}